@@ -0,0 +1,69 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+// Conditions and condition Reasons for the MetalsoftCluster object.
+
+const (
+	// InfrastructureReadyCondition reports on the state of the MetalSoft
+	// infrastructure backing the cluster.
+	InfrastructureReadyCondition clusterv1.ConditionType = "InfrastructureReady"
+
+	// InfrastructureProvisionFailedReason used when the MetalSoft infrastructure
+	// could not be created, looked up or deployed.
+	InfrastructureProvisionFailedReason = "InfrastructureProvisionFailed"
+	// InfrastructureDeployingReason used when the MetalSoft infrastructure is being deployed.
+	InfrastructureDeployingReason = "InfrastructureDeploying"
+	// CredentialsUnavailableReason used when the MetalSoft credentials of the
+	// cluster cannot be read from its credentials Secret.
+	CredentialsUnavailableReason = "CredentialsUnavailable"
+	// NamespaceNotAllowedByIdentityReason used when the MetalsoftClusterIdentity
+	// referenced by the cluster does not allow the namespace of the cluster.
+	NamespaceNotAllowedByIdentityReason = "NamespaceNotAllowedByIdentity"
+	// WaitingForMachinesDeletionReason used when the MetalSoft infrastructure
+	// is not deleted yet because MetalsoftMachines of the cluster still exist.
+	WaitingForMachinesDeletionReason = "WaitingForMachinesDeletion"
+
+	// ControlPlaneEndpointReadyCondition reports whether the control plane
+	// endpoint of the cluster is known.
+	ControlPlaneEndpointReadyCondition clusterv1.ConditionType = "ControlPlaneEndpointReady"
+
+	// WaitingForControlPlaneEndpointReason used when spec.controlPlaneEndpoint.host is not set yet.
+	WaitingForControlPlaneEndpointReason = "WaitingForControlPlaneEndpoint"
+)
+
+// Conditions and condition Reasons for the MetalsoftMachine object.
+
+const (
+	// InstanceReadyCondition reports on the state of the MetalSoft instance
+	// backing the machine.
+	InstanceReadyCondition clusterv1.ConditionType = "InstanceReady"
+
+	// WaitingForClusterInfrastructureReason used when the machine is waiting
+	// for the cluster infrastructure to be ready before provisioning.
+	WaitingForClusterInfrastructureReason = "WaitingForClusterInfrastructure"
+	// WaitingForBootstrapDataReason used when the machine is waiting for the
+	// bootstrap provider to generate its bootstrap data.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+	// InstanceProvisionFailedReason used when the MetalSoft instance array of
+	// the machine could not be created or deployed.
+	InstanceProvisionFailedReason = "InstanceProvisionFailed"
+	// InstanceDeployingReason used when the MetalSoft instance is being deployed.
+	InstanceDeployingReason = "InstanceDeploying"
+)
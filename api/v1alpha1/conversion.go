@@ -0,0 +1,451 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+)
+
+// v1alpha1 objects convert to and from the v1beta1 hub. ConvertFrom stores
+// the hub object in the utilconversion.DataAnnotation annotation and ConvertTo
+// restores from it the hub fields this version cannot represent, so that
+// hub-spoke-hub round trips are lossless.
+
+// ConvertTo converts this MetalsoftCluster to the Hub version (v1beta1).
+func (src *MetalsoftCluster) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftCluster)
+	dst.ObjectMeta = src.ObjectMeta
+	convertClusterSpecToV1beta1(&src.Spec, &dst.Spec)
+	dst.Status = v1beta1.MetalsoftClusterStatus{
+		Ready:            src.Status.Ready,
+		InfrastructureID: src.Status.InfrastructureID,
+		FailureReason:    src.Status.FailureReason,
+		FailureMessage:   src.Status.FailureMessage,
+		Conditions:       src.Status.Conditions,
+	}
+
+	restored := &v1beta1.MetalsoftCluster{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.ControlPlaneLoadBalancer = restored.Spec.ControlPlaneLoadBalancer
+	dst.Spec.Networks = restored.Spec.Networks
+	dst.Spec.FailureDomains = restored.Spec.FailureDomains
+	dst.Status.InfrastructureCreated = restored.Status.InfrastructureCreated
+	dst.Status.ControlPlaneLoadBalancer = restored.Status.ControlPlaneLoadBalancer
+	dst.Status.Networks = restored.Status.Networks
+	dst.Status.CreatedNetworkIDs = restored.Status.CreatedNetworkIDs
+	dst.Status.FailureDomains = restored.Status.FailureDomains
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftCluster) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftCluster)
+	dst.ObjectMeta = src.ObjectMeta
+	convertClusterSpecFromV1beta1(&src.Spec, &dst.Spec)
+	dst.Status = MetalsoftClusterStatus{
+		Ready:            src.Status.Ready,
+		InfrastructureID: src.Status.InfrastructureID,
+		FailureReason:    src.Status.FailureReason,
+		FailureMessage:   src.Status.FailureMessage,
+		Conditions:       src.Status.Conditions,
+	}
+
+	return utilconversion.MarshalData(src, dst)
+}
+
+// ConvertTo converts this MetalsoftClusterList to the Hub version (v1beta1).
+func (src *MetalsoftClusterList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftClusterList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]v1beta1.MetalsoftCluster, len(src.Items))
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftClusterList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftClusterList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]MetalsoftCluster, len(src.Items))
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertTo converts this MetalsoftMachine to the Hub version (v1beta1).
+func (src *MetalsoftMachine) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftMachine)
+	dst.ObjectMeta = src.ObjectMeta
+	convertMachineSpecToV1beta1(&src.Spec, &dst.Spec)
+	dst.Status = v1beta1.MetalsoftMachineStatus{
+		Ready:           src.Status.Ready,
+		Addresses:       src.Status.Addresses,
+		InstanceArrayID: src.Status.InstanceArrayID,
+		InstanceID:      src.Status.InstanceID,
+		FailureReason:   src.Status.FailureReason,
+		FailureMessage:  src.Status.FailureMessage,
+		Conditions:      src.Status.Conditions,
+	}
+	if src.Status.InstanceState != nil {
+		state := v1beta1.InstanceState(*src.Status.InstanceState)
+		dst.Status.InstanceState = &state
+	}
+
+	restored := &v1beta1.MetalsoftMachine{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.NetworkInterfaces = restored.Spec.NetworkInterfaces
+	dst.Spec.HostSelector = restored.Spec.HostSelector
+	dst.Spec.HardwareRequirements = restored.Spec.HardwareRequirements
+	dst.Spec.BootstrapDelivery = restored.Spec.BootstrapDelivery
+	dst.Status.Placement = restored.Status.Placement
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftMachine) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftMachine)
+	dst.ObjectMeta = src.ObjectMeta
+	convertMachineSpecFromV1beta1(&src.Spec, &dst.Spec)
+	dst.Status = MetalsoftMachineStatus{
+		Ready:           src.Status.Ready,
+		Addresses:       src.Status.Addresses,
+		InstanceArrayID: src.Status.InstanceArrayID,
+		InstanceID:      src.Status.InstanceID,
+		FailureReason:   src.Status.FailureReason,
+		FailureMessage:  src.Status.FailureMessage,
+		Conditions:      src.Status.Conditions,
+	}
+	if src.Status.InstanceState != nil {
+		state := InstanceState(*src.Status.InstanceState)
+		dst.Status.InstanceState = &state
+	}
+
+	return utilconversion.MarshalData(src, dst)
+}
+
+// ConvertTo converts this MetalsoftMachineList to the Hub version (v1beta1).
+func (src *MetalsoftMachineList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftMachineList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]v1beta1.MetalsoftMachine, len(src.Items))
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftMachineList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftMachineList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]MetalsoftMachine, len(src.Items))
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertTo converts this MetalsoftMachineTemplate to the Hub version (v1beta1).
+func (src *MetalsoftMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftMachineTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	convertMachineSpecToV1beta1(&src.Spec.Template.Spec, &dst.Spec.Template.Spec)
+
+	restored := &v1beta1.MetalsoftMachineTemplate{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.Template.Spec.NetworkInterfaces = restored.Spec.Template.Spec.NetworkInterfaces
+	dst.Spec.Template.Spec.HostSelector = restored.Spec.Template.Spec.HostSelector
+	dst.Spec.Template.Spec.HardwareRequirements = restored.Spec.Template.Spec.HardwareRequirements
+	dst.Spec.Template.Spec.BootstrapDelivery = restored.Spec.Template.Spec.BootstrapDelivery
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftMachineTemplate) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftMachineTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	convertMachineSpecFromV1beta1(&src.Spec.Template.Spec, &dst.Spec.Template.Spec)
+
+	return utilconversion.MarshalData(src, dst)
+}
+
+// ConvertTo converts this MetalsoftMachineTemplateList to the Hub version (v1beta1).
+func (src *MetalsoftMachineTemplateList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftMachineTemplateList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]v1beta1.MetalsoftMachineTemplate, len(src.Items))
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftMachineTemplateList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftMachineTemplateList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]MetalsoftMachineTemplate, len(src.Items))
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertTo converts this MetalsoftClusterTemplate to the Hub version (v1beta1).
+func (src *MetalsoftClusterTemplate) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftClusterTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	convertClusterSpecToV1beta1(&src.Spec.Template.Spec, &dst.Spec.Template.Spec)
+
+	restored := &v1beta1.MetalsoftClusterTemplate{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	dst.Spec.Template.Spec.ControlPlaneLoadBalancer = restored.Spec.Template.Spec.ControlPlaneLoadBalancer
+	dst.Spec.Template.Spec.Networks = restored.Spec.Template.Spec.Networks
+	dst.Spec.Template.Spec.FailureDomains = restored.Spec.Template.Spec.FailureDomains
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftClusterTemplate) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftClusterTemplate)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.Template.ObjectMeta = src.Spec.Template.ObjectMeta
+	convertClusterSpecFromV1beta1(&src.Spec.Template.Spec, &dst.Spec.Template.Spec)
+
+	return utilconversion.MarshalData(src, dst)
+}
+
+// ConvertTo converts this MetalsoftClusterTemplateList to the Hub version (v1beta1).
+func (src *MetalsoftClusterTemplateList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftClusterTemplateList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]v1beta1.MetalsoftClusterTemplate, len(src.Items))
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftClusterTemplateList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftClusterTemplateList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]MetalsoftClusterTemplate, len(src.Items))
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertTo converts this MetalsoftClusterIdentity to the Hub version (v1beta1).
+func (src *MetalsoftClusterIdentity) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftClusterIdentity)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.SecretName = src.Spec.SecretName
+	dst.Spec.AllowedNamespaces = nil
+	if src.Spec.AllowedNamespaces != nil {
+		dst.Spec.AllowedNamespaces = &v1beta1.AllowedNamespaces{Selector: src.Spec.AllowedNamespaces.Selector}
+	}
+
+	restored := &v1beta1.MetalsoftClusterIdentity{}
+	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
+		return err
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftClusterIdentity) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftClusterIdentity)
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec.SecretName = src.Spec.SecretName
+	dst.Spec.AllowedNamespaces = nil
+	if src.Spec.AllowedNamespaces != nil {
+		dst.Spec.AllowedNamespaces = &AllowedNamespaces{Selector: src.Spec.AllowedNamespaces.Selector}
+	}
+
+	return utilconversion.MarshalData(src, dst)
+}
+
+// ConvertTo converts this MetalsoftClusterIdentityList to the Hub version (v1beta1).
+func (src *MetalsoftClusterIdentityList) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.MetalsoftClusterIdentityList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]v1beta1.MetalsoftClusterIdentity, len(src.Items))
+	for i := range src.Items {
+		if err := src.Items[i].ConvertTo(&dst.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ConvertFrom converts from the Hub version (v1beta1) to this version.
+func (dst *MetalsoftClusterIdentityList) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.MetalsoftClusterIdentityList)
+	dst.ListMeta = src.ListMeta
+	if src.Items == nil {
+		dst.Items = nil
+		return nil
+	}
+	dst.Items = make([]MetalsoftClusterIdentity, len(src.Items))
+	for i := range src.Items {
+		if err := dst.Items[i].ConvertFrom(&src.Items[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func convertClusterSpecToV1beta1(in *MetalsoftClusterSpec, out *v1beta1.MetalsoftClusterSpec) {
+	out.Datacenter = in.Datacenter
+	out.InfrastructureLabel = in.InfrastructureLabel
+	out.InfrastructureID = in.InfrastructureID
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	out.CredentialsRef = in.CredentialsRef
+	out.IdentityRef = nil
+	if in.IdentityRef != nil {
+		out.IdentityRef = &v1beta1.MetalsoftClusterIdentityReference{Kind: in.IdentityRef.Kind, Name: in.IdentityRef.Name}
+	}
+}
+
+func convertClusterSpecFromV1beta1(in *v1beta1.MetalsoftClusterSpec, out *MetalsoftClusterSpec) {
+	out.Datacenter = in.Datacenter
+	out.InfrastructureLabel = in.InfrastructureLabel
+	out.InfrastructureID = in.InfrastructureID
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	out.CredentialsRef = in.CredentialsRef
+	out.IdentityRef = nil
+	if in.IdentityRef != nil {
+		out.IdentityRef = &MetalsoftClusterIdentityReference{Kind: in.IdentityRef.Kind, Name: in.IdentityRef.Name}
+	}
+}
+
+func convertMachineSpecToV1beta1(in *MetalsoftMachineSpec, out *v1beta1.MetalsoftMachineSpec) {
+	out.ProviderID = in.ProviderID
+	out.ServerType = in.ServerType
+	out.ServerTypeSelector = nil
+	if in.ServerTypeSelector != nil {
+		out.ServerTypeSelector = &v1beta1.ServerTypeSelector{
+			MinCores: in.ServerTypeSelector.MinCores,
+			MinRAMGB: in.ServerTypeSelector.MinRAMGB,
+		}
+	}
+	out.OSTemplate = in.OSTemplate
+	out.BootDriveSizeGB = in.BootDriveSizeGB
+	out.AdditionalDriveArrays = nil
+	if in.AdditionalDriveArrays != nil {
+		out.AdditionalDriveArrays = make([]v1beta1.DriveArraySpec, len(in.AdditionalDriveArrays))
+		for i, driveArray := range in.AdditionalDriveArrays {
+			out.AdditionalDriveArrays[i] = v1beta1.DriveArraySpec(driveArray)
+		}
+	}
+	out.SSHKeyIDs = in.SSHKeyIDs
+	out.CustomVariables = in.CustomVariables
+}
+
+func convertMachineSpecFromV1beta1(in *v1beta1.MetalsoftMachineSpec, out *MetalsoftMachineSpec) {
+	out.ProviderID = in.ProviderID
+	out.ServerType = in.ServerType
+	out.ServerTypeSelector = nil
+	if in.ServerTypeSelector != nil {
+		out.ServerTypeSelector = &ServerTypeSelector{
+			MinCores: in.ServerTypeSelector.MinCores,
+			MinRAMGB: in.ServerTypeSelector.MinRAMGB,
+		}
+	}
+	out.OSTemplate = in.OSTemplate
+	out.BootDriveSizeGB = in.BootDriveSizeGB
+	out.AdditionalDriveArrays = nil
+	if in.AdditionalDriveArrays != nil {
+		out.AdditionalDriveArrays = make([]DriveArraySpec, len(in.AdditionalDriveArrays))
+		for i, driveArray := range in.AdditionalDriveArrays {
+			out.AdditionalDriveArrays[i] = DriveArraySpec(driveArray)
+		}
+	}
+	out.SSHKeyIDs = in.SSHKeyIDs
+	out.CustomVariables = in.CustomVariables
+}
@@ -17,29 +17,90 @@ limitations under the License.
 package v1alpha1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+const (
+	// ClusterFinalizer allows MetalsoftClusterReconciler to clean up MetalSoft
+	// resources associated with MetalsoftCluster before removing it from the apiserver.
+	ClusterFinalizer = "metalsoftcluster.infrastructure.cluster.x-k8s.io"
+)
 
 // MetalsoftClusterSpec defines the desired state of MetalsoftCluster
+// +kubebuilder:validation:XValidation:rule="!(has(self.credentialsRef) && has(self.identityRef))",message="credentialsRef and identityRef are mutually exclusive"
 type MetalsoftClusterSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Datacenter is the label of the MetalSoft datacenter in which the
+	// infrastructure is created.
+	Datacenter string `json:"datacenter"`
+
+	// InfrastructureLabel is the label of the MetalSoft infrastructure backing
+	// the cluster. When empty, it defaults to the MetalsoftCluster name.
+	// +optional
+	InfrastructureLabel string `json:"infrastructureLabel,omitempty"`
+
+	// InfrastructureID is the ID of an existing MetalSoft infrastructure to adopt
+	// instead of creating a new one.
+	// +optional
+	InfrastructureID *int `json:"infrastructureID,omitempty"`
+
+	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
+	// +optional
+	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
 
-	// Foo is an example field of MetalsoftCluster. Edit metalsoftcluster_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// CredentialsRef is a reference to a Secret in the same namespace holding
+	// the MetalSoft API credentials used for this cluster under the endpoint,
+	// userEmail and apiKey keys. When neither CredentialsRef nor IdentityRef
+	// is set, the default credentials Secret of the controller is used.
+	// +optional
+	CredentialsRef *corev1.LocalObjectReference `json:"credentialsRef,omitempty"`
+
+	// IdentityRef is a reference to a MetalsoftClusterIdentity providing the
+	// MetalSoft API credentials used for this cluster. The identity must allow
+	// the namespace of the MetalsoftCluster.
+	// +optional
+	IdentityRef *MetalsoftClusterIdentityReference `json:"identityRef,omitempty"`
 }
 
 // MetalsoftClusterStatus defines the observed state of MetalsoftCluster
 type MetalsoftClusterStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Ready denotes that the MetalSoft infrastructure is deployed and the
+	// cluster is ready to receive machines.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// InfrastructureID is the ID of the MetalSoft infrastructure backing the cluster.
+	// +optional
+	InfrastructureID *int `json:"infrastructureID,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftCluster and will contain a succinct value suitable
+	// for machine interpretation.
+	// +optional
+	FailureReason *capierrors.ClusterStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftCluster and will contain a more verbose string suitable
+	// for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the MetalsoftCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:path=metalsoftclusters,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this MetalsoftCluster belongs"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready",description="Cluster infrastructure is ready for MetalSoft instances"
+//+kubebuilder:printcolumn:name="Datacenter",type="string",JSONPath=".spec.datacenter",description="MetalSoft datacenter"
+//+kubebuilder:printcolumn:name="Infrastructure",type="integer",JSONPath=".status.infrastructureID",description="MetalSoft infrastructure ID"
+//+kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="API Endpoint",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MetalsoftCluster is the Schema for the metalsoftclusters API
 type MetalsoftCluster struct {
@@ -59,6 +120,16 @@ type MetalsoftClusterList struct {
 	Items           []MetalsoftCluster `json:"items"`
 }
 
+// GetConditions returns the observations of the operational state of the MetalsoftCluster resource.
+func (c *MetalsoftCluster) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MetalsoftCluster to the predescribed clusterv1.Conditions.
+func (c *MetalsoftCluster) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&MetalsoftCluster{}, &MetalsoftClusterList{})
 }
@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MetalsoftClusterIdentityKind is the kind of MetalsoftClusterIdentity objects.
+const MetalsoftClusterIdentityKind = "MetalsoftClusterIdentity"
+
+// MetalsoftClusterIdentitySpec defines the desired state of MetalsoftClusterIdentity
+type MetalsoftClusterIdentitySpec struct {
+	// SecretName is the name of the Secret in the controller namespace holding
+	// the MetalSoft API credentials under the endpoint, userEmail and apiKey keys.
+	SecretName string `json:"secretName"`
+
+	// AllowedNamespaces selects the namespaces of the MetalsoftClusters
+	// allowed to use this identity. When nil, no namespace is allowed.
+	// +optional
+	AllowedNamespaces *AllowedNamespaces `json:"allowedNamespaces,omitempty"`
+}
+
+// AllowedNamespaces selects namespaces by label.
+type AllowedNamespaces struct {
+	// Selector is a label query over namespaces. An empty selector matches
+	// all namespaces.
+	// +optional
+	Selector metav1.LabelSelector `json:"selector"`
+}
+
+// MetalsoftClusterIdentityReference is a reference to a MetalsoftClusterIdentity.
+type MetalsoftClusterIdentityReference struct {
+	// Kind of the identity.
+	// +kubebuilder:validation:Enum=MetalsoftClusterIdentity
+	Kind string `json:"kind"`
+
+	// Name of the identity.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:path=metalsoftclusteridentities,scope=Cluster,categories=cluster-api
+//+kubebuilder:printcolumn:name="Secret",type="string",JSONPath=".spec.secretName",description="Secret holding the MetalSoft credentials"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MetalsoftClusterIdentity is the Schema for the metalsoftclusteridentities API.
+// It shares MetalSoft credentials kept in the controller namespace with the
+// MetalsoftClusters of the allowed namespaces.
+type MetalsoftClusterIdentity struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MetalsoftClusterIdentitySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalsoftClusterIdentityList contains a list of MetalsoftClusterIdentity
+type MetalsoftClusterIdentityList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalsoftClusterIdentity `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalsoftClusterIdentity{}, &MetalsoftClusterIdentityList{})
+}
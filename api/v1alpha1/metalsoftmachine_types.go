@@ -18,28 +18,146 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
 )
 
-// EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
-// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+const (
+	// MachineFinalizer allows MetalsoftMachineReconciler to clean up MetalSoft
+	// resources associated with MetalsoftMachine before removing it from the apiserver.
+	MachineFinalizer = "metalsoftmachine.infrastructure.cluster.x-k8s.io"
+)
+
+// InstanceState describes the state of a MetalSoft instance.
+type InstanceState string
+
+var (
+	// InstanceStateOrdered is the state of an instance that has been created
+	// in the infrastructure but not yet deployed.
+	InstanceStateOrdered = InstanceState("ordered")
+
+	// InstanceStateActive is the state of a deployed, running instance.
+	InstanceStateActive = InstanceState("active")
+
+	// InstanceStateStopped is the state of a deployed instance that has been powered off.
+	InstanceStateStopped = InstanceState("stopped")
+
+	// InstanceStateDeleted is the state of an instance that has been deleted.
+	InstanceStateDeleted = InstanceState("deleted")
+)
+
+// DriveArraySpec describes an additional MetalSoft drive array attached to the machine.
+type DriveArraySpec struct {
+	// Label is the label of the drive array within the infrastructure.
+	Label string `json:"label"`
+
+	// SizeGB is the size of each drive in the array, in gigabytes.
+	// +kubebuilder:validation:Minimum=1
+	SizeGB int `json:"sizeGB"`
+
+	// StorageType is the MetalSoft storage type of the drive array.
+	// +kubebuilder:validation:Enum=iscsi_ssd;iscsi_hdd
+	// +optional
+	StorageType string `json:"storageType,omitempty"`
+}
 
 // MetalsoftMachineSpec defines the desired state of MetalsoftMachine
 type MetalsoftMachineSpec struct {
-	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// ProviderID is the unique identifier as specified by the cloud provider.
+	// +optional
+	ProviderID *string `json:"providerID,omitempty"`
+
+	// ServerType is the label of the MetalSoft server type to provision,
+	// e.g. M.16.64.v2. Either ServerType or ServerTypeSelector must be set.
+	// +optional
+	ServerType string `json:"serverType,omitempty"`
+
+	// ServerTypeSelector selects the smallest MetalSoft server type satisfying
+	// the given minimums when ServerType is not set.
+	// +optional
+	ServerTypeSelector *ServerTypeSelector `json:"serverTypeSelector,omitempty"`
+
+	// OSTemplate is the label of the MetalSoft OS template installed on the
+	// boot drive, e.g. ubuntu-22-04.
+	OSTemplate string `json:"osTemplate"`
+
+	// BootDriveSizeGB is the size of the boot drive, in gigabytes.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BootDriveSizeGB int `json:"bootDriveSizeGB,omitempty"`
+
+	// AdditionalDriveArrays are extra drive arrays attached to the machine.
+	// +optional
+	AdditionalDriveArrays []DriveArraySpec `json:"additionalDriveArrays,omitempty"`
 
-	// Foo is an example field of MetalsoftMachine. Edit metalsoftmachine_types.go to remove/update
-	Foo string `json:"foo,omitempty"`
+	// SSHKeyIDs are the IDs of MetalSoft user SSH keys installed on the machine.
+	// +optional
+	SSHKeyIDs []int `json:"sshKeyIDs,omitempty"`
+
+	// CustomVariables are passed to the OS template as MetalSoft custom variables.
+	// +optional
+	CustomVariables map[string]string `json:"customVariables,omitempty"`
+}
+
+// ServerTypeSelector describes the minimum hardware of a MetalSoft server type.
+type ServerTypeSelector struct {
+	// MinCores is the minimum number of processor cores.
+	// +optional
+	MinCores int `json:"minCores,omitempty"`
+
+	// MinRAMGB is the minimum amount of RAM, in gigabytes.
+	// +optional
+	MinRAMGB int `json:"minRAMGB,omitempty"`
 }
 
 // MetalsoftMachineStatus defines the observed state of MetalsoftMachine
 type MetalsoftMachineStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// Ready denotes that the machine's server is deployed and running.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Addresses contains the MetalSoft instance associated addresses.
+	// +optional
+	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
+
+	// InstanceArrayID is the ID of the MetalSoft instance array backing the machine.
+	// +optional
+	InstanceArrayID *int `json:"instanceArrayID,omitempty"`
+
+	// InstanceID is the ID of the MetalSoft instance backing the machine.
+	// +optional
+	InstanceID *int `json:"instanceID,omitempty"`
+
+	// InstanceState is the state of the MetalSoft instance backing the machine.
+	// +optional
+	InstanceState *InstanceState `json:"instanceState,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftMachine and will contain a succinct value suitable
+	// for machine interpretation.
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftMachine and will contain a more verbose string suitable
+	// for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the MetalsoftMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:path=metalsoftmachines,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this MetalsoftMachine belongs"
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.instanceState",description="MetalSoft instance state"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready",description="Machine ready status"
+//+kubebuilder:printcolumn:name="InstanceID",type="string",JSONPath=".spec.providerID",description="MetalSoft instance ID"
+//+kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this MetalsoftMachine"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // MetalsoftMachine is the Schema for the metalsoftmachines API
 type MetalsoftMachine struct {
@@ -59,6 +177,16 @@ type MetalsoftMachineList struct {
 	Items           []MetalsoftMachine `json:"items"`
 }
 
+// GetConditions returns the observations of the operational state of the MetalsoftMachine resource.
+func (m *MetalsoftMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MetalsoftMachine to the predescribed clusterv1.Conditions.
+func (m *MetalsoftMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&MetalsoftMachine{}, &MetalsoftMachineList{})
 }
@@ -22,16 +22,50 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AllowedNamespaces) DeepCopyInto(out *AllowedNamespaces) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AllowedNamespaces.
+func (in *AllowedNamespaces) DeepCopy() *AllowedNamespaces {
+	if in == nil {
+		return nil
+	}
+	out := new(AllowedNamespaces)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriveArraySpec) DeepCopyInto(out *DriveArraySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriveArraySpec.
+func (in *DriveArraySpec) DeepCopy() *DriveArraySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DriveArraySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftCluster) DeepCopyInto(out *MetalsoftCluster) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftCluster.
@@ -52,6 +86,99 @@ func (in *MetalsoftCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterIdentity) DeepCopyInto(out *MetalsoftClusterIdentity) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterIdentity.
+func (in *MetalsoftClusterIdentity) DeepCopy() *MetalsoftClusterIdentity {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterIdentity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftClusterIdentity) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterIdentityList) DeepCopyInto(out *MetalsoftClusterIdentityList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetalsoftClusterIdentity, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterIdentityList.
+func (in *MetalsoftClusterIdentityList) DeepCopy() *MetalsoftClusterIdentityList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterIdentityList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftClusterIdentityList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterIdentityReference) DeepCopyInto(out *MetalsoftClusterIdentityReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterIdentityReference.
+func (in *MetalsoftClusterIdentityReference) DeepCopy() *MetalsoftClusterIdentityReference {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterIdentityReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterIdentitySpec) DeepCopyInto(out *MetalsoftClusterIdentitySpec) {
+	*out = *in
+	if in.AllowedNamespaces != nil {
+		in, out := &in.AllowedNamespaces, &out.AllowedNamespaces
+		*out = new(AllowedNamespaces)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterIdentitySpec.
+func (in *MetalsoftClusterIdentitySpec) DeepCopy() *MetalsoftClusterIdentitySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterIdentitySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftClusterList) DeepCopyInto(out *MetalsoftClusterList) {
 	*out = *in
@@ -87,6 +214,22 @@ func (in *MetalsoftClusterList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftClusterSpec) DeepCopyInto(out *MetalsoftClusterSpec) {
 	*out = *in
+	if in.InfrastructureID != nil {
+		in, out := &in.InfrastructureID, &out.InfrastructureID
+		*out = new(int)
+		**out = **in
+	}
+	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.CredentialsRef != nil {
+		in, out := &in.CredentialsRef, &out.CredentialsRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+	if in.IdentityRef != nil {
+		in, out := &in.IdentityRef, &out.IdentityRef
+		*out = new(MetalsoftClusterIdentityReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterSpec.
@@ -102,6 +245,28 @@ func (in *MetalsoftClusterSpec) DeepCopy() *MetalsoftClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftClusterStatus) DeepCopyInto(out *MetalsoftClusterStatus) {
 	*out = *in
+	if in.InfrastructureID != nil {
+		in, out := &in.InfrastructureID, &out.InfrastructureID
+		*out = new(int)
+		**out = **in
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.ClusterStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterStatus.
@@ -114,13 +279,104 @@ func (in *MetalsoftClusterStatus) DeepCopy() *MetalsoftClusterStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterTemplate) DeepCopyInto(out *MetalsoftClusterTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterTemplate.
+func (in *MetalsoftClusterTemplate) DeepCopy() *MetalsoftClusterTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftClusterTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterTemplateList) DeepCopyInto(out *MetalsoftClusterTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetalsoftClusterTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterTemplateList.
+func (in *MetalsoftClusterTemplateList) DeepCopy() *MetalsoftClusterTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftClusterTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterTemplateResource) DeepCopyInto(out *MetalsoftClusterTemplateResource) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterTemplateResource.
+func (in *MetalsoftClusterTemplateResource) DeepCopy() *MetalsoftClusterTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftClusterTemplateSpec) DeepCopyInto(out *MetalsoftClusterTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftClusterTemplateSpec.
+func (in *MetalsoftClusterTemplateSpec) DeepCopy() *MetalsoftClusterTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftClusterTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftMachine) DeepCopyInto(out *MetalsoftMachine) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachine.
@@ -176,6 +432,33 @@ func (in *MetalsoftMachineList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftMachineSpec) DeepCopyInto(out *MetalsoftMachineSpec) {
 	*out = *in
+	if in.ProviderID != nil {
+		in, out := &in.ProviderID, &out.ProviderID
+		*out = new(string)
+		**out = **in
+	}
+	if in.ServerTypeSelector != nil {
+		in, out := &in.ServerTypeSelector, &out.ServerTypeSelector
+		*out = new(ServerTypeSelector)
+		**out = **in
+	}
+	if in.AdditionalDriveArrays != nil {
+		in, out := &in.AdditionalDriveArrays, &out.AdditionalDriveArrays
+		*out = make([]DriveArraySpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.SSHKeyIDs != nil {
+		in, out := &in.SSHKeyIDs, &out.SSHKeyIDs
+		*out = make([]int, len(*in))
+		copy(*out, *in)
+	}
+	if in.CustomVariables != nil {
+		in, out := &in.CustomVariables, &out.CustomVariables
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineSpec.
@@ -191,6 +474,43 @@ func (in *MetalsoftMachineSpec) DeepCopy() *MetalsoftMachineSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalsoftMachineStatus) DeepCopyInto(out *MetalsoftMachineStatus) {
 	*out = *in
+	if in.Addresses != nil {
+		in, out := &in.Addresses, &out.Addresses
+		*out = make([]v1beta1.MachineAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.InstanceArrayID != nil {
+		in, out := &in.InstanceArrayID, &out.InstanceArrayID
+		*out = new(int)
+		**out = **in
+	}
+	if in.InstanceID != nil {
+		in, out := &in.InstanceID, &out.InstanceID
+		*out = new(int)
+		**out = **in
+	}
+	if in.InstanceState != nil {
+		in, out := &in.InstanceState, &out.InstanceState
+		*out = new(InstanceState)
+		**out = **in
+	}
+	if in.FailureReason != nil {
+		in, out := &in.FailureReason, &out.FailureReason
+		*out = new(errors.MachineStatusError)
+		**out = **in
+	}
+	if in.FailureMessage != nil {
+		in, out := &in.FailureMessage, &out.FailureMessage
+		*out = new(string)
+		**out = **in
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(v1beta1.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineStatus.
@@ -202,3 +522,109 @@ func (in *MetalsoftMachineStatus) DeepCopy() *MetalsoftMachineStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftMachineTemplate) DeepCopyInto(out *MetalsoftMachineTemplate) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineTemplate.
+func (in *MetalsoftMachineTemplate) DeepCopy() *MetalsoftMachineTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftMachineTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftMachineTemplate) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftMachineTemplateList) DeepCopyInto(out *MetalsoftMachineTemplateList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MetalsoftMachineTemplate, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineTemplateList.
+func (in *MetalsoftMachineTemplateList) DeepCopy() *MetalsoftMachineTemplateList {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftMachineTemplateList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MetalsoftMachineTemplateList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftMachineTemplateResource) DeepCopyInto(out *MetalsoftMachineTemplateResource) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineTemplateResource.
+func (in *MetalsoftMachineTemplateResource) DeepCopy() *MetalsoftMachineTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftMachineTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalsoftMachineTemplateSpec) DeepCopyInto(out *MetalsoftMachineTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalsoftMachineTemplateSpec.
+func (in *MetalsoftMachineTemplateSpec) DeepCopy() *MetalsoftMachineTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalsoftMachineTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerTypeSelector) DeepCopyInto(out *ServerTypeSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerTypeSelector.
+func (in *ServerTypeSelector) DeepCopy() *ServerTypeSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerTypeSelector)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+
+// Conditions and condition Reasons for the MetalsoftCluster object.
+
+const (
+	// InfrastructureReadyCondition reports on the state of the MetalSoft
+	// infrastructure backing the cluster.
+	InfrastructureReadyCondition clusterv1.ConditionType = "InfrastructureReady"
+
+	// InfrastructureProvisionFailedReason used when the MetalSoft infrastructure
+	// could not be created, looked up or deployed.
+	InfrastructureProvisionFailedReason = "InfrastructureProvisionFailed"
+	// InfrastructureDeployingReason used when the MetalSoft infrastructure is being deployed.
+	InfrastructureDeployingReason = "InfrastructureDeploying"
+	// CredentialsUnavailableReason used when the MetalSoft credentials of the
+	// cluster cannot be read from its credentials Secret.
+	CredentialsUnavailableReason = "CredentialsUnavailable"
+	// NamespaceNotAllowedByIdentityReason used when the MetalsoftClusterIdentity
+	// referenced by the cluster does not allow the namespace of the cluster.
+	NamespaceNotAllowedByIdentityReason = "NamespaceNotAllowedByIdentity"
+	// WaitingForMachinesDeletionReason used when the MetalSoft infrastructure
+	// is not deleted yet because MetalsoftMachines of the cluster still exist.
+	WaitingForMachinesDeletionReason = "WaitingForMachinesDeletion"
+
+	// ControlPlaneEndpointReadyCondition reports whether the control plane
+	// endpoint of the cluster is known.
+	ControlPlaneEndpointReadyCondition clusterv1.ConditionType = "ControlPlaneEndpointReady"
+
+	// WaitingForControlPlaneEndpointReason used when spec.controlPlaneEndpoint.host is not set yet.
+	WaitingForControlPlaneEndpointReason = "WaitingForControlPlaneEndpoint"
+	// ControlPlaneEndpointProvisioningReason used while the MetalSoft load
+	// balancer or floating IP of the endpoint is being deployed.
+	ControlPlaneEndpointProvisioningReason = "ControlPlaneEndpointProvisioning"
+	// ControlPlaneEndpointProvisionFailedReason used when the MetalSoft load
+	// balancer or floating IP of the endpoint could not be allocated.
+	ControlPlaneEndpointProvisionFailedReason = "ControlPlaneEndpointProvisionFailed"
+
+	// NetworksReadyCondition reports on the state of the MetalSoft networks
+	// created for spec.networks.
+	NetworksReadyCondition clusterv1.ConditionType = "NetworksReady"
+
+	// NetworksProvisioningReason used while the MetalSoft networks of the
+	// cluster are being deployed.
+	NetworksProvisioningReason = "NetworksProvisioning"
+	// NetworksProvisionFailedReason used when a MetalSoft network of the
+	// cluster could not be created or adopted.
+	NetworksProvisionFailedReason = "NetworksProvisionFailed"
+
+	// FailureDomainsReadyCondition reports whether the racks of the failure
+	// domains of the cluster could be resolved.
+	FailureDomainsReadyCondition clusterv1.ConditionType = "FailureDomainsReady"
+
+	// FailureDomainsUnresolvedReason used when a rack or switch group of a
+	// failure domain does not exist in the datacenter.
+	FailureDomainsUnresolvedReason = "FailureDomainsUnresolved"
+)
+
+// Conditions and condition Reasons for the MetalsoftMachine object.
+
+const (
+	// InstanceReadyCondition reports on the state of the MetalSoft instance
+	// backing the machine.
+	InstanceReadyCondition clusterv1.ConditionType = "InstanceReady"
+
+	// WaitingForClusterInfrastructureReason used when the machine is waiting
+	// for the cluster infrastructure to be ready before provisioning.
+	WaitingForClusterInfrastructureReason = "WaitingForClusterInfrastructure"
+	// WaitingForBootstrapDataReason used when the machine is waiting for the
+	// bootstrap provider to generate its bootstrap data.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+	// InstanceProvisionFailedReason used when the MetalSoft instance array of
+	// the machine could not be created or deployed.
+	InstanceProvisionFailedReason = "InstanceProvisionFailed"
+	// InstanceDeployingReason used when the MetalSoft instance is being deployed.
+	InstanceDeployingReason = "InstanceDeploying"
+	// ControlPlaneBackendRegistrationFailedReason used when a control plane
+	// instance could not be registered with the control plane endpoint.
+	ControlPlaneBackendRegistrationFailedReason = "ControlPlaneBackendRegistrationFailed"
+	// WaitingForNetworksReason used when the machine is waiting for the
+	// networks of its network interfaces to be deployed.
+	WaitingForNetworksReason = "WaitingForNetworks"
+	// BootstrapDataTooLargeReason used when the bootstrap data of the machine
+	// exceeds what its bootstrap delivery method can carry.
+	BootstrapDataTooLargeReason = "BootstrapDataTooLarge"
+	// BootstrapFormatUnsupportedReason used when the OS template of the
+	// machine does not read bootstrap data of the format of its bootstrap
+	// Secret, e.g. Ignition on a cloud-init image.
+	BootstrapFormatUnsupportedReason = "BootstrapFormatUnsupported"
+
+	// IPAddressClaimedCondition reports whether the static addresses of the
+	// network interfaces have been allocated by their IPAM pools.
+	IPAddressClaimedCondition clusterv1.ConditionType = "IPAddressClaimed"
+
+	// WaitingForIPAddressReason used while an IPAddressClaim of the machine
+	// is not fulfilled by its pool yet.
+	WaitingForIPAddressReason = "WaitingForIPAddress"
+	// IPAddressInvalidReason used when an IPAddress allocated to the machine
+	// cannot be configured on its server.
+	IPAddressInvalidReason = "IPAddressInvalid"
+
+	// ServerSelectedCondition reports whether an available server matches
+	// the host selector and hardware requirements of the machine.
+	ServerSelectedCondition clusterv1.ConditionType = "ServerSelected"
+
+	// NoMatchingServerReason used when no available server of the datacenter
+	// satisfies the host selector and hardware requirements of the machine.
+	NoMatchingServerReason = "NoMatchingServer"
+)
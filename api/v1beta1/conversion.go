@@ -0,0 +1,50 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+// v1beta1 is the conversion hub and the storage version of all the
+// infrastructure types: older API versions convert to and from it.
+
+// Hub marks MetalsoftCluster as a conversion hub.
+func (*MetalsoftCluster) Hub() {}
+
+// Hub marks MetalsoftClusterList as a conversion hub.
+func (*MetalsoftClusterList) Hub() {}
+
+// Hub marks MetalsoftMachine as a conversion hub.
+func (*MetalsoftMachine) Hub() {}
+
+// Hub marks MetalsoftMachineList as a conversion hub.
+func (*MetalsoftMachineList) Hub() {}
+
+// Hub marks MetalsoftMachineTemplate as a conversion hub.
+func (*MetalsoftMachineTemplate) Hub() {}
+
+// Hub marks MetalsoftMachineTemplateList as a conversion hub.
+func (*MetalsoftMachineTemplateList) Hub() {}
+
+// Hub marks MetalsoftClusterTemplate as a conversion hub.
+func (*MetalsoftClusterTemplate) Hub() {}
+
+// Hub marks MetalsoftClusterTemplateList as a conversion hub.
+func (*MetalsoftClusterTemplateList) Hub() {}
+
+// Hub marks MetalsoftClusterIdentity as a conversion hub.
+func (*MetalsoftClusterIdentity) Hub() {}
+
+// Hub marks MetalsoftClusterIdentityList as a conversion hub.
+func (*MetalsoftClusterIdentityList) Hub() {}
@@ -0,0 +1,348 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+const (
+	// ClusterFinalizer allows MetalsoftClusterReconciler to clean up MetalSoft
+	// resources associated with MetalsoftCluster before removing it from the apiserver.
+	ClusterFinalizer = "metalsoftcluster.infrastructure.cluster.x-k8s.io"
+)
+
+// MetalsoftClusterSpec defines the desired state of MetalsoftCluster
+// +kubebuilder:validation:XValidation:rule="!(has(self.credentialsRef) && has(self.identityRef))",message="credentialsRef and identityRef are mutually exclusive"
+type MetalsoftClusterSpec struct {
+	// Datacenter is the label of the MetalSoft datacenter in which the
+	// infrastructure is created.
+	Datacenter string `json:"datacenter"`
+
+	// InfrastructureLabel is the label of the MetalSoft infrastructure backing
+	// the cluster. When empty, it defaults to the MetalsoftCluster name.
+	// +optional
+	InfrastructureLabel string `json:"infrastructureLabel,omitempty"`
+
+	// InfrastructureID is the ID of an existing MetalSoft infrastructure to adopt
+	// instead of creating a new one.
+	// +optional
+	InfrastructureID *int `json:"infrastructureID,omitempty"`
+
+	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
+	// +optional
+	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// ControlPlaneLoadBalancer makes the controller allocate the control plane
+	// endpoint in MetalSoft and fill in spec.controlPlaneEndpoint.host from it.
+	// When nil, spec.controlPlaneEndpoint.host must be provided.
+	// +optional
+	ControlPlaneLoadBalancer *ControlPlaneLoadBalancer `json:"controlPlaneLoadBalancer,omitempty"`
+
+	// Networks are the networks created in the MetalSoft infrastructure.
+	// Machines attach their interfaces to them by name through
+	// spec.networkInterfaces. A network named like one MetalSoft already
+	// created in the infrastructure, e.g. the default wan network, is adopted.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Networks []NetworkSpec `json:"networks,omitempty"`
+
+	// FailureDomains groups the server racks of the datacenter into failure
+	// domains that Cluster API spreads the machines over. When empty, the
+	// datacenter is the only failure domain, eligible for control plane
+	// machines.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	FailureDomains []FailureDomainSpec `json:"failureDomains,omitempty"`
+
+	// CredentialsRef is a reference to a Secret in the same namespace holding
+	// the MetalSoft API credentials used for this cluster under the endpoint,
+	// userEmail and apiKey keys. When neither CredentialsRef nor IdentityRef
+	// is set, the default credentials Secret of the controller is used.
+	// +optional
+	CredentialsRef *corev1.LocalObjectReference `json:"credentialsRef,omitempty"`
+
+	// IdentityRef is a reference to a MetalsoftClusterIdentity providing the
+	// MetalSoft API credentials used for this cluster. The identity must allow
+	// the namespace of the MetalsoftCluster.
+	// +optional
+	IdentityRef *MetalsoftClusterIdentityReference `json:"identityRef,omitempty"`
+}
+
+// NetworkType is the kind of a MetalSoft network.
+type NetworkType string
+
+const (
+	// NetworkTypeWAN is a network with public addresses routed to the internet.
+	NetworkTypeWAN = NetworkType("WAN")
+	// NetworkTypeLAN is a private network between the servers of the infrastructure.
+	NetworkTypeLAN = NetworkType("LAN")
+	// NetworkTypeSAN is a storage network connecting the servers to their
+	// iSCSI drive arrays.
+	NetworkTypeSAN = NetworkType("SAN")
+)
+
+// NetworkSpec describes a network of the MetalSoft infrastructure.
+type NetworkSpec struct {
+	// Name is the label of the network in the infrastructure and the name the
+	// machines of the cluster reference it by.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Type is the kind of network.
+	// +kubebuilder:validation:Enum=WAN;LAN;SAN
+	Type NetworkType `json:"type"`
+
+	// SubnetPool is the label of the MetalSoft subnet pool the subnet of the
+	// network is allocated from. When empty, the default pool of the
+	// datacenter for the network type is used.
+	// +optional
+	SubnetPool string `json:"subnetPool,omitempty"`
+
+	// PrefixLength is the size of the subnet allocated for the network, as a
+	// CIDR prefix length. When zero, MetalSoft picks the size.
+	// +kubebuilder:validation:Minimum=8
+	// +kubebuilder:validation:Maximum=30
+	// +optional
+	PrefixLength int `json:"prefixLength,omitempty"`
+
+	// VLANID is the VLAN requested for the network. When zero, MetalSoft
+	// allocates a free VLAN.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4094
+	// +optional
+	VLANID int `json:"vlanID,omitempty"`
+}
+
+// NetworkStatus reports a MetalSoft network created for spec.networks.
+type NetworkStatus struct {
+	// Name is the name of the network in spec.networks.
+	Name string `json:"name"`
+
+	// ID is the ID of the MetalSoft network.
+	ID int `json:"id"`
+
+	// Type is the kind of network.
+	Type NetworkType `json:"type"`
+}
+
+// FailureDomainSpec describes a failure domain made of server racks of the
+// datacenter.
+// +kubebuilder:validation:XValidation:rule="has(self.racks) || has(self.switchGroup)",message="one of racks or switchGroup must be set"
+type FailureDomainSpec struct {
+	// Name is the name of the failure domain referenced by
+	// Machine.spec.failureDomain.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Racks are the labels of the MetalSoft server racks in the failure domain.
+	// +optional
+	Racks []string `json:"racks,omitempty"`
+
+	// SwitchGroup adds all the server racks of a MetalSoft switch group to the
+	// failure domain.
+	// +optional
+	SwitchGroup string `json:"switchGroup,omitempty"`
+
+	// ControlPlane marks the failure domain as eligible for control plane
+	// machines.
+	// +optional
+	ControlPlane bool `json:"controlPlane,omitempty"`
+}
+
+// ControlPlaneLoadBalancerType is the kind of MetalSoft resource backing the
+// control plane endpoint.
+type ControlPlaneLoadBalancerType string
+
+const (
+	// ControlPlaneLoadBalancerTypeLoadBalancer uses a MetalSoft-managed load
+	// balancer forwarding to all the control plane machines.
+	ControlPlaneLoadBalancerTypeLoadBalancer = ControlPlaneLoadBalancerType("load-balancer")
+	// ControlPlaneLoadBalancerTypeFloatingIP uses a reserved WAN IP routed to
+	// one control plane machine at a time.
+	ControlPlaneLoadBalancerTypeFloatingIP = ControlPlaneLoadBalancerType("floating-ip")
+	// ControlPlaneLoadBalancerTypeKubeVIP uses an address reserved in the
+	// subnet of a WAN network, announced by a kube-vip static pod running on
+	// the control plane machines, for datacenters without a load balancer
+	// offering.
+	ControlPlaneLoadBalancerTypeKubeVIP = ControlPlaneLoadBalancerType("kube-vip")
+)
+
+// ControlPlaneLoadBalancer configures the MetalSoft resource backing the
+// control plane endpoint.
+// +kubebuilder:validation:XValidation:rule="!has(self.kubeVIP) || self.type == 'kube-vip'",message="kubeVIP requires type kube-vip"
+type ControlPlaneLoadBalancer struct {
+	// Type is the kind of MetalSoft resource allocated for the endpoint.
+	// +kubebuilder:validation:Enum=load-balancer;floating-ip;kube-vip
+	// +kubebuilder:default=load-balancer
+	// +optional
+	Type ControlPlaneLoadBalancerType `json:"type,omitempty"`
+
+	// KubeVIP configures the kube-vip static pod of the kube-vip type.
+	// +optional
+	KubeVIP *KubeVIPSpec `json:"kubeVIP,omitempty"`
+}
+
+// KubeVIPSpec configures the kube-vip static pod injected into the bootstrap
+// data of the control plane machines.
+type KubeVIPSpec struct {
+	// Image is the kube-vip container image. When empty, a kube-vip release
+	// known to work with the provider is used.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Interface is the network interface of the servers on which the
+	// endpoint address is announced. When empty, kube-vip uses the interface
+	// of the default route.
+	// +optional
+	Interface string `json:"interface,omitempty"`
+
+	// Network is the name of the WAN network of spec.networks whose subnet
+	// the endpoint address is reserved in. The control plane machines must
+	// be attached to it. When empty, the wan network of the infrastructure,
+	// which machines are attached to by default, is used.
+	// +optional
+	Network string `json:"network,omitempty"`
+}
+
+// ControlPlaneLoadBalancerStatus reports the MetalSoft resource allocated for
+// the control plane endpoint.
+type ControlPlaneLoadBalancerStatus struct {
+	// ID is the ID of the MetalSoft load balancer, floating IP or reserved
+	// IP.
+	ID int `json:"id"`
+
+	// Address is the WAN address allocated for the endpoint.
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// PrefixLength is the prefix length of the subnet the address of a
+	// kube-vip endpoint is reserved in.
+	// +optional
+	PrefixLength int `json:"prefixLength,omitempty"`
+}
+
+// MetalsoftClusterStatus defines the observed state of MetalsoftCluster
+type MetalsoftClusterStatus struct {
+	// Ready denotes that the MetalSoft infrastructure is deployed and the
+	// cluster is ready to receive machines.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// InfrastructureID is the ID of the MetalSoft infrastructure backing the cluster.
+	// +optional
+	InfrastructureID *int `json:"infrastructureID,omitempty"`
+
+	// InfrastructureCreated tells whether the MetalSoft infrastructure was
+	// created for the cluster. An infrastructure adopted through
+	// spec.infrastructureID or its label is not deleted with the cluster;
+	// only the networks and the control plane endpoint created for the
+	// cluster are removed from it.
+	// +optional
+	InfrastructureCreated bool `json:"infrastructureCreated,omitempty"`
+
+	// ControlPlaneLoadBalancer is the MetalSoft resource allocated for the
+	// control plane endpoint when spec.controlPlaneLoadBalancer is set.
+	// +optional
+	ControlPlaneLoadBalancer *ControlPlaneLoadBalancerStatus `json:"controlPlaneLoadBalancer,omitempty"`
+
+	// Networks are the MetalSoft networks created for spec.networks.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Networks []NetworkStatus `json:"networks,omitempty"`
+
+	// CreatedNetworkIDs are the IDs of the MetalSoft networks created for the
+	// cluster rather than adopted from the infrastructure. Only these are
+	// deleted from an adopted infrastructure.
+	// +optional
+	CreatedNetworkIDs []int `json:"createdNetworkIDs,omitempty"`
+
+	// FailureDomains are the failure domains of spec.failureDomains, or the
+	// datacenter when none are configured. The racks of a failure domain are
+	// listed in its racks attribute.
+	// +optional
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftCluster and will contain a succinct value suitable
+	// for machine interpretation.
+	// +optional
+	FailureReason *capierrors.ClusterStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftCluster and will contain a more verbose string suitable
+	// for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the MetalsoftCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=metalsoftclusters,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this MetalsoftCluster belongs"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready",description="Cluster infrastructure is ready for MetalSoft instances"
+//+kubebuilder:printcolumn:name="Datacenter",type="string",JSONPath=".spec.datacenter",description="MetalSoft datacenter"
+//+kubebuilder:printcolumn:name="Infrastructure",type="integer",JSONPath=".status.infrastructureID",description="MetalSoft infrastructure ID"
+//+kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="API Endpoint",priority=1
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MetalsoftCluster is the Schema for the metalsoftclusters API
+type MetalsoftCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetalsoftClusterSpec   `json:"spec,omitempty"`
+	Status MetalsoftClusterStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalsoftClusterList contains a list of MetalsoftCluster
+type MetalsoftClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalsoftCluster `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the MetalsoftCluster resource.
+func (c *MetalsoftCluster) GetConditions() clusterv1.Conditions {
+	return c.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MetalsoftCluster to the predescribed clusterv1.Conditions.
+func (c *MetalsoftCluster) SetConditions(conditions clusterv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalsoftCluster{}, &MetalsoftClusterList{})
+}
@@ -0,0 +1,166 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultAPIServerPort is the port of the control plane endpoint when
+// spec.controlPlaneEndpoint.port is not set.
+const DefaultAPIServerPort = 6443
+
+// datacenterRegex matches MetalSoft datacenter labels, e.g. us-chi-qts01-dc.
+var datacenterRegex = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?$`)
+
+// log is for logging in this package.
+var metalsoftclusterlog = logf.Log.WithName("metalsoftcluster-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *MetalsoftCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-metalsoftcluster,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters,verbs=create;update,versions=v1beta1,name=mmetalsoftcluster.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &MetalsoftCluster{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *MetalsoftCluster) Default() {
+	metalsoftclusterlog.Info("default", "name", r.Name)
+
+	if r.Spec.ControlPlaneEndpoint.Port == 0 {
+		r.Spec.ControlPlaneEndpoint.Port = DefaultAPIServerPort
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-metalsoftcluster,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters,verbs=create;update,versions=v1beta1,name=vmetalsoftcluster.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &MetalsoftCluster{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftCluster) ValidateCreate() (admission.Warnings, error) {
+	metalsoftclusterlog.Info("validate create", "name", r.Name)
+
+	return nil, r.validate(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftCluster) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	metalsoftclusterlog.Info("validate update", "name", r.Name)
+
+	oldCluster, ok := old.(*MetalsoftCluster)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a MetalsoftCluster but got a %T", old))
+	}
+
+	allErrs := r.validateSpec()
+	spec := field.NewPath("spec")
+	if r.Spec.Datacenter != oldCluster.Spec.Datacenter {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("datacenter"), "field is immutable"))
+	}
+	if r.Spec.InfrastructureLabel != oldCluster.Spec.InfrastructureLabel {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("infrastructureLabel"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.InfrastructureID, oldCluster.Spec.InfrastructureID) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("infrastructureID"), "field is immutable"))
+	}
+	// The endpoint may be filled in once, by the user or the controller, but
+	// not moved afterwards as it is baked into the cluster certificates.
+	if oldCluster.Spec.ControlPlaneEndpoint.Host != "" && r.Spec.ControlPlaneEndpoint != oldCluster.Spec.ControlPlaneEndpoint {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("controlPlaneEndpoint"), "field is immutable once set"))
+	}
+	if !reflect.DeepEqual(r.Spec.ControlPlaneLoadBalancer, oldCluster.Spec.ControlPlaneLoadBalancer) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("controlPlaneLoadBalancer"), "field is immutable"))
+	}
+	// Networks may be added to a running cluster, but existing ones are in
+	// use by the machines and cannot be changed or removed.
+	networks := map[string]NetworkSpec{}
+	for _, network := range r.Spec.Networks {
+		networks[network.Name] = network
+	}
+	for i, oldNetwork := range oldCluster.Spec.Networks {
+		if network, ok := networks[oldNetwork.Name]; !ok || network != oldNetwork {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("networks").Index(i), "existing networks are immutable"))
+		}
+	}
+	return nil, r.validate(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftCluster) ValidateDelete() (admission.Warnings, error) {
+	metalsoftclusterlog.Info("validate delete", "name", r.Name)
+
+	return nil, nil
+}
+
+func (r *MetalsoftCluster) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+	if !datacenterRegex.MatchString(r.Spec.Datacenter) {
+		allErrs = append(allErrs, field.Invalid(spec.Child("datacenter"), r.Spec.Datacenter,
+			"must consist of lower case alphanumeric characters or '-', and must start and end with an alphanumeric character"))
+	}
+	if port := r.Spec.ControlPlaneEndpoint.Port; port < 0 || port > 65535 {
+		allErrs = append(allErrs, field.Invalid(spec.Child("controlPlaneEndpoint", "port"), port, "must be a valid port number"))
+	}
+
+	networkTypes := map[string]NetworkType{}
+	for i, network := range r.Spec.Networks {
+		if _, ok := networkTypes[network.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(spec.Child("networks").Index(i).Child("name"), network.Name))
+		}
+		networkTypes[network.Name] = network.Type
+	}
+	if loadBalancer := r.Spec.ControlPlaneLoadBalancer; loadBalancer != nil && loadBalancer.KubeVIP != nil && loadBalancer.KubeVIP.Network != "" {
+		if networkTypes[loadBalancer.KubeVIP.Network] != NetworkTypeWAN {
+			allErrs = append(allErrs, field.Invalid(spec.Child("controlPlaneLoadBalancer", "kubeVIP", "network"), loadBalancer.KubeVIP.Network,
+				"must be the name of a WAN network of spec.networks"))
+		}
+	}
+
+	failureDomains := map[string]bool{}
+	for i, failureDomain := range r.Spec.FailureDomains {
+		if failureDomains[failureDomain.Name] {
+			allErrs = append(allErrs, field.Duplicate(spec.Child("failureDomains").Index(i).Child("name"), failureDomain.Name))
+		}
+		failureDomains[failureDomain.Name] = true
+		if len(failureDomain.Racks) == 0 && failureDomain.SwitchGroup == "" {
+			allErrs = append(allErrs, field.Required(spec.Child("failureDomains").Index(i), "one of racks or switchGroup must be set"))
+		}
+	}
+	return allErrs
+}
+
+func (r *MetalsoftCluster) validate(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("MetalsoftCluster").GroupKind(), r.Name, allErrs)
+}
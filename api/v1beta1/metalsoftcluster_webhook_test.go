@@ -0,0 +1,262 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+func TestMetalsoftClusterDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	cluster := &MetalsoftCluster{Spec: MetalsoftClusterSpec{Datacenter: "us-chi-qts01-dc"}}
+	cluster.Default()
+	g.Expect(cluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(DefaultAPIServerPort))
+
+	cluster.Spec.ControlPlaneEndpoint.Port = 443
+	cluster.Default()
+	g.Expect(cluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(443))
+}
+
+func TestMetalsoftClusterValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    MetalsoftClusterSpec
+		wantErr bool
+	}{
+		{
+			name: "valid",
+			spec: MetalsoftClusterSpec{Datacenter: "us-chi-qts01-dc"},
+		},
+		{
+			name:    "missing datacenter",
+			spec:    MetalsoftClusterSpec{},
+			wantErr: true,
+		},
+		{
+			name:    "malformed datacenter",
+			spec:    MetalsoftClusterSpec{Datacenter: "US_Chicago"},
+			wantErr: true,
+		},
+		{
+			name: "port out of range",
+			spec: MetalsoftClusterSpec{
+				Datacenter:           "us-chi-qts01-dc",
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 70000},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate network names",
+			spec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				Networks: []NetworkSpec{
+					{Name: "private", Type: NetworkTypeLAN},
+					{Name: "private", Type: NetworkTypeSAN},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "kube-vip on a WAN network",
+			spec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				Networks:   []NetworkSpec{{Name: "public", Type: NetworkTypeWAN}},
+				ControlPlaneLoadBalancer: &ControlPlaneLoadBalancer{
+					Type:    ControlPlaneLoadBalancerTypeKubeVIP,
+					KubeVIP: &KubeVIPSpec{Network: "public"},
+				},
+			},
+		},
+		{
+			name: "kube-vip on a LAN network",
+			spec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				Networks:   []NetworkSpec{{Name: "private", Type: NetworkTypeLAN}},
+				ControlPlaneLoadBalancer: &ControlPlaneLoadBalancer{
+					Type:    ControlPlaneLoadBalancerTypeKubeVIP,
+					KubeVIP: &KubeVIPSpec{Network: "private"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "failure domains",
+			spec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				FailureDomains: []FailureDomainSpec{
+					{Name: "row-a", Racks: []string{"a1", "a2"}, ControlPlane: true},
+					{Name: "row-b", SwitchGroup: "b"},
+				},
+			},
+		},
+		{
+			name: "failure domain without racks",
+			spec: MetalsoftClusterSpec{
+				Datacenter:     "us-chi-qts01-dc",
+				FailureDomains: []FailureDomainSpec{{Name: "row-a"}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			cluster := &MetalsoftCluster{Spec: tt.spec}
+			_, err := cluster.ValidateCreate()
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestMetalsoftClusterValidateUpdate(t *testing.T) {
+	old := MetalsoftClusterSpec{
+		Datacenter:          "us-chi-qts01-dc",
+		InfrastructureLabel: "workload",
+	}
+	tests := []struct {
+		name    string
+		oldSpec MetalsoftClusterSpec
+		update  func(spec *MetalsoftClusterSpec)
+		wantErr bool
+	}{
+		{
+			name:    "unchanged",
+			oldSpec: old,
+			update:  func(spec *MetalsoftClusterSpec) {},
+		},
+		{
+			name:    "datacenter changed",
+			oldSpec: old,
+			update:  func(spec *MetalsoftClusterSpec) { spec.Datacenter = "us-nyc-dc" },
+			wantErr: true,
+		},
+		{
+			name:    "infrastructure label changed",
+			oldSpec: old,
+			update:  func(spec *MetalsoftClusterSpec) { spec.InfrastructureLabel = "other" },
+			wantErr: true,
+		},
+		{
+			name:    "infrastructure adopted",
+			oldSpec: old,
+			update:  func(spec *MetalsoftClusterSpec) { spec.InfrastructureID = pointer.Int(42) },
+			wantErr: true,
+		},
+		{
+			name:    "endpoint set",
+			oldSpec: old,
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443}
+			},
+		},
+		{
+			name: "endpoint moved",
+			oldSpec: MetalsoftClusterSpec{
+				Datacenter:           "us-chi-qts01-dc",
+				ControlPlaneEndpoint: clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443},
+			},
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.ControlPlaneEndpoint.Host = "10.0.0.2"
+			},
+			wantErr: true,
+		},
+		{
+			name:    "control plane load balancer added",
+			oldSpec: old,
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.ControlPlaneLoadBalancer = &ControlPlaneLoadBalancer{Type: ControlPlaneLoadBalancerTypeLoadBalancer}
+			},
+			wantErr: true,
+		},
+		{
+			name: "control plane load balancer type changed",
+			oldSpec: MetalsoftClusterSpec{
+				Datacenter:               "us-chi-qts01-dc",
+				ControlPlaneLoadBalancer: &ControlPlaneLoadBalancer{Type: ControlPlaneLoadBalancerTypeLoadBalancer},
+			},
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.ControlPlaneLoadBalancer.Type = ControlPlaneLoadBalancerTypeFloatingIP
+			},
+			wantErr: true,
+		},
+		{
+			name: "endpoint filled in from the control plane load balancer",
+			oldSpec: MetalsoftClusterSpec{
+				Datacenter:               "us-chi-qts01-dc",
+				ControlPlaneLoadBalancer: &ControlPlaneLoadBalancer{Type: ControlPlaneLoadBalancerTypeFloatingIP},
+			},
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "192.0.2.10", Port: 6443}
+			},
+		},
+		{
+			name:    "network added",
+			oldSpec: old,
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.Networks = append(spec.Networks, NetworkSpec{Name: "storage", Type: NetworkTypeSAN})
+			},
+		},
+		{
+			name: "network changed",
+			oldSpec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				Networks:   []NetworkSpec{{Name: "private", Type: NetworkTypeLAN}},
+			},
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.Networks[0].VLANID = 100
+			},
+			wantErr: true,
+		},
+		{
+			name: "network removed",
+			oldSpec: MetalsoftClusterSpec{
+				Datacenter: "us-chi-qts01-dc",
+				Networks:   []NetworkSpec{{Name: "private", Type: NetworkTypeLAN}},
+			},
+			update: func(spec *MetalsoftClusterSpec) {
+				spec.Networks = nil
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldCluster := &MetalsoftCluster{Spec: tt.oldSpec}
+			cluster := oldCluster.DeepCopy()
+			tt.update(&cluster.Spec)
+			_, err := cluster.ValidateUpdate(oldCluster)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Labels set on MetalsoftHosts from the MetalSoft server inventory, so
+// machines can select hosts by location and server type.
+const (
+	// HostDatacenterLabel is the label of the MetalSoft datacenter of a host.
+	HostDatacenterLabel = "metalsoft.infrastructure.cluster.x-k8s.io/datacenter"
+	// HostServerTypeLabel is the name of the MetalSoft server type of a host.
+	HostServerTypeLabel = "metalsoft.infrastructure.cluster.x-k8s.io/server-type"
+	// HostRackLabel is the label of the MetalSoft server rack of a host.
+	HostRackLabel = "metalsoft.infrastructure.cluster.x-k8s.io/rack"
+)
+
+// HostInventoryLabel identifies the MetalSoft account whose server inventory
+// a host was last mirrored from. Clusters of a namespace with different
+// credentials may see different inventories, so only the hosts of an
+// account are deleted when their servers leave its inventory.
+const HostInventoryLabel = "metalsoft.infrastructure.cluster.x-k8s.io/inventory"
+
+// PowerState is the power state of a MetalSoft server.
+type PowerState string
+
+// Power states of MetalSoft servers.
+const (
+	PowerStateOn  = PowerState("On")
+	PowerStateOff = PowerState("Off")
+)
+
+// AllocationState tells whether a MetalSoft server is allocated to an instance.
+type AllocationState string
+
+// Allocation states of MetalSoft servers.
+const (
+	// AllocationStateAvailable is the state of free servers.
+	AllocationStateAvailable = AllocationState("Available")
+	// AllocationStateReserved is the state of servers reserved for a
+	// machine that is not deployed yet.
+	AllocationStateReserved = AllocationState("Reserved")
+	// AllocationStateAllocated is the state of servers allocated to an
+	// instance, whether by Cluster API or not.
+	AllocationStateAllocated = AllocationState("Allocated")
+	// AllocationStateGone is the state of servers removed from the MetalSoft
+	// inventory while their host is claimed by a machine. The host is
+	// deleted once the machine releases it.
+	AllocationStateGone = AllocationState("Gone")
+)
+
+// MetalsoftHostSpec defines the desired state of MetalsoftHost
+type MetalsoftHostSpec struct {
+	// ServerID is the ID of the MetalSoft server.
+	// +kubebuilder:validation:Minimum=1
+	ServerID int `json:"serverID"`
+
+	// ConsumerRef is the MetalsoftMachine the server is claimed by. Machines
+	// claim a host by setting it with an update conditional on the
+	// resourceVersion of the host, so only one of them can succeed.
+	// +optional
+	ConsumerRef *corev1.ObjectReference `json:"consumerRef,omitempty"`
+}
+
+// HardwareDetails is the hardware inventory of a MetalSoft server.
+type HardwareDetails struct {
+	// CPU describes the processors of the server.
+	CPU CPU `json:"cpu"`
+
+	// RAMGB is the amount of RAM, in gigabytes.
+	RAMGB int `json:"ramGB"`
+
+	// Disks are the local disks of the server.
+	// +optional
+	Disks []Disk `json:"disks,omitempty"`
+
+	// NICs are the network interfaces of the server.
+	// +optional
+	NICs []NIC `json:"nics,omitempty"`
+}
+
+// CPU describes the processors of a server.
+type CPU struct {
+	// Model is the processor model.
+	// +optional
+	Model string `json:"model,omitempty"`
+
+	// Count is the number of processors.
+	Count int `json:"count"`
+
+	// Cores is the total number of processor cores.
+	Cores int `json:"cores"`
+}
+
+// Disk is a local disk of a server.
+type Disk struct {
+	// SizeGB is the size of the disk, in gigabytes.
+	SizeGB int `json:"sizeGB"`
+
+	// Type is the type of the disk, one of hdd, ssd or nvme.
+	Type string `json:"type"`
+}
+
+// NIC is a network interface of a server.
+type NIC struct {
+	// MACAddress is the MAC address of the interface.
+	MACAddress string `json:"macAddress"`
+
+	// SpeedMbps is the speed of the interface, in megabits per second.
+	SpeedMbps int `json:"speedMbps"`
+}
+
+// MetalsoftHostStatus defines the observed state of MetalsoftHost
+type MetalsoftHostStatus struct {
+	// Datacenter is the label of the MetalSoft datacenter of the server.
+	// +optional
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// ServerType is the name of the MetalSoft server type of the server.
+	// +optional
+	ServerType string `json:"serverType,omitempty"`
+
+	// Rack is the label of the MetalSoft server rack hosting the server.
+	// +optional
+	Rack string `json:"rack,omitempty"`
+
+	// SerialNumber is the serial number of the server.
+	// +optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// Hardware is the hardware inventory of the server.
+	// +optional
+	Hardware *HardwareDetails `json:"hardware,omitempty"`
+
+	// PowerState is the power state of the server.
+	// +optional
+	PowerState PowerState `json:"powerState,omitempty"`
+
+	// AllocationState tells whether the server is allocated to an instance.
+	// +optional
+	AllocationState AllocationState `json:"allocationState,omitempty"`
+
+	// InstanceID is the ID of the MetalSoft instance the server is allocated to.
+	// +optional
+	InstanceID *int `json:"instanceID,omitempty"`
+
+	// LastUpdated is when the status was last refreshed from MetalSoft.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=metalsofthosts,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Server",type="integer",JSONPath=".spec.serverID",description="MetalSoft server ID"
+//+kubebuilder:printcolumn:name="Type",type="string",JSONPath=".status.serverType",description="MetalSoft server type"
+//+kubebuilder:printcolumn:name="Rack",type="string",JSONPath=".status.rack",description="MetalSoft server rack"
+//+kubebuilder:printcolumn:name="Power",type="string",JSONPath=".status.powerState",description="Server power state"
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.allocationState",description="Server allocation state"
+//+kubebuilder:printcolumn:name="Consumer",type="string",JSONPath=".spec.consumerRef.name",description="MetalsoftMachine claiming the server"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MetalsoftHost is the Schema for the metalsofthosts API. It mirrors a
+// bare-metal server of the MetalSoft inventory of the datacenter of a
+// MetalsoftCluster of its namespace.
+type MetalsoftHost struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetalsoftHostSpec   `json:"spec,omitempty"`
+	Status MetalsoftHostStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalsoftHostList contains a list of MetalsoftHost
+type MetalsoftHostList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalsoftHost `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalsoftHost{}, &MetalsoftHostList{})
+}
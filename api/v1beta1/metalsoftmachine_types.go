@@ -0,0 +1,312 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+)
+
+const (
+	// MachineFinalizer allows MetalsoftMachineReconciler to clean up MetalSoft
+	// resources associated with MetalsoftMachine before removing it from the apiserver.
+	MachineFinalizer = "metalsoftmachine.infrastructure.cluster.x-k8s.io"
+
+	// BootstrapDataCustomVariable is the MetalSoft custom variable holding the
+	// bootstrap data of machines using the custom-variables delivery.
+	BootstrapDataCustomVariable = "cluster_api_bootstrap_data"
+)
+
+// InstanceState describes the state of a MetalSoft instance.
+type InstanceState string
+
+var (
+	// InstanceStateOrdered is the state of an instance that has been created
+	// in the infrastructure but not yet deployed.
+	InstanceStateOrdered = InstanceState("ordered")
+
+	// InstanceStateActive is the state of a deployed, running instance.
+	InstanceStateActive = InstanceState("active")
+
+	// InstanceStateStopped is the state of a deployed instance that has been powered off.
+	InstanceStateStopped = InstanceState("stopped")
+
+	// InstanceStateDeleted is the state of an instance that has been deleted.
+	InstanceStateDeleted = InstanceState("deleted")
+)
+
+// BootstrapDeliveryMethod is how the bootstrap data of a machine is handed to
+// the OS installed on its server.
+type BootstrapDeliveryMethod string
+
+const (
+	// BootstrapDeliveryCloudInit passes the bootstrap data as the cloud-init
+	// user data of the OS template.
+	BootstrapDeliveryCloudInit = BootstrapDeliveryMethod("cloud-init")
+	// BootstrapDeliveryCustomVariables passes the bootstrap data, gzipped and
+	// base64 encoded, as the cluster_api_bootstrap_data custom variable, for
+	// OS templates rendering it themselves.
+	BootstrapDeliveryCustomVariables = BootstrapDeliveryMethod("custom-variables")
+	// BootstrapDeliveryConfigDrive attaches a config drive ISO holding the
+	// bootstrap data as OpenStack user data, for larger bootstrap data and OS
+	// images reading their configuration from a config drive.
+	BootstrapDeliveryConfigDrive = BootstrapDeliveryMethod("config-drive")
+)
+
+// DriveArraySpec describes an additional MetalSoft drive array attached to the machine.
+type DriveArraySpec struct {
+	// Label is the label of the drive array within the infrastructure.
+	Label string `json:"label"`
+
+	// SizeGB is the size of each drive in the array, in gigabytes.
+	// +kubebuilder:validation:Minimum=1
+	SizeGB int `json:"sizeGB"`
+
+	// StorageType is the MetalSoft storage type of the drive array.
+	// +kubebuilder:validation:Enum=iscsi_ssd;iscsi_hdd
+	// +optional
+	StorageType string `json:"storageType,omitempty"`
+}
+
+// MetalsoftMachineSpec defines the desired state of MetalsoftMachine
+type MetalsoftMachineSpec struct {
+	// ProviderID is the unique identifier as specified by the cloud provider.
+	// +optional
+	ProviderID *string `json:"providerID,omitempty"`
+
+	// ServerType is the label of the MetalSoft server type to provision,
+	// e.g. M.16.64.v2. One of ServerType, ServerTypeSelector, HostSelector
+	// or HardwareRequirements must be set.
+	// +optional
+	ServerType string `json:"serverType,omitempty"`
+
+	// ServerTypeSelector selects the smallest MetalSoft server type satisfying
+	// the given minimums when ServerType is not set.
+	// +optional
+	ServerTypeSelector *ServerTypeSelector `json:"serverTypeSelector,omitempty"`
+
+	// HostSelector selects the MetalsoftHost whose server is allocated to the
+	// machine among the available hosts of its namespace in the datacenter of
+	// the cluster. When ServerType is set too, only hosts of that server type
+	// are selected.
+	// +optional
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+
+	// HardwareRequirements selects the smallest available server of the
+	// datacenter of the cluster with the given hardware. When HostSelector or
+	// ServerType is set too, only the servers they select are considered.
+	// +optional
+	HardwareRequirements *HardwareRequirements `json:"hardwareRequirements,omitempty"`
+
+	// OSTemplate is the label of the MetalSoft OS template installed on the
+	// boot drive, e.g. ubuntu-22-04.
+	OSTemplate string `json:"osTemplate"`
+
+	// BootDriveSizeGB is the size of the boot drive, in gigabytes.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	BootDriveSizeGB int `json:"bootDriveSizeGB,omitempty"`
+
+	// AdditionalDriveArrays are extra drive arrays attached to the machine.
+	// +optional
+	AdditionalDriveArrays []DriveArraySpec `json:"additionalDriveArrays,omitempty"`
+
+	// NetworkInterfaces attaches the network interfaces of the server to
+	// networks of the cluster. When empty, MetalSoft attaches the first
+	// interface to the WAN network of the infrastructure.
+	// +listType=map
+	// +listMapKey=index
+	// +optional
+	NetworkInterfaces []NetworkInterfaceSpec `json:"networkInterfaces,omitempty"`
+
+	// SSHKeyIDs are the IDs of MetalSoft user SSH keys installed on the machine.
+	// +optional
+	SSHKeyIDs []int `json:"sshKeyIDs,omitempty"`
+
+	// CustomVariables are passed to the OS template as MetalSoft custom variables.
+	// +optional
+	CustomVariables map[string]string `json:"customVariables,omitempty"`
+
+	// BootstrapDelivery is how the bootstrap data is handed to the OS,
+	// cloud-init when not set. Each method limits the size of the data.
+	// +kubebuilder:validation:Enum=cloud-init;custom-variables;config-drive
+	// +optional
+	BootstrapDelivery BootstrapDeliveryMethod `json:"bootstrapDelivery,omitempty"`
+}
+
+// Placement describes where in MetalSoft the server of a machine is located.
+type Placement struct {
+	// Datacenter is the label of the MetalSoft datacenter of the server.
+	Datacenter string `json:"datacenter"`
+
+	// FailureDomain is the failure domain the server was selected in.
+	// +optional
+	FailureDomain string `json:"failureDomain,omitempty"`
+
+	// Rack is the label of the MetalSoft server rack hosting the server.
+	// +optional
+	Rack string `json:"rack,omitempty"`
+}
+
+// NetworkInterfaceSpec attaches a network interface of the server to a network.
+type NetworkInterfaceSpec struct {
+	// Index is the index of the network interface of the server, starting at 0.
+	// +kubebuilder:validation:Minimum=0
+	Index int `json:"index"`
+
+	// Network is the name of the network the interface is attached to, either
+	// one of spec.networks of the MetalsoftCluster or a network MetalSoft
+	// created in the infrastructure, e.g. wan.
+	// +kubebuilder:validation:MinLength=1
+	Network string `json:"network"`
+
+	// AddressesFromPools are the IPAM pools, like InClusterIPPools, the
+	// static addresses of the interface are claimed from, one per pool.
+	// The addresses are configured by MetalSoft and cloud-init instead of
+	// addresses allocated from the subnets of the network.
+	// +optional
+	AddressesFromPools []corev1.TypedLocalObjectReference `json:"addressesFromPools,omitempty"`
+}
+
+// ServerTypeSelector describes the minimum hardware of a MetalSoft server type.
+type ServerTypeSelector struct {
+	// MinCores is the minimum number of processor cores.
+	// +optional
+	MinCores int `json:"minCores,omitempty"`
+
+	// MinRAMGB is the minimum amount of RAM, in gigabytes.
+	// +optional
+	MinRAMGB int `json:"minRAMGB,omitempty"`
+}
+
+// HardwareRequirements describes the minimum hardware of a MetalSoft server.
+type HardwareRequirements struct {
+	// MinCores is the minimum number of processor cores.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCores int `json:"minCores,omitempty"`
+
+	// MinRAMGB is the minimum amount of RAM, in gigabytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinRAMGB int `json:"minRAMGB,omitempty"`
+
+	// DiskType restricts MinDisks to the disks of the given type. At least
+	// one disk of that type is required.
+	// +kubebuilder:validation:Enum=hdd;ssd;nvme
+	// +optional
+	DiskType string `json:"diskType,omitempty"`
+
+	// MinDisks is the minimum number of local disks.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinDisks int `json:"minDisks,omitempty"`
+
+	// MinNICSpeedMbps is the minimum speed of at least one network
+	// interface, in megabits per second.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinNICSpeedMbps int `json:"minNICSpeedMbps,omitempty"`
+}
+
+// MetalsoftMachineStatus defines the observed state of MetalsoftMachine
+type MetalsoftMachineStatus struct {
+	// Ready denotes that the machine's server is deployed and running.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Addresses contains the MetalSoft instance associated addresses.
+	// +optional
+	Addresses []clusterv1.MachineAddress `json:"addresses,omitempty"`
+
+	// InstanceArrayID is the ID of the MetalSoft instance array backing the machine.
+	// +optional
+	InstanceArrayID *int `json:"instanceArrayID,omitempty"`
+
+	// InstanceID is the ID of the MetalSoft instance backing the machine.
+	// +optional
+	InstanceID *int `json:"instanceID,omitempty"`
+
+	// InstanceState is the state of the MetalSoft instance backing the machine.
+	// +optional
+	InstanceState *InstanceState `json:"instanceState,omitempty"`
+
+	// Placement reports where the server of the machine was allocated.
+	// +optional
+	Placement *Placement `json:"placement,omitempty"`
+
+	// FailureReason will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftMachine and will contain a succinct value suitable
+	// for machine interpretation.
+	// +optional
+	FailureReason *capierrors.MachineStatusError `json:"failureReason,omitempty"`
+
+	// FailureMessage will be set in the event that there is a terminal problem
+	// reconciling the MetalsoftMachine and will contain a more verbose string suitable
+	// for logging and human consumption.
+	// +optional
+	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the MetalsoftMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:path=metalsoftmachines,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this MetalsoftMachine belongs"
+//+kubebuilder:printcolumn:name="State",type="string",JSONPath=".status.instanceState",description="MetalSoft instance state"
+//+kubebuilder:printcolumn:name="Ready",type="boolean",JSONPath=".status.ready",description="Machine ready status"
+//+kubebuilder:printcolumn:name="InstanceID",type="string",JSONPath=".spec.providerID",description="MetalSoft instance ID"
+//+kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".metadata.ownerReferences[?(@.kind==\"Machine\")].name",description="Machine object which owns with this MetalsoftMachine"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MetalsoftMachine is the Schema for the metalsoftmachines API
+type MetalsoftMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   MetalsoftMachineSpec   `json:"spec,omitempty"`
+	Status MetalsoftMachineStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalsoftMachineList contains a list of MetalsoftMachine
+type MetalsoftMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalsoftMachine `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the MetalsoftMachine resource.
+func (m *MetalsoftMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the MetalsoftMachine to the predescribed clusterv1.Conditions.
+func (m *MetalsoftMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalsoftMachine{}, &MetalsoftMachineList{})
+}
@@ -0,0 +1,185 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultStorageType is the MetalSoft storage type of additional drive arrays
+// that do not set one.
+const DefaultStorageType = "iscsi_ssd"
+
+// log is for logging in this package.
+var metalsoftmachinelog = logf.Log.WithName("metalsoftmachine-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *MetalsoftMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-infrastructure-cluster-x-k8s-io-v1beta1-metalsoftmachine,mutating=true,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=metalsoftmachines,verbs=create;update,versions=v1beta1,name=mmetalsoftmachine.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &MetalsoftMachine{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *MetalsoftMachine) Default() {
+	metalsoftmachinelog.Info("default", "name", r.Name)
+
+	for i := range r.Spec.AdditionalDriveArrays {
+		if r.Spec.AdditionalDriveArrays[i].StorageType == "" {
+			r.Spec.AdditionalDriveArrays[i].StorageType = DefaultStorageType
+		}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-infrastructure-cluster-x-k8s-io-v1beta1-metalsoftmachine,mutating=false,failurePolicy=fail,sideEffects=None,groups=infrastructure.cluster.x-k8s.io,resources=metalsoftmachines,verbs=create;update,versions=v1beta1,name=vmetalsoftmachine.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &MetalsoftMachine{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftMachine) ValidateCreate() (admission.Warnings, error) {
+	metalsoftmachinelog.Info("validate create", "name", r.Name)
+
+	return nil, r.validate(r.validateSpec())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftMachine) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	metalsoftmachinelog.Info("validate update", "name", r.Name)
+
+	oldMachine, ok := old.(*MetalsoftMachine)
+	if !ok {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("expected a MetalsoftMachine but got a %T", old))
+	}
+
+	allErrs := r.validateSpec()
+	spec := field.NewPath("spec")
+	if r.Spec.ServerType != oldMachine.Spec.ServerType {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("serverType"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.ServerTypeSelector, oldMachine.Spec.ServerTypeSelector) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("serverTypeSelector"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.HostSelector, oldMachine.Spec.HostSelector) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("hostSelector"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.HardwareRequirements, oldMachine.Spec.HardwareRequirements) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("hardwareRequirements"), "field is immutable"))
+	}
+	if r.Spec.OSTemplate != oldMachine.Spec.OSTemplate {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("osTemplate"), "field is immutable"))
+	}
+	if r.Spec.BootDriveSizeGB != oldMachine.Spec.BootDriveSizeGB {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("bootDriveSizeGB"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.AdditionalDriveArrays, oldMachine.Spec.AdditionalDriveArrays) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("additionalDriveArrays"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.NetworkInterfaces, oldMachine.Spec.NetworkInterfaces) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("networkInterfaces"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.SSHKeyIDs, oldMachine.Spec.SSHKeyIDs) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("sshKeyIDs"), "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.CustomVariables, oldMachine.Spec.CustomVariables) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("customVariables"), "field is immutable"))
+	}
+	if r.Spec.BootstrapDelivery != oldMachine.Spec.BootstrapDelivery {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("bootstrapDelivery"), "field is immutable"))
+	}
+	if oldMachine.Spec.ProviderID != nil && !reflect.DeepEqual(r.Spec.ProviderID, oldMachine.Spec.ProviderID) {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("providerID"), "field is immutable once set"))
+	}
+	return nil, r.validate(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *MetalsoftMachine) ValidateDelete() (admission.Warnings, error) {
+	metalsoftmachinelog.Info("validate delete", "name", r.Name)
+
+	return nil, nil
+}
+
+func (r *MetalsoftMachine) validateSpec() field.ErrorList {
+	var allErrs field.ErrorList
+	spec := field.NewPath("spec")
+	switch {
+	case r.Spec.ServerType == "" && r.Spec.ServerTypeSelector == nil && r.Spec.HostSelector == nil && r.Spec.HardwareRequirements == nil:
+		allErrs = append(allErrs, field.Required(spec.Child("serverType"), "one of serverType, serverTypeSelector, hostSelector or hardwareRequirements must be set"))
+	case r.Spec.ServerType != "" && r.Spec.ServerTypeSelector != nil:
+		allErrs = append(allErrs, field.Forbidden(spec.Child("serverTypeSelector"), "cannot be set together with serverType"))
+	case r.Spec.HostSelector != nil && r.Spec.ServerTypeSelector != nil:
+		allErrs = append(allErrs, field.Forbidden(spec.Child("serverTypeSelector"), "cannot be set together with hostSelector"))
+	case r.Spec.HardwareRequirements != nil && r.Spec.ServerTypeSelector != nil:
+		allErrs = append(allErrs, field.Forbidden(spec.Child("serverTypeSelector"), "cannot be set together with hardwareRequirements"))
+	}
+	if r.Spec.HostSelector != nil {
+		if _, err := metav1.LabelSelectorAsSelector(r.Spec.HostSelector); err != nil {
+			allErrs = append(allErrs, field.Invalid(spec.Child("hostSelector"), r.Spec.HostSelector, err.Error()))
+		}
+	}
+	if r.Spec.OSTemplate == "" {
+		allErrs = append(allErrs, field.Required(spec.Child("osTemplate"), ""))
+	}
+	if _, ok := r.Spec.CustomVariables[BootstrapDataCustomVariable]; ok && r.Spec.BootstrapDelivery == BootstrapDeliveryCustomVariables {
+		allErrs = append(allErrs, field.Forbidden(spec.Child("customVariables").Key(BootstrapDataCustomVariable),
+			"is reserved for the bootstrap data with the custom-variables bootstrap delivery"))
+	}
+
+	labels := map[string]bool{}
+	for i, driveArray := range r.Spec.AdditionalDriveArrays {
+		if labels[driveArray.Label] {
+			allErrs = append(allErrs, field.Duplicate(spec.Child("additionalDriveArrays").Index(i).Child("label"), driveArray.Label))
+		}
+		labels[driveArray.Label] = true
+	}
+
+	indexes := map[int]bool{}
+	for i, networkInterface := range r.Spec.NetworkInterfaces {
+		if indexes[networkInterface.Index] {
+			allErrs = append(allErrs, field.Duplicate(spec.Child("networkInterfaces").Index(i).Child("index"), networkInterface.Index))
+		}
+		indexes[networkInterface.Index] = true
+		for j, pool := range networkInterface.AddressesFromPools {
+			if pool.APIGroup == nil || *pool.APIGroup == "" {
+				allErrs = append(allErrs, field.Required(spec.Child("networkInterfaces").Index(i).Child("addressesFromPools").Index(j).Child("apiGroup"),
+					"the API group of the IPAM pool is required"))
+			}
+		}
+	}
+	return allErrs
+}
+
+func (r *MetalsoftMachine) validate(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(GroupVersion.WithKind("MetalsoftMachine").GroupKind(), r.Name, allErrs)
+}
@@ -0,0 +1,297 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestMetalsoftMachineDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	machine := &MetalsoftMachine{Spec: MetalsoftMachineSpec{
+		AdditionalDriveArrays: []DriveArraySpec{
+			{Label: "data", SizeGB: 100},
+			{Label: "logs", SizeGB: 50, StorageType: "iscsi_hdd"},
+		},
+	}}
+	machine.Default()
+	g.Expect(machine.Spec.AdditionalDriveArrays[0].StorageType).To(Equal(DefaultStorageType))
+	g.Expect(machine.Spec.AdditionalDriveArrays[1].StorageType).To(Equal("iscsi_hdd"))
+}
+
+func TestMetalsoftMachineValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    MetalsoftMachineSpec
+		wantErr bool
+	}{
+		{
+			name: "server type",
+			spec: MetalsoftMachineSpec{ServerType: "M.16.64.v2", OSTemplate: "ubuntu-22-04"},
+		},
+		{
+			name: "server type selector",
+			spec: MetalsoftMachineSpec{ServerTypeSelector: &ServerTypeSelector{MinCores: 16}, OSTemplate: "ubuntu-22-04"},
+		},
+		{
+			name:    "no server type",
+			spec:    MetalsoftMachineSpec{OSTemplate: "ubuntu-22-04"},
+			wantErr: true,
+		},
+		{
+			name: "both server type and selector",
+			spec: MetalsoftMachineSpec{
+				ServerType:         "M.16.64.v2",
+				ServerTypeSelector: &ServerTypeSelector{MinCores: 16},
+				OSTemplate:         "ubuntu-22-04",
+			},
+			wantErr: true,
+		},
+		{
+			name:    "no OS template",
+			spec:    MetalsoftMachineSpec{ServerType: "M.16.64.v2"},
+			wantErr: true,
+		},
+		{
+			name: "duplicate drive array labels",
+			spec: MetalsoftMachineSpec{
+				ServerType: "M.16.64.v2",
+				OSTemplate: "ubuntu-22-04",
+				AdditionalDriveArrays: []DriveArraySpec{
+					{Label: "data", SizeGB: 100},
+					{Label: "data", SizeGB: 200},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate network interface indexes",
+			spec: MetalsoftMachineSpec{
+				ServerType: "M.16.64.v2",
+				OSTemplate: "ubuntu-22-04",
+				NetworkInterfaces: []NetworkInterfaceSpec{
+					{Index: 0, Network: "wan"},
+					{Index: 0, Network: "private"},
+				},
+			},
+			wantErr: true,
+		},
+		{
+			name: "host selector",
+			spec: MetalsoftMachineSpec{
+				OSTemplate:   "ubuntu-22-04",
+				HostSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "storage"}},
+			},
+		},
+		{
+			name: "host selector with server type selector",
+			spec: MetalsoftMachineSpec{
+				OSTemplate:         "ubuntu-22-04",
+				ServerTypeSelector: &ServerTypeSelector{MinCores: 8},
+				HostSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "storage"}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid host selector",
+			spec: MetalsoftMachineSpec{
+				OSTemplate: "ubuntu-22-04",
+				HostSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "tier", Operator: "Near"},
+				}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hardware requirements",
+			spec: MetalsoftMachineSpec{
+				OSTemplate:           "ubuntu-22-04",
+				HardwareRequirements: &HardwareRequirements{MinRAMGB: 512, DiskType: "nvme", MinDisks: 4},
+			},
+		},
+		{
+			name: "hardware requirements with server type selector",
+			spec: MetalsoftMachineSpec{
+				OSTemplate:           "ubuntu-22-04",
+				ServerTypeSelector:   &ServerTypeSelector{MinCores: 8},
+				HardwareRequirements: &HardwareRequirements{MinCores: 8},
+			},
+			wantErr: true,
+		},
+		{
+			name: "custom variables bootstrap delivery",
+			spec: MetalsoftMachineSpec{
+				ServerType:        "M.16.64.v2",
+				OSTemplate:        "ubuntu-22-04",
+				BootstrapDelivery: BootstrapDeliveryCustomVariables,
+				CustomVariables:   map[string]string{"ntp_server": "10.0.0.1"},
+			},
+		},
+		{
+			name: "custom variable reserved for the bootstrap data",
+			spec: MetalsoftMachineSpec{
+				ServerType:        "M.16.64.v2",
+				OSTemplate:        "ubuntu-22-04",
+				BootstrapDelivery: BootstrapDeliveryCustomVariables,
+				CustomVariables:   map[string]string{BootstrapDataCustomVariable: "#cloud-config"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "addresses from IPAM pools",
+			spec: MetalsoftMachineSpec{
+				ServerType: "M.16.64.v2",
+				OSTemplate: "ubuntu-22-04",
+				NetworkInterfaces: []NetworkInterfaceSpec{{
+					Index:   0,
+					Network: "private",
+					AddressesFromPools: []corev1.TypedLocalObjectReference{
+						{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "nodes"},
+					},
+				}},
+			},
+		},
+		{
+			name: "IPAM pool without API group",
+			spec: MetalsoftMachineSpec{
+				ServerType: "M.16.64.v2",
+				OSTemplate: "ubuntu-22-04",
+				NetworkInterfaces: []NetworkInterfaceSpec{{
+					Index:              0,
+					Network:            "private",
+					AddressesFromPools: []corev1.TypedLocalObjectReference{{Kind: "InClusterIPPool", Name: "nodes"}},
+				}},
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			machine := &MetalsoftMachine{Spec: tt.spec}
+			_, err := machine.ValidateCreate()
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestMetalsoftMachineValidateUpdate(t *testing.T) {
+	old := MetalsoftMachineSpec{ServerType: "M.16.64.v2", OSTemplate: "ubuntu-22-04", BootDriveSizeGB: 40}
+	tests := []struct {
+		name    string
+		oldSpec MetalsoftMachineSpec
+		update  func(spec *MetalsoftMachineSpec)
+		wantErr bool
+	}{
+		{
+			name:    "provider ID set",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.ProviderID = pointer.String("metalsoft://1") },
+		},
+		{
+			name:    "server type changed",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.ServerType = "M.40.256.v3" },
+			wantErr: true,
+		},
+		{
+			name:    "OS template changed",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.OSTemplate = "rocky-9" },
+			wantErr: true,
+		},
+		{
+			name:    "boot drive resized",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.BootDriveSizeGB = 80 },
+			wantErr: true,
+		},
+		{
+			name:    "network interface added",
+			oldSpec: old,
+			update: func(spec *MetalsoftMachineSpec) {
+				spec.NetworkInterfaces = []NetworkInterfaceSpec{{Index: 1, Network: "private"}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "drive array added",
+			oldSpec: old,
+			update: func(spec *MetalsoftMachineSpec) {
+				spec.AdditionalDriveArrays = []DriveArraySpec{{Label: "data", SizeGB: 100}}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "SSH key added",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.SSHKeyIDs = []int{1} },
+			wantErr: true,
+		},
+		{
+			name:    "custom variable changed",
+			oldSpec: old,
+			update: func(spec *MetalsoftMachineSpec) {
+				spec.CustomVariables = map[string]string{"ntp_server": "pool.ntp.org"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "bootstrap delivery changed",
+			oldSpec: old,
+			update:  func(spec *MetalsoftMachineSpec) { spec.BootstrapDelivery = BootstrapDeliveryConfigDrive },
+			wantErr: true,
+		},
+		{
+			name: "provider ID changed",
+			oldSpec: MetalsoftMachineSpec{
+				ServerType: "M.16.64.v2",
+				OSTemplate: "ubuntu-22-04",
+				ProviderID: pointer.String("metalsoft://1"),
+			},
+			update:  func(spec *MetalsoftMachineSpec) { spec.ProviderID = pointer.String("metalsoft://2") },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			oldMachine := &MetalsoftMachine{Spec: tt.oldSpec}
+			machine := oldMachine.DeepCopy()
+			tt.update(&machine.Spec)
+			_, err := machine.ValidateUpdate(oldMachine)
+			if tt.wantErr {
+				g.Expect(err).To(HaveOccurred())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+)
+
+// MetalsoftMachineTemplateSpec defines the desired state of MetalsoftMachineTemplate
+type MetalsoftMachineTemplateSpec struct {
+	Template MetalsoftMachineTemplateResource `json:"template"`
+}
+
+// MetalsoftMachineTemplateResource describes the data needed to create a MetalsoftMachine from a template.
+type MetalsoftMachineTemplateResource struct {
+	// Standard object's metadata.
+	// More info: https://git.k8s.io/community/contributors/devel/sig-architecture/api-conventions.md#metadata
+	// +optional
+	ObjectMeta clusterv1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is the specification of the desired behavior of the machine.
+	Spec MetalsoftMachineSpec `json:"spec"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:resource:path=metalsoftmachinetemplates,scope=Namespaced,categories=cluster-api
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// MetalsoftMachineTemplate is the Schema for the metalsoftmachinetemplates API
+type MetalsoftMachineTemplate struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec is immutable: Cluster API rolls machines out by switching to a
+	// new template, so changes must be made by creating one.
+	//+kubebuilder:validation:XValidation:rule="self == oldSelf",message="MetalsoftMachineTemplate spec is immutable"
+	Spec MetalsoftMachineTemplateSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MetalsoftMachineTemplateList contains a list of MetalsoftMachineTemplate
+type MetalsoftMachineTemplateList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MetalsoftMachineTemplate `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MetalsoftMachineTemplate{}, &MetalsoftMachineTemplateList{})
+}
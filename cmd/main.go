@@ -19,6 +19,8 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -27,12 +29,18 @@ import (
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	infrastructurev1alpha1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1alpha1"
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
 	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/controller"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -43,8 +51,12 @@ var (
 
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(clusterv1.AddToScheme(scheme))
+	utilruntime.Must(bootstrapv1.AddToScheme(scheme))
+	utilruntime.Must(ipamv1.AddToScheme(scheme))
 
 	utilruntime.Must(infrastructurev1alpha1.AddToScheme(scheme))
+	utilruntime.Must(infrastructurev1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
@@ -52,11 +64,25 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var defaultCredentialsSecret string
+	var controllerNamespace string
+	var hostResyncPeriod time.Duration
+	var machineConcurrency int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":9440", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&defaultCredentialsSecret, "default-credentials-secret", "",
+		"The <namespace>/<name> of the Secret holding the MetalSoft credentials of "+
+			"MetalsoftClusters that do not set spec.credentialsRef.")
+	flag.StringVar(&controllerNamespace, "controller-namespace", os.Getenv("POD_NAMESPACE"),
+		"The namespace the controller runs in, holding the Secrets referenced by MetalsoftClusterIdentities. "+
+			"Defaults to $POD_NAMESPACE.")
+	flag.DurationVar(&hostResyncPeriod, "host-resync-period", controller.DefaultHostResyncPeriod,
+		"How often MetalsoftHosts are refreshed from the MetalSoft server inventory.")
+	flag.IntVar(&machineConcurrency, "metalsoftmachine-concurrency", 10,
+		"Number of MetalsoftMachines to reconcile in parallel.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -89,20 +115,71 @@ func main() {
 		os.Exit(1)
 	}
 
+	var defaultCredentialsSecretKey *client.ObjectKey
+	if defaultCredentialsSecret != "" {
+		namespace, name, ok := strings.Cut(defaultCredentialsSecret, "/")
+		if !ok || namespace == "" || name == "" {
+			setupLog.Error(nil, "--default-credentials-secret must be of the form <namespace>/<name>",
+				"value", defaultCredentialsSecret)
+			os.Exit(1)
+		}
+		defaultCredentialsSecretKey = &client.ObjectKey{Namespace: namespace, Name: name}
+	}
+
 	if err = (&controller.MetalsoftClusterReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: defaultCredentialsSecretKey,
+		ControllerNamespace:      controllerNamespace,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MetalsoftCluster")
 		os.Exit(1)
 	}
 	if err = (&controller.MetalsoftMachineReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: defaultCredentialsSecretKey,
+		ControllerNamespace:      controllerNamespace,
+		MaxConcurrentReconciles:  machineConcurrency,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "MetalsoftMachine")
 		os.Exit(1)
 	}
+	if err = (&controller.MetalsoftHostReconciler{
+		Client:                   mgr.GetClient(),
+		Scheme:                   mgr.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: defaultCredentialsSecretKey,
+		ControllerNamespace:      controllerNamespace,
+		ResyncPeriod:             hostResyncPeriod,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "MetalsoftHost")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&infrastructurev1beta1.MetalsoftCluster{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetalsoftCluster")
+			os.Exit(1)
+		}
+		if err = (&infrastructurev1beta1.MetalsoftMachine{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetalsoftMachine")
+			os.Exit(1)
+		}
+		if err = (&infrastructurev1beta1.MetalsoftMachineTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetalsoftMachineTemplate")
+			os.Exit(1)
+		}
+		if err = (&infrastructurev1beta1.MetalsoftClusterTemplate{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetalsoftClusterTemplate")
+			os.Exit(1)
+		}
+		if err = (&infrastructurev1beta1.MetalsoftClusterIdentity{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "MetalsoftClusterIdentity")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
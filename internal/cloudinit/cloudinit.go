@@ -0,0 +1,112 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloudinit edits the cloud-config bootstrap data generated by
+// bootstrap providers before it is handed over to MetalSoft, and renders the
+// cloud-init network configuration of servers with static addresses.
+package cloudinit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Header is the first line of cloud-config user data.
+const Header = "#cloud-config"
+
+// ErrNotCloudConfig is returned when editing user data that is not a cloud-config document.
+var ErrNotCloudConfig = errors.New("bootstrap data is not a cloud-config document")
+
+// WriteFile is an entry of the write_files cloud-config module.
+type WriteFile struct {
+	Path        string `json:"path"`
+	Owner       string `json:"owner,omitempty"`
+	Permissions string `json:"permissions,omitempty"`
+	Content     string `json:"content"`
+}
+
+// IsCloudConfig returns true if data is a cloud-config document. The
+// cloud-config header may follow other header comments, like the jinja
+// template header of kubeadm bootstrap data.
+func IsCloudConfig(data []byte) bool {
+	for _, line := range headerLines(data) {
+		if bytes.Equal(bytes.TrimSpace(line), []byte(Header)) {
+			return true
+		}
+	}
+	return false
+}
+
+// headerLines returns the comment lines at the top of data.
+func headerLines(data []byte) [][]byte {
+	var lines [][]byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// AddWriteFiles returns data with files appended to its write_files, replacing
+// any file already written to the same path.
+func AddWriteFiles(data []byte, files ...WriteFile) ([]byte, error) {
+	if !IsCloudConfig(data) {
+		return nil, ErrNotCloudConfig
+	}
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse cloud-config: %w", err)
+	}
+
+	existing, _ := config["write_files"].([]interface{})
+	var writeFiles []interface{}
+	for _, entry := range existing {
+		if path, _ := entry.(map[string]interface{})["path"].(string); replaced(path, files) {
+			continue
+		}
+		writeFiles = append(writeFiles, entry)
+	}
+	for _, file := range files {
+		writeFiles = append(writeFiles, file)
+	}
+	config["write_files"] = writeFiles
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode cloud-config: %w", err)
+	}
+	// The header comments are dropped by the YAML round trip but tell
+	// cloud-init how to process the document.
+	header := bytes.Join(headerLines(data), nil)
+	if !bytes.HasSuffix(header, []byte("\n")) {
+		header = append(header, '\n')
+	}
+	return append(header, out...), nil
+}
+
+func replaced(path string, files []WriteFile) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+const kubeadmCloudConfig = `## template: jinja
+#cloud-config
+
+write_files:
+-   path: /run/kubeadm/kubeadm.yaml
+    owner: root:root
+    permissions: '0640'
+    content: |
+      ---
+      apiVersion: kubeadm.k8s.io/v1beta3
+-   path: /etc/kubernetes/manifests/kube-vip.yaml
+    content: stale
+runcmd:
+  - 'kubeadm init --config /run/kubeadm/kubeadm.yaml'
+`
+
+func TestAddWriteFiles(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := AddWriteFiles([]byte("#cloud-config\nruncmd:\n- kubeadm join\n"), WriteFile{Path: "/etc/motd", Content: "hello"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(IsCloudConfig(data)).To(BeTrue())
+
+	config := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+	g.Expect(config["runcmd"]).To(Equal([]interface{}{"kubeadm join"}))
+	g.Expect(config["write_files"]).To(Equal([]interface{}{
+		map[string]interface{}{"path": "/etc/motd", "content": "hello"},
+	}))
+}
+
+func TestAddWriteFilesReplacesPath(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := AddWriteFiles([]byte(kubeadmCloudConfig), WriteFile{
+		Path:        "/etc/kubernetes/manifests/kube-vip.yaml",
+		Owner:       "root:root",
+		Permissions: "0644",
+		Content:     "fresh",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+	g.Expect(string(data)).To(HavePrefix("## template: jinja\n#cloud-config\n"))
+	writeFiles := config["write_files"].([]interface{})
+	g.Expect(writeFiles).To(HaveLen(2))
+	g.Expect(writeFiles[0]).To(HaveKeyWithValue("path", "/run/kubeadm/kubeadm.yaml"))
+	g.Expect(writeFiles[1]).To(HaveKeyWithValue("content", "fresh"))
+	g.Expect(config["runcmd"]).To(HaveLen(1))
+}
+
+func TestAddWriteFilesRejectsOtherFormats(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := AddWriteFiles([]byte(`{"ignition":{"version":"3.3.0"}}`), WriteFile{Path: "/etc/motd"})
+	g.Expect(err).To(MatchError(ErrNotCloudConfig))
+}
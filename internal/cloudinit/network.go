@@ -0,0 +1,196 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+
+	"sigs.k8s.io/yaml"
+)
+
+// NetworkInterface is a network interface of a server. Interfaces without
+// static addresses are configured with DHCP.
+type NetworkInterface struct {
+	// Name is the name of the interface in the operating system.
+	Name string
+	// Addresses are the static addresses of the interface in CIDR notation.
+	Addresses []string
+	// Gateways are the gateways of the networks of the addresses.
+	Gateways []string
+}
+
+type networkConfig struct {
+	Network networkConfigV2 `json:"network"`
+}
+
+type networkConfigV2 struct {
+	Version   int                 `json:"version"`
+	Ethernets map[string]ethernet `json:"ethernets"`
+}
+
+type ethernet struct {
+	DHCP4     bool     `json:"dhcp4,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	Routes    []route  `json:"routes,omitempty"`
+}
+
+type route struct {
+	To  string `json:"to"`
+	Via string `json:"via"`
+}
+
+// NetworkConfig returns the version 2 cloud-init network configuration of
+// interfaces. The first gateway of each address family becomes the default
+// route, as a server can only have one.
+func NetworkConfig(interfaces []NetworkInterface) ([]byte, error) {
+	config := networkConfig{Network: networkConfigV2{Version: 2, Ethernets: map[string]ethernet{}}}
+	var defaultIPv4, defaultIPv6 bool
+	for _, iface := range interfaces {
+		if _, ok := config.Network.Ethernets[iface.Name]; ok {
+			return nil, fmt.Errorf("duplicate network interface %q", iface.Name)
+		}
+		entry := ethernet{DHCP4: len(iface.Addresses) == 0}
+		for _, address := range iface.Addresses {
+			if _, _, err := net.ParseCIDR(address); err != nil {
+				return nil, fmt.Errorf("invalid address %q of network interface %q: %w", address, iface.Name, err)
+			}
+			entry.Addresses = append(entry.Addresses, address)
+		}
+		for _, gateway := range iface.Gateways {
+			ip := net.ParseIP(gateway)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid gateway %q of network interface %q", gateway, iface.Name)
+			}
+			if ip.To4() != nil && !defaultIPv4 {
+				entry.Routes = append(entry.Routes, route{To: "0.0.0.0/0", Via: gateway})
+				defaultIPv4 = true
+			}
+			if ip.To4() == nil && !defaultIPv6 {
+				entry.Routes = append(entry.Routes, route{To: "::/0", Via: gateway})
+				defaultIPv6 = true
+			}
+		}
+		config.Network.Ethernets[iface.Name] = entry
+	}
+
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode network config: %w", err)
+	}
+	return out, nil
+}
+
+type networkData struct {
+	Links    []networkDataLink    `json:"links"`
+	Networks []networkDataNetwork `json:"networks"`
+	Services []interface{}        `json:"services"`
+}
+
+type networkDataLink struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type networkDataNetwork struct {
+	ID        string             `json:"id"`
+	Type      string             `json:"type"`
+	Link      string             `json:"link"`
+	IPAddress string             `json:"ip_address,omitempty"`
+	Netmask   string             `json:"netmask,omitempty"`
+	Routes    []networkDataRoute `json:"routes,omitempty"`
+}
+
+type networkDataRoute struct {
+	Network string `json:"network"`
+	Netmask string `json:"netmask"`
+	Gateway string `json:"gateway"`
+}
+
+// NetworkData returns the OpenStack network_data.json of interfaces, the
+// network configuration cloud-init reads from config drives. Links are
+// matched by name, as the MAC addresses of a server are not known before it
+// is allocated. Like NetworkConfig, only the first gateway of each address
+// family becomes a default route.
+func NetworkData(interfaces []NetworkInterface) ([]byte, error) {
+	data := networkData{Links: []networkDataLink{}, Networks: []networkDataNetwork{}, Services: []interface{}{}}
+	links := map[string]bool{}
+	var defaultIPv4, defaultIPv6 bool
+	for _, iface := range interfaces {
+		if links[iface.Name] {
+			return nil, fmt.Errorf("duplicate network interface %q", iface.Name)
+		}
+		links[iface.Name] = true
+		data.Links = append(data.Links, networkDataLink{ID: iface.Name, Name: iface.Name, Type: "phy"})
+		if len(iface.Addresses) == 0 {
+			data.Networks = append(data.Networks, networkDataNetwork{
+				ID:   fmt.Sprintf("network%d", len(data.Networks)),
+				Type: "ipv4_dhcp",
+				Link: iface.Name,
+			})
+			continue
+		}
+
+		gateways := map[bool]string{}
+		for _, gateway := range iface.Gateways {
+			ip := net.ParseIP(gateway)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid gateway %q of network interface %q", gateway, iface.Name)
+			}
+			ipv4 := ip.To4() != nil
+			if _, ok := gateways[ipv4]; !ok {
+				gateways[ipv4] = gateway
+			}
+		}
+		for _, address := range iface.Addresses {
+			ip, ipNet, err := net.ParseCIDR(address)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q of network interface %q: %w", address, iface.Name, err)
+			}
+			network := networkDataNetwork{
+				ID:        fmt.Sprintf("network%d", len(data.Networks)),
+				Type:      "ipv6",
+				Link:      iface.Name,
+				IPAddress: ip.String(),
+				Netmask:   net.IP(ipNet.Mask).String(),
+			}
+			ipv4 := ip.To4() != nil
+			if ipv4 {
+				network.Type = "ipv4"
+			}
+			if gateway, ok := gateways[ipv4]; ok {
+				switch {
+				case ipv4 && !defaultIPv4:
+					network.Routes = []networkDataRoute{{Network: "0.0.0.0", Netmask: "0.0.0.0", Gateway: gateway}}
+					defaultIPv4 = true
+				case !ipv4 && !defaultIPv6:
+					network.Routes = []networkDataRoute{{Network: "::", Netmask: "::", Gateway: gateway}}
+					defaultIPv6 = true
+				}
+			}
+			data.Networks = append(data.Networks, network)
+		}
+	}
+
+	out, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode network data: %w", err)
+	}
+	return out, nil
+}
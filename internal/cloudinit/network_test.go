@@ -0,0 +1,105 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloudinit
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/yaml"
+)
+
+func TestNetworkConfig(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := NetworkConfig([]NetworkInterface{
+		{Name: "eth0"},
+		{Name: "eth1", Addresses: []string{"10.0.0.10/24", "2001:db8::10/64"}, Gateways: []string{"10.0.0.1", "2001:db8::1"}},
+		{Name: "eth2", Addresses: []string{"10.1.0.10/24"}, Gateways: []string{"10.1.0.1"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config := map[string]interface{}{}
+	g.Expect(yaml.Unmarshal(data, &config)).To(Succeed())
+	g.Expect(config).To(Equal(map[string]interface{}{
+		"network": map[string]interface{}{
+			"version": float64(2),
+			"ethernets": map[string]interface{}{
+				"eth0": map[string]interface{}{"dhcp4": true},
+				"eth1": map[string]interface{}{
+					"addresses": []interface{}{"10.0.0.10/24", "2001:db8::10/64"},
+					"routes": []interface{}{
+						map[string]interface{}{"to": "0.0.0.0/0", "via": "10.0.0.1"},
+						map[string]interface{}{"to": "::/0", "via": "2001:db8::1"},
+					},
+				},
+				"eth2": map[string]interface{}{"addresses": []interface{}{"10.1.0.10/24"}},
+			},
+		},
+	}))
+}
+
+func TestNetworkConfigRejectsInvalidAddresses(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := NetworkConfig([]NetworkInterface{{Name: "eth0", Addresses: []string{"10.0.0.10"}}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid address")))
+	_, err = NetworkConfig([]NetworkInterface{{Name: "eth0", Addresses: []string{"10.0.0.10/24"}, Gateways: []string{"gateway"}}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid gateway")))
+	_, err = NetworkConfig([]NetworkInterface{{Name: "eth0"}, {Name: "eth0"}})
+	g.Expect(err).To(MatchError(ContainSubstring("duplicate")))
+}
+
+func TestNetworkData(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := NetworkData([]NetworkInterface{
+		{Name: "eth0"},
+		{Name: "eth1", Addresses: []string{"10.0.0.10/24", "2001:db8::10/64"}, Gateways: []string{"10.0.0.1", "2001:db8::1"}},
+		{Name: "eth2", Addresses: []string{"10.1.0.10/24"}, Gateways: []string{"10.1.0.1"}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	networkData := map[string]interface{}{}
+	g.Expect(json.Unmarshal(data, &networkData)).To(Succeed())
+	g.Expect(networkData).To(Equal(map[string]interface{}{
+		"links": []interface{}{
+			map[string]interface{}{"id": "eth0", "name": "eth0", "type": "phy"},
+			map[string]interface{}{"id": "eth1", "name": "eth1", "type": "phy"},
+			map[string]interface{}{"id": "eth2", "name": "eth2", "type": "phy"},
+		},
+		"networks": []interface{}{
+			map[string]interface{}{"id": "network0", "type": "ipv4_dhcp", "link": "eth0"},
+			map[string]interface{}{
+				"id": "network1", "type": "ipv4", "link": "eth1", "ip_address": "10.0.0.10", "netmask": "255.255.255.0",
+				"routes": []interface{}{map[string]interface{}{"network": "0.0.0.0", "netmask": "0.0.0.0", "gateway": "10.0.0.1"}},
+			},
+			map[string]interface{}{
+				"id": "network2", "type": "ipv6", "link": "eth1", "ip_address": "2001:db8::10", "netmask": "ffff:ffff:ffff:ffff::",
+				"routes": []interface{}{map[string]interface{}{"network": "::", "netmask": "::", "gateway": "2001:db8::1"}},
+			},
+			map[string]interface{}{"id": "network3", "type": "ipv4", "link": "eth2", "ip_address": "10.1.0.10", "netmask": "255.255.255.0"},
+		},
+		"services": []interface{}{},
+	}))
+
+	_, err = NetworkData([]NetworkInterface{{Name: "eth0", Addresses: []string{"10.0.0.10"}}})
+	g.Expect(err).To(MatchError(ContainSubstring("invalid address")))
+	_, err = NetworkData([]NetworkInterface{{Name: "eth0"}, {Name: "eth0"}})
+	g.Expect(err).To(MatchError(ContainSubstring("duplicate")))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package configdrive builds OpenStack config drive images, the ISO 9660 file
+// systems labelled config-2 that cloud-init and Ignition read user data and
+// instance metadata from when no metadata service is available.
+package configdrive
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Label is the volume label cloud-init and Ignition look config drives up by.
+const Label = "config-2"
+
+const (
+	metaDataPath    = "openstack/latest/meta_data.json"
+	userDataPath    = "openstack/latest/user_data"
+	networkDataPath = "openstack/latest/network_data.json"
+)
+
+// MetaData is the instance metadata of a config drive.
+type MetaData struct {
+	UUID     string `json:"uuid"`
+	Name     string `json:"name,omitempty"`
+	Hostname string `json:"hostname,omitempty"`
+}
+
+// New returns a config drive image holding metaData and userData, and the
+// OpenStack network_data.json networkData unless it is empty.
+func New(metaData MetaData, userData, networkData []byte) ([]byte, error) {
+	metaDataJSON, err := json.Marshal(metaData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	files := map[string][]byte{
+		metaDataPath: metaDataJSON,
+		userDataPath: userData,
+	}
+	if len(networkData) > 0 {
+		files[networkDataPath] = networkData
+	}
+	return writeISO(Label, files)
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configdrive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+// readFile returns the content of the file at path of an ISO 9660 image by
+// walking its directory records from the root directory.
+func readFile(image []byte, path string) ([]byte, error) {
+	descriptor := image[systemAreaSectors*sectorSize:]
+	if string(descriptor[1:6]) != "CD001" {
+		return nil, fmt.Errorf("no volume descriptor")
+	}
+	record := descriptor[156:190]
+	for _, name := range strings.Split(path, "/") {
+		if record[25]&2 == 0 {
+			return nil, fmt.Errorf("%q is not a directory", name)
+		}
+		extent := int(binary.LittleEndian.Uint32(record[2:]))
+		size := int(binary.LittleEndian.Uint32(record[10:]))
+		dir := image[extent*sectorSize : extent*sectorSize+size]
+		record = nil
+		for offset := 0; offset < len(dir); {
+			length := int(dir[offset])
+			if length == 0 {
+				offset += sectorSize - offset%sectorSize
+				continue
+			}
+			entry := dir[offset : offset+length]
+			identifier := string(entry[33 : 33+int(entry[32])])
+			identifier = strings.TrimSuffix(strings.TrimSuffix(identifier, ";1"), ".")
+			if strings.EqualFold(identifier, name) {
+				record = entry
+				break
+			}
+			offset += length
+		}
+		if record == nil {
+			return nil, fmt.Errorf("%q not found", name)
+		}
+	}
+	extent := int(binary.LittleEndian.Uint32(record[2:]))
+	size := int(binary.LittleEndian.Uint32(record[10:]))
+	return image[extent*sectorSize : extent*sectorSize+size], nil
+}
+
+func TestNew(t *testing.T) {
+	g := NewWithT(t)
+
+	userData := []byte("#cloud-config\nruncmd:\n- kubeadm join\n")
+	image, err := New(MetaData{UUID: "f1f0c4a2", Name: "worker-0", Hostname: "worker-0"}, userData, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(len(image) % sectorSize).To(BeZero())
+
+	descriptor := image[systemAreaSectors*sectorSize:]
+	g.Expect(string(descriptor[1:6])).To(Equal("CD001"))
+	g.Expect(strings.TrimRight(string(descriptor[40:72]), " ")).To(Equal(Label))
+	g.Expect(int(binary.LittleEndian.Uint32(descriptor[80:]))).To(Equal(len(image) / sectorSize))
+
+	data, err := readFile(image, "openstack/latest/user_data")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(Equal(userData))
+
+	data, err = readFile(image, "openstack/latest/meta_data.json")
+	g.Expect(err).NotTo(HaveOccurred())
+	metaData := MetaData{}
+	g.Expect(json.Unmarshal(data, &metaData)).To(Succeed())
+	g.Expect(metaData).To(Equal(MetaData{UUID: "f1f0c4a2", Name: "worker-0", Hostname: "worker-0"}))
+
+	_, err = readFile(image, "openstack/latest/network_data.json")
+	g.Expect(err).To(MatchError(ContainSubstring("not found")))
+
+	again, err := New(MetaData{UUID: "f1f0c4a2", Name: "worker-0", Hostname: "worker-0"}, userData, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(bytes.Equal(image, again)).To(BeTrue(), "images are not reproducible")
+}
+
+func TestNewWithNetworkData(t *testing.T) {
+	g := NewWithT(t)
+
+	networkData := []byte(`{"links":[{"id":"eth0","name":"eth0","type":"phy"}],"networks":[{"id":"network0","type":"ipv4_dhcp","link":"eth0"}],"services":[]}`)
+	image, err := New(MetaData{UUID: "f1f0c4a2"}, []byte("#cloud-config\n"), networkData)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	data, err := readFile(image, "openstack/latest/network_data.json")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(data).To(Equal(networkData))
+}
+
+func TestWriteISOSpansSectors(t *testing.T) {
+	g := NewWithT(t)
+
+	files := map[string][]byte{}
+	for i := 0; i < 100; i++ {
+		files[fmt.Sprintf("data/file_with_a_long_name_%03d", i)] = bytes.Repeat([]byte{byte(i)}, i*100)
+	}
+	image, err := writeISO(Label, files)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for path, content := range files {
+		data, err := readFile(image, path)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(data).To(Equal(content), path)
+	}
+}
+
+func TestWriteISORejectsInvalidPaths(t *testing.T) {
+	g := NewWithT(t)
+
+	for _, path := range []string{
+		"openstack/latest/User_Data",
+		"openstack/latest/vendor-data.json",
+		"openstack/latest/meta.data.json",
+		"openstack/latest/" + strings.Repeat("a", 30),
+		"open.stack/latest/user_data",
+	} {
+		_, err := writeISO(Label, map[string][]byte{path: nil})
+		g.Expect(err).To(HaveOccurred(), path)
+	}
+
+	_, err := writeISO(Label, map[string][]byte{"openstack": nil, "openstack/latest": nil})
+	g.Expect(err).To(MatchError(ContainSubstring("is a file")))
+}
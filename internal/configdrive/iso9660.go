@@ -0,0 +1,331 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package configdrive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sectorSize is the logical block size of the image.
+const sectorSize = 2048
+
+// systemAreaSectors is the number of sectors before the volume descriptors.
+const systemAreaSectors = 16
+
+// maxIdentifierLength is the longest file identifier of ISO 9660 level 2.
+const maxIdentifierLength = 30
+
+// node is a file or directory of the image.
+type node struct {
+	name       string
+	identifier string
+	dir        bool
+	data       []byte
+	parent     *node
+	children   []*node
+
+	// number is the position of a directory in the path tables.
+	number int
+	extent int
+	size   int
+}
+
+// writeISO returns an ISO 9660 level 2 image labelled label holding files by
+// path. Without Rock Ridge or Joliet extensions, Linux shows the names in
+// lower case, so paths are limited to lower case letters, digits,
+// underscores and a single dot in file names.
+func writeISO(label string, files map[string][]byte) ([]byte, error) {
+	root := &node{identifier: "\x00", dir: true}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if err := root.add(strings.Split(path, "/"), files[path]); err != nil {
+			return nil, fmt.Errorf("invalid path %q: %w", path, err)
+		}
+	}
+
+	// The path tables list the directories level by level, which a breadth
+	// first walk of the sorted tree yields.
+	dirs := []*node{root}
+	for i := 0; i < len(dirs); i++ {
+		dirs[i].number = i + 1
+		sort.Slice(dirs[i].children, func(a, b int) bool {
+			return dirs[i].children[a].identifier < dirs[i].children[b].identifier
+		})
+		for _, child := range dirs[i].children {
+			if child.dir {
+				dirs = append(dirs, child)
+			}
+		}
+	}
+
+	pathTableSize := 0
+	for _, dir := range dirs {
+		pathTableSize += pathTableRecordLength(dir)
+	}
+	pathTableSectors := sectors(pathTableSize)
+
+	// Volume descriptor, terminator, then the L and M path tables.
+	next := systemAreaSectors + 2
+	lPathTable := next
+	mPathTable := lPathTable + pathTableSectors
+	next = mPathTable + pathTableSectors
+	for _, dir := range dirs {
+		dir.extent = next
+		dir.size = sectors(dir.recordsLength()) * sectorSize
+		next += dir.size / sectorSize
+	}
+	for _, dir := range dirs {
+		for _, child := range dir.children {
+			if child.dir {
+				continue
+			}
+			child.extent = next
+			child.size = len(child.data)
+			next += sectors(child.size)
+		}
+	}
+
+	image := make([]byte, next*sectorSize)
+	writeVolumeDescriptor(image[systemAreaSectors*sectorSize:], label, next, pathTableSize, lPathTable, mPathTable, root)
+	terminator := image[(systemAreaSectors+1)*sectorSize:]
+	terminator[0] = 255
+	copy(terminator[1:], "CD001")
+	terminator[6] = 1
+
+	l := image[lPathTable*sectorSize:]
+	m := image[mPathTable*sectorSize:]
+	for _, dir := range dirs {
+		parent := dir.parent
+		if parent == nil {
+			parent = dir
+		}
+		n := copy(l, pathTableRecord(dir, parent, binary.LittleEndian))
+		l = l[n:]
+		copy(m, pathTableRecord(dir, parent, binary.BigEndian))
+		m = m[n:]
+	}
+
+	for _, dir := range dirs {
+		parent := dir.parent
+		if parent == nil {
+			parent = dir
+		}
+		records := [][]byte{
+			directoryRecord("\x00", dir.extent, dir.size, true),
+			directoryRecord("\x01", parent.extent, parent.size, true),
+		}
+		for _, child := range dir.children {
+			records = append(records, directoryRecord(child.identifier, child.extent, child.size, child.dir))
+		}
+		offset := dir.extent * sectorSize
+		for _, record := range records {
+			// Records do not cross sector boundaries.
+			if remaining := sectorSize - offset%sectorSize; len(record) > remaining {
+				offset += remaining
+			}
+			offset += copy(image[offset:], record)
+		}
+		for _, child := range dir.children {
+			if !child.dir {
+				copy(image[child.extent*sectorSize:], child.data)
+			}
+		}
+	}
+	return image, nil
+}
+
+// add adds the file at path under n with its parent directories.
+func (n *node) add(path []string, data []byte) error {
+	name := path[0]
+	if len(path) == 1 {
+		identifier, err := fileIdentifier(name)
+		if err != nil {
+			return err
+		}
+		for _, child := range n.children {
+			if child.name == name {
+				return fmt.Errorf("duplicate file %q", name)
+			}
+		}
+		n.children = append(n.children, &node{name: name, identifier: identifier, data: data, parent: n})
+		return nil
+	}
+
+	identifier, err := directoryIdentifier(name)
+	if err != nil {
+		return err
+	}
+	for _, child := range n.children {
+		if child.name == name {
+			if !child.dir {
+				return fmt.Errorf("%q is a file", name)
+			}
+			return child.add(path[1:], data)
+		}
+	}
+	dir := &node{name: name, identifier: identifier, dir: true, parent: n}
+	n.children = append(n.children, dir)
+	return dir.add(path[1:], data)
+}
+
+// recordsLength returns the length of the directory records of a directory.
+func (n *node) recordsLength() int {
+	length := 0
+	add := func(recordLength int) {
+		if remaining := sectorSize - length%sectorSize; recordLength > remaining {
+			length += remaining
+		}
+		length += recordLength
+	}
+	add(directoryRecordLength("\x00"))
+	add(directoryRecordLength("\x01"))
+	for _, child := range n.children {
+		add(directoryRecordLength(child.identifier))
+	}
+	return length
+}
+
+// fileIdentifier returns the ISO 9660 identifier of a file name.
+func fileIdentifier(name string) (string, error) {
+	base, extension, _ := strings.Cut(name, ".")
+	if base == "" || !validName(base) || !validName(extension) {
+		return "", fmt.Errorf("file name %q is not made of lower case letters, digits and underscores with a single extension", name)
+	}
+	identifier := strings.ToUpper(base + "." + extension)
+	if len(identifier) > maxIdentifierLength {
+		return "", fmt.Errorf("file name %q is longer than %d characters", name, maxIdentifierLength-1)
+	}
+	return identifier + ";1", nil
+}
+
+// directoryIdentifier returns the ISO 9660 identifier of a directory name.
+func directoryIdentifier(name string) (string, error) {
+	if name == "" || !validName(name) {
+		return "", fmt.Errorf("directory name %q is not made of lower case letters, digits and underscores", name)
+	}
+	if len(name) > maxIdentifierLength+1 {
+		return "", fmt.Errorf("directory name %q is longer than %d characters", name, maxIdentifierLength+1)
+	}
+	return strings.ToUpper(name), nil
+}
+
+// validName returns true if name is made of ISO 9660 d-characters, in lower
+// case.
+func validName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// sectors returns the number of sectors holding length bytes.
+func sectors(length int) int {
+	return (length + sectorSize - 1) / sectorSize
+}
+
+// directoryRecordLength returns the length of the directory record of an
+// identifier, padded to an even length.
+func directoryRecordLength(identifier string) int {
+	return 33 + len(identifier) + (len(identifier)+1)%2
+}
+
+// directoryRecord returns the directory record of a file or directory. The
+// recording date is left unspecified so that images are reproducible.
+func directoryRecord(identifier string, extent, size int, dir bool) []byte {
+	record := make([]byte, directoryRecordLength(identifier))
+	record[0] = byte(len(record))
+	putBothUint32(record[2:], uint32(extent))
+	putBothUint32(record[10:], uint32(size))
+	if dir {
+		record[25] = 2
+	}
+	putBothUint16(record[28:], 1)
+	record[32] = byte(len(identifier))
+	copy(record[33:], identifier)
+	return record
+}
+
+// pathTableRecordLength returns the length of the path table record of a
+// directory, padded to an even length.
+func pathTableRecordLength(dir *node) int {
+	return 8 + len(dir.identifier) + len(dir.identifier)%2
+}
+
+// pathTableRecord returns the path table record of a directory.
+func pathTableRecord(dir, parent *node, order binary.ByteOrder) []byte {
+	record := make([]byte, pathTableRecordLength(dir))
+	record[0] = byte(len(dir.identifier))
+	order.PutUint32(record[2:], uint32(dir.extent))
+	order.PutUint16(record[6:], uint16(parent.number))
+	copy(record[8:], dir.identifier)
+	return record
+}
+
+// writeVolumeDescriptor writes the primary volume descriptor of an image of
+// size sectors to descriptor.
+func writeVolumeDescriptor(descriptor []byte, label string, size, pathTableSize, lPathTable, mPathTable int, root *node) {
+	descriptor[0] = 1
+	copy(descriptor[1:], "CD001")
+	descriptor[6] = 1
+	padded(descriptor[8:40], "LINUX")
+	padded(descriptor[40:72], label)
+	putBothUint32(descriptor[80:], uint32(size))
+	putBothUint16(descriptor[120:], 1)
+	putBothUint16(descriptor[124:], 1)
+	putBothUint16(descriptor[128:], sectorSize)
+	putBothUint32(descriptor[132:], uint32(pathTableSize))
+	binary.LittleEndian.PutUint32(descriptor[140:], uint32(lPathTable))
+	binary.BigEndian.PutUint32(descriptor[148:], uint32(mPathTable))
+	copy(descriptor[156:190], directoryRecord("\x00", root.extent, root.size, true))
+	for _, field := range [][2]int{{190, 318}, {318, 446}, {446, 574}, {574, 702}, {702, 739}, {739, 776}, {776, 813}} {
+		padded(descriptor[field[0]:field[1]], "")
+	}
+	// Unspecified creation, modification, expiration and effective dates.
+	for _, offset := range []int{813, 830, 847, 864} {
+		copy(descriptor[offset:offset+16], "0000000000000000")
+	}
+	descriptor[881] = 1
+}
+
+// padded copies s to field, padded with spaces.
+func padded(field []byte, s string) {
+	n := copy(field, s)
+	for i := n; i < len(field); i++ {
+		field[i] = ' '
+	}
+}
+
+// putBothUint16 writes v in both byte orders.
+func putBothUint16(b []byte, v uint16) {
+	binary.LittleEndian.PutUint16(b, v)
+	binary.BigEndian.PutUint16(b[2:], v)
+}
+
+// putBothUint32 writes v in both byte orders.
+func putBothUint32(b []byte, v uint32) {
+	binary.LittleEndian.PutUint32(b, v)
+	binary.BigEndian.PutUint32(b[4:], v)
+}
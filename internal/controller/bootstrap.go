@@ -0,0 +1,105 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/configdrive"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// deliverBootstrapData sets the fields of instanceArray carrying bootstrapData
+// to the OS with the bootstrap delivery method of the machine. ok is false when
+// the machine has been marked as failed because the data does not fit.
+func (r *MetalsoftMachineReconciler) deliverBootstrapData(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, instanceArray *metalsoft.InstanceArray, bootstrapData string) (bool, error) {
+	method := metalsoftMachine.Spec.BootstrapDelivery
+	var size, limit int
+	switch method {
+	case infrastructurev1beta1.BootstrapDeliveryCustomVariables:
+		value, err := encodeBootstrapVariable(bootstrapData)
+		if err != nil {
+			return false, fmt.Errorf("failed to encode the bootstrap data: %w", err)
+		}
+		variables := make(map[string]string, len(instanceArray.CustomVariables)+1)
+		for name, v := range instanceArray.CustomVariables {
+			variables[name] = v
+		}
+		variables[infrastructurev1beta1.BootstrapDataCustomVariable] = value
+		instanceArray.CustomVariables = variables
+		size, limit = len(value), metalsoft.MaxCustomVariableBytes
+	case infrastructurev1beta1.BootstrapDeliveryConfigDrive:
+		networkData, err := staticNetworkData(instanceArray.Interfaces)
+		if err != nil {
+			return false, fmt.Errorf("failed to render the network data: %w", err)
+		}
+		image, err := configdrive.New(configdrive.MetaData{
+			UUID: string(metalsoftMachine.UID),
+			Name: metalsoftMachine.Name,
+		}, []byte(bootstrapData), networkData)
+		if err != nil {
+			return false, fmt.Errorf("failed to build the config drive: %w", err)
+		}
+		instanceArray.ConfigDriveISO = image
+		size, limit = len(image), metalsoft.MaxConfigDriveBytes
+	default:
+		method = infrastructurev1beta1.BootstrapDeliveryCloudInit
+		instanceArray.CloudInitData = bootstrapData
+		size, limit = len(bootstrapData), metalsoft.MaxCloudInitDataBytes
+	}
+	if size <= limit {
+		return true, nil
+	}
+
+	message := fmt.Sprintf("bootstrap data of %d bytes takes %d bytes with the %s bootstrap delivery, over its limit of %d bytes",
+		len(bootstrapData), size, method, limit)
+	if method != infrastructurev1beta1.BootstrapDeliveryConfigDrive {
+		message += "; use the config-drive bootstrap delivery for larger bootstrap data"
+	}
+	r.setBootstrapFailure(metalsoftMachine, infrastructurev1beta1.BootstrapDataTooLargeReason, message)
+	return false, nil
+}
+
+// setBootstrapFailure marks the machine as failed because its bootstrap data
+// cannot be handed to its OS, with reason on the InstanceReady condition.
+func (r *MetalsoftMachineReconciler) setBootstrapFailure(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, reason, message string) {
+	r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError, message)
+	conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+		reason, clusterv1.ConditionSeverityError, message)
+}
+
+// encodeBootstrapVariable returns bootstrap data gzipped and base64 encoded,
+// the form OS templates expect it in the bootstrap data custom variable.
+func encodeBootstrapVariable(bootstrapData string) (string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write([]byte(bootstrapData)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -0,0 +1,370 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// errHostNotMirrored is returned by claimServer for servers without a
+// MetalsoftHost yet.
+var errHostNotMirrored = errors.New("server not mirrored as a MetalsoftHost yet")
+
+// selectServer claims the smallest available MetalSoft server of the
+// datacenter of the cluster matching spec.serverType, spec.hostSelector and
+// spec.hardwareRequirements, in one of the given racks unless there are none.
+// A nil server with a nil error means the machine has been marked as failed.
+func (r *MetalsoftMachineReconciler) selectServer(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, rackLabels []string, msClient metalsoft.Client) (*metalsoft.Server, error) {
+	// A reconcile interrupted before creating the instance array leaves the
+	// server claimed for the machine.
+	server, err := r.resumeClaim(ctx, metalsoftMachine, msClient)
+	if err != nil {
+		return nil, err
+	}
+	if server != nil {
+		conditions.MarkTrue(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition)
+		return server, nil
+	}
+
+	// Only the hosts mirrored from the inventory of the credentials of the
+	// cluster belong to the servers the search returns.
+	creds, err := getCredentials(ctx, r.Client, r.DefaultCredentialsSecret, r.ControllerNamespace, metalsoftCluster)
+	if err != nil {
+		return nil, err
+	}
+	inventory := inventoryID(creds)
+
+	search := serverSearch(metalsoftMachine, metalsoftCluster, rackLabels)
+	if metalsoftMachine.Spec.ServerType != "" {
+		serverType, err := r.resolveServerType(ctx, metalsoftMachine, metalsoftCluster, msClient)
+		if err != nil || serverType == nil {
+			return nil, err
+		}
+		search.ServerTypeID = serverType.ID
+	}
+
+	servers, err := msClient.SearchServers(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search MetalSoft servers: %w", err)
+	}
+	if metalsoftMachine.Spec.HostSelector != nil {
+		selected, err := r.selectedHostServers(ctx, metalsoftMachine, metalsoftCluster, inventory, rackLabels)
+		if err != nil {
+			return nil, err
+		}
+		candidates := servers[:0]
+		for _, server := range servers {
+			if selected[server.ID] {
+				candidates = append(candidates, server)
+			}
+		}
+		servers = candidates
+	}
+	// Prefer the smallest server, keeping larger ones for machines that
+	// need them.
+	sort.SliceStable(servers, func(i, j int) bool {
+		a, b := &servers[i], &servers[j]
+		if a.ProcessorCoreCount != b.ProcessorCoreCount {
+			return a.ProcessorCoreCount < b.ProcessorCoreCount
+		}
+		if a.RAMGB != b.RAMGB {
+			return a.RAMGB < b.RAMGB
+		}
+		if len(a.Disks) != len(b.Disks) {
+			return len(a.Disks) < len(b.Disks)
+		}
+		if maxInterfaceCapacity(a) != maxInterfaceCapacity(b) {
+			return maxInterfaceCapacity(a) < maxInterfaceCapacity(b)
+		}
+		return a.ID < b.ID
+	})
+
+	notMirrored := 0
+	for i := range servers {
+		claimed, err := r.claimServer(ctx, metalsoftMachine, inventory, &servers[i], msClient)
+		if errors.Is(err, errHostNotMirrored) {
+			notMirrored++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if claimed {
+			conditions.MarkTrue(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition)
+			return &servers[i], nil
+		}
+	}
+	if notMirrored > 0 {
+		return nil, fmt.Errorf("%d matching MetalSoft servers are not mirrored as MetalsoftHosts yet", notMirrored)
+	}
+
+	message := fmt.Sprintf("no available MetalSoft server in datacenter %q", metalsoftCluster.Spec.Datacenter)
+	if constraints := describeConstraints(metalsoftMachine, rackLabels); len(constraints) > 0 {
+		message += " has " + strings.Join(constraints, ", ")
+	}
+	err = errors.New(message)
+	conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition,
+		infrastructurev1beta1.NoMatchingServerReason, clusterv1.ConditionSeverityWarning, err.Error())
+	return nil, err
+}
+
+// claimServer claims a server of an inventory for the machine, first on its
+// MetalsoftHost, which only one machine of the namespace can claim, then in
+// MetalSoft, which guards against machines of other namespaces and management
+// clusters. claimed is false when the server is claimed by another machine.
+func (r *MetalsoftMachineReconciler) claimServer(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, inventoryID string, server *metalsoft.Server, msClient metalsoft.Client) (bool, error) {
+	logger := log.FromContext(ctx)
+
+	host := &infrastructurev1beta1.MetalsoftHost{}
+	key := client.ObjectKey{Namespace: metalsoftMachine.Namespace, Name: hostName(inventoryID, server.ID)}
+	if err := r.Get(ctx, key, host); err != nil {
+		if apierrors.IsNotFound(err) {
+			return false, errHostNotMirrored
+		}
+		return false, fmt.Errorf("failed to get MetalsoftHost %s: %w", key.Name, err)
+	}
+	if host.Labels[infrastructurev1beta1.HostInventoryLabel] != inventoryID {
+		return false, errHostNotMirrored
+	}
+	if ref := host.Spec.ConsumerRef; ref != nil && ref.UID != metalsoftMachine.UID {
+		return false, nil
+	}
+	if host.Spec.ConsumerRef == nil {
+		host.Spec.ConsumerRef = &corev1.ObjectReference{
+			APIVersion: infrastructurev1beta1.GroupVersion.String(),
+			Kind:       "MetalsoftMachine",
+			Namespace:  metalsoftMachine.Namespace,
+			Name:       metalsoftMachine.Name,
+			UID:        metalsoftMachine.UID,
+		}
+		// The update carries the resourceVersion the host was read at, so it
+		// conflicts if another machine claimed the host in the meantime, in
+		// which case the selection moves on to the next server.
+		if err := r.Update(ctx, host); err != nil {
+			if apierrors.IsConflict(err) {
+				logger.Info("MetalsoftHost claimed by another machine meanwhile, skipping it", "MetalsoftHost", host.Name)
+				return false, nil
+			}
+			return false, fmt.Errorf("failed to claim MetalsoftHost %s: %w", key.Name, err)
+		}
+		logger.Info("Claimed MetalsoftHost", "MetalsoftHost", host.Name, "serverID", server.ID)
+	}
+
+	if err := msClient.ReserveServer(ctx, server.ID, serverReservationKey(metalsoftMachine)); err != nil {
+		if !metalsoft.IsConflict(err) {
+			return false, fmt.Errorf("failed to reserve MetalSoft server %d: %w", server.ID, err)
+		}
+		logger.Info("MetalSoft server taken outside of the namespace, releasing its MetalsoftHost", "serverID", server.ID)
+		return false, r.unclaimHost(ctx, host)
+	}
+	return true, nil
+}
+
+// resumeClaim returns the server of the MetalsoftHost already claimed by the
+// machine, if any, making sure it is still reserved for it.
+func (r *MetalsoftMachineReconciler) resumeClaim(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, msClient metalsoft.Client) (*metalsoft.Server, error) {
+	hosts, err := r.claimedHosts(ctx, metalsoftMachine)
+	if err != nil || len(hosts) == 0 {
+		return nil, err
+	}
+	host := &hosts[0]
+	server, err := msClient.GetServer(ctx, host.Spec.ServerID)
+	if metalsoft.IsNotFound(err) {
+		return nil, r.unclaimHost(ctx, host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MetalSoft server %d: %w", host.Spec.ServerID, err)
+	}
+	if err := msClient.ReserveServer(ctx, server.ID, serverReservationKey(metalsoftMachine)); err != nil {
+		if !metalsoft.IsConflict(err) {
+			return nil, fmt.Errorf("failed to reserve MetalSoft server %d: %w", server.ID, err)
+		}
+		return nil, r.unclaimHost(ctx, host)
+	}
+	return server, nil
+}
+
+// releaseHosts ends the MetalSoft reservations of the servers claimed by the
+// machine and releases their MetalsoftHosts.
+func (r *MetalsoftMachineReconciler) releaseHosts(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, msClient metalsoft.Client) error {
+	hosts, err := r.claimedHosts(ctx, metalsoftMachine)
+	if err != nil {
+		return err
+	}
+	for i := range hosts {
+		host := &hosts[i]
+		err := msClient.ReleaseServer(ctx, host.Spec.ServerID, serverReservationKey(metalsoftMachine))
+		if err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to release MetalSoft server %d: %w", host.Spec.ServerID, err)
+		}
+		if err := r.unclaimHost(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// claimedHosts returns the MetalsoftHosts claimed by the machine.
+func (r *MetalsoftMachineReconciler) claimedHosts(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine) ([]infrastructurev1beta1.MetalsoftHost, error) {
+	hosts := &infrastructurev1beta1.MetalsoftHostList{}
+	if err := r.List(ctx, hosts, client.InNamespace(metalsoftMachine.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list MetalsoftHosts: %w", err)
+	}
+	var claimed []infrastructurev1beta1.MetalsoftHost
+	for _, host := range hosts.Items {
+		if host.Spec.ConsumerRef != nil && host.Spec.ConsumerRef.UID == metalsoftMachine.UID {
+			claimed = append(claimed, host)
+		}
+	}
+	sort.Slice(claimed, func(i, j int) bool { return claimed[i].Name < claimed[j].Name })
+	return claimed, nil
+}
+
+// unclaimHost clears the consumer of a MetalsoftHost.
+func (r *MetalsoftMachineReconciler) unclaimHost(ctx context.Context, host *infrastructurev1beta1.MetalsoftHost) error {
+	log.FromContext(ctx).Info("Releasing MetalsoftHost", "MetalsoftHost", host.Name)
+	host.Spec.ConsumerRef = nil
+	if err := r.Update(ctx, host); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to release MetalsoftHost %s: %w", host.Name, err)
+	}
+	return nil
+}
+
+// serverReservationKey returns the key of the MetalSoft reservations of the
+// servers claimed by the machine.
+func serverReservationKey(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine) string {
+	return string(metalsoftMachine.UID)
+}
+
+// serverSearch returns the search of the available MetalSoft servers of the
+// given racks satisfying the hardware requirements of the machine.
+func serverSearch(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, rackLabels []string) metalsoft.ServerSearch {
+	search := metalsoft.ServerSearch{
+		Datacenter: metalsoftCluster.Spec.Datacenter,
+		Status:     metalsoft.ServerStatusAvailable,
+		RackLabels: rackLabels,
+	}
+	if requirements := metalsoftMachine.Spec.HardwareRequirements; requirements != nil {
+		search.MinCoreCount = requirements.MinCores
+		search.MinRAMGB = requirements.MinRAMGB
+		search.DiskType = requirements.DiskType
+		search.MinDiskCount = requirements.MinDisks
+		search.MinCapacityMbps = requirements.MinNICSpeedMbps
+	}
+	return search
+}
+
+// selectedHostServers returns the IDs of the servers of the MetalsoftHosts
+// of an inventory matching spec.hostSelector in the datacenter of the cluster
+// and, when given, in one of the racks.
+func (r *MetalsoftMachineReconciler) selectedHostServers(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, inventoryID string, rackLabels []string) (map[int]bool, error) {
+	// List options hold a single label selector, so the inventory, datacenter
+	// and rack requirements are added to the host selector.
+	hostSelector := metalsoftMachine.Spec.HostSelector.DeepCopy()
+	if hostSelector.MatchLabels == nil {
+		hostSelector.MatchLabels = map[string]string{}
+	}
+	hostSelector.MatchLabels[infrastructurev1beta1.HostInventoryLabel] = inventoryID
+	hostSelector.MatchLabels[infrastructurev1beta1.HostDatacenterLabel] = metalsoftCluster.Spec.Datacenter
+	if len(rackLabels) > 0 {
+		hostSelector.MatchExpressions = append(hostSelector.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      infrastructurev1beta1.HostRackLabel,
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   rackLabels,
+		})
+	}
+	selector, err := metav1.LabelSelectorAsSelector(hostSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid spec.hostSelector: %w", err)
+	}
+
+	hosts := &infrastructurev1beta1.MetalsoftHostList{}
+	if err := r.List(ctx, hosts, client.InNamespace(metalsoftMachine.Namespace),
+		client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, fmt.Errorf("failed to list MetalsoftHosts: %w", err)
+	}
+	selected := map[int]bool{}
+	for _, host := range hosts.Items {
+		selected[host.Spec.ServerID] = true
+	}
+	return selected, nil
+}
+
+// describeConstraints describes the constraints of the machine on its
+// server, for conditions explaining why no server matches.
+func describeConstraints(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, rackLabels []string) []string {
+	var constraints []string
+	if metalsoftMachine.Spec.ServerType != "" {
+		constraints = append(constraints, fmt.Sprintf("server type %s", metalsoftMachine.Spec.ServerType))
+	}
+	if metalsoftMachine.Spec.HostSelector != nil {
+		constraints = append(constraints, "a MetalsoftHost matching spec.hostSelector")
+	}
+	if requirements := metalsoftMachine.Spec.HardwareRequirements; requirements != nil {
+		if requirements.MinCores > 0 {
+			constraints = append(constraints, fmt.Sprintf("at least %d cores", requirements.MinCores))
+		}
+		if requirements.MinRAMGB > 0 {
+			constraints = append(constraints, fmt.Sprintf("at least %d GB of RAM", requirements.MinRAMGB))
+		}
+		if requirements.DiskType != "" || requirements.MinDisks > 0 {
+			disks := requirements.MinDisks
+			if disks == 0 {
+				disks = 1
+			}
+			diskType := requirements.DiskType
+			if diskType == "" {
+				diskType = "local"
+			}
+			constraints = append(constraints, fmt.Sprintf("at least %d %s disks", disks, diskType))
+		}
+		if requirements.MinNICSpeedMbps > 0 {
+			constraints = append(constraints, fmt.Sprintf("a network interface of at least %d Mbps", requirements.MinNICSpeedMbps))
+		}
+	}
+	if len(rackLabels) > 0 {
+		constraints = append(constraints, fmt.Sprintf("a rack of its failure domain (%s)", strings.Join(rackLabels, " or ")))
+	}
+	return constraints
+}
+
+// maxInterfaceCapacity returns the capacity of the fastest network interface
+// of a server.
+func maxInterfaceCapacity(server *metalsoft.Server) int {
+	capacity := 0
+	for _, iface := range server.Interfaces {
+		if iface.CapacityMbps > capacity {
+			capacity = iface.CapacityMbps
+		}
+	}
+	return capacity
+}
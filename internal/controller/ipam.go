@@ -0,0 +1,246 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/cloudinit"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/ignition"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// ipAddressClaimName returns the name of the IPAddressClaim of the address
+// of the network interface at index claimed from its pool at poolIndex.
+func ipAddressClaimName(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, index, poolIndex int) string {
+	return fmt.Sprintf("%s-%d-%d", metalsoftMachine.Name, index, poolIndex)
+}
+
+// reconcileIPAddressClaims claims the static addresses of the network
+// interfaces of the machine from their IPAM pools and returns them by
+// interface index. ok is false while a claim is not fulfilled yet.
+func (r *MetalsoftMachineReconciler) reconcileIPAddressClaims(ctx context.Context, cluster *clusterv1.Cluster, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine) (map[int][]metalsoft.StaticIP, bool, error) {
+	logger := log.FromContext(ctx)
+
+	staticIPs := map[int][]metalsoft.StaticIP{}
+	claims, pending := 0, 0
+	for _, networkInterface := range metalsoftMachine.Spec.NetworkInterfaces {
+		for poolIndex, pool := range networkInterface.AddressesFromPools {
+			claims++
+			claim := &ipamv1.IPAddressClaim{}
+			key := client.ObjectKey{Namespace: metalsoftMachine.Namespace, Name: ipAddressClaimName(metalsoftMachine, networkInterface.Index, poolIndex)}
+			err := r.Get(ctx, key, claim)
+			if apierrors.IsNotFound(err) {
+				claim = &ipamv1.IPAddressClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      key.Name,
+						Namespace: key.Namespace,
+						Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+					},
+					Spec: ipamv1.IPAddressClaimSpec{PoolRef: pool},
+				}
+				if err := controllerutil.SetControllerReference(metalsoftMachine, claim, r.Scheme); err != nil {
+					return nil, false, err
+				}
+				logger.Info("Claiming IP address", "IPAddressClaim", key.Name, "pool", pool.Name)
+				if err := r.Create(ctx, claim); err != nil {
+					return nil, false, fmt.Errorf("failed to create IPAddressClaim %s: %w", key, err)
+				}
+			} else if err != nil {
+				return nil, false, fmt.Errorf("failed to get IPAddressClaim %s: %w", key, err)
+			}
+
+			if claim.Status.AddressRef.Name == "" {
+				pending++
+				continue
+			}
+			address := &ipamv1.IPAddress{}
+			addressKey := client.ObjectKey{Namespace: claim.Namespace, Name: claim.Status.AddressRef.Name}
+			if err := r.Get(ctx, addressKey, address); err != nil {
+				if apierrors.IsNotFound(err) {
+					pending++
+					continue
+				}
+				return nil, false, fmt.Errorf("failed to get IPAddress %s: %w", addressKey, err)
+			}
+			staticIP, err := staticIP(address)
+			if err != nil {
+				conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.IPAddressClaimedCondition,
+					infrastructurev1beta1.IPAddressInvalidReason, clusterv1.ConditionSeverityError, err.Error())
+				return nil, false, err
+			}
+			staticIPs[networkInterface.Index] = append(staticIPs[networkInterface.Index], staticIP)
+		}
+	}
+	if claims == 0 {
+		return nil, true, nil
+	}
+	if pending > 0 {
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.IPAddressClaimedCondition,
+			infrastructurev1beta1.WaitingForIPAddressReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d IP addresses not allocated yet", pending, claims)
+		return nil, false, nil
+	}
+	conditions.MarkTrue(metalsoftMachine, infrastructurev1beta1.IPAddressClaimedCondition)
+	return staticIPs, true, nil
+}
+
+// staticIP returns the MetalSoft static IP of an IPAddress allocated by an
+// IPAM provider.
+func staticIP(address *ipamv1.IPAddress) (metalsoft.StaticIP, error) {
+	ip := net.ParseIP(address.Spec.Address)
+	if ip == nil {
+		return metalsoft.StaticIP{}, fmt.Errorf("IPAddress %s has an invalid address %q", address.Name, address.Spec.Address)
+	}
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+	if address.Spec.Prefix <= 0 || address.Spec.Prefix > bits {
+		return metalsoft.StaticIP{}, fmt.Errorf("IPAddress %s has an invalid prefix %d", address.Name, address.Spec.Prefix)
+	}
+	if address.Spec.Gateway != "" && net.ParseIP(address.Spec.Gateway) == nil {
+		return metalsoft.StaticIP{}, fmt.Errorf("IPAddress %s has an invalid gateway %q", address.Name, address.Spec.Gateway)
+	}
+	return metalsoft.StaticIP{
+		Address: address.Spec.Address,
+		Prefix:  address.Spec.Prefix,
+		Gateway: address.Spec.Gateway,
+	}, nil
+}
+
+// releaseIPAddressClaims deletes the IPAddressClaims of the machine so their
+// pools can hand the addresses out again once the server is released.
+func (r *MetalsoftMachineReconciler) releaseIPAddressClaims(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine) error {
+	for _, networkInterface := range metalsoftMachine.Spec.NetworkInterfaces {
+		for poolIndex := range networkInterface.AddressesFromPools {
+			claim := &ipamv1.IPAddressClaim{ObjectMeta: metav1.ObjectMeta{
+				Name:      ipAddressClaimName(metalsoftMachine, networkInterface.Index, poolIndex),
+				Namespace: metalsoftMachine.Namespace,
+			}}
+			log.FromContext(ctx).Info("Releasing IP address", "IPAddressClaim", claim.Name)
+			if err := r.Delete(ctx, claim); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to delete IPAddressClaim %s: %w", client.ObjectKeyFromObject(claim), err)
+			}
+		}
+	}
+	return nil
+}
+
+// staticNetworkInterfaces returns the network interfaces of a server with
+// their static addresses, and whether any of them has one. MetalSoft OS
+// templates name the network interface at index N ethN.
+func staticNetworkInterfaces(interfaces []metalsoft.InstanceArrayInterface) ([]cloudinit.NetworkInterface, bool) {
+	static := false
+	networkInterfaces := make([]cloudinit.NetworkInterface, 0, len(interfaces))
+	for _, iface := range interfaces {
+		networkInterface := cloudinit.NetworkInterface{Name: fmt.Sprintf("eth%d", iface.Index)}
+		for _, ip := range iface.StaticIPs {
+			static = true
+			networkInterface.Addresses = append(networkInterface.Addresses, fmt.Sprintf("%s/%d", ip.Address, ip.Prefix))
+			if ip.Gateway != "" {
+				networkInterface.Gateways = append(networkInterface.Gateways, ip.Gateway)
+			}
+		}
+		networkInterfaces = append(networkInterfaces, networkInterface)
+	}
+	return networkInterfaces, static
+}
+
+// staticNetworkConfig returns the cloud-init network configuration of a
+// server with static addresses on some of its interfaces, or an empty string
+// when all its addresses are allocated by MetalSoft.
+func staticNetworkConfig(interfaces []metalsoft.InstanceArrayInterface) (string, error) {
+	networkInterfaces, static := staticNetworkInterfaces(interfaces)
+	if !static {
+		return "", nil
+	}
+	config, err := cloudinit.NetworkConfig(networkInterfaces)
+	if err != nil {
+		return "", err
+	}
+	return string(config), nil
+}
+
+// staticNetworkData returns the network_data.json of the config drive of a
+// server with static addresses on some of its interfaces, or nil when all its
+// addresses are allocated by MetalSoft. cloud-init configures the network
+// from the config drive instead of the network configuration MetalSoft hands
+// to it.
+func staticNetworkData(interfaces []metalsoft.InstanceArrayInterface) ([]byte, error) {
+	networkInterfaces, static := staticNetworkInterfaces(interfaces)
+	if !static {
+		return nil, nil
+	}
+	return cloudinit.NetworkData(networkInterfaces)
+}
+
+// staticNetworkFiles returns the systemd-networkd configuration files of a
+// server with static addresses on some of its interfaces, or nil when all its
+// addresses are allocated by MetalSoft. Ignition does not read cloud-init
+// network configurations, so Ignition bootstrap data carries these files.
+// Interfaces without static addresses are configured with DHCP, and the
+// first gateway of each address family becomes the default route.
+func staticNetworkFiles(interfaces []metalsoft.InstanceArrayInterface) []ignition.File {
+	networkInterfaces, static := staticNetworkInterfaces(interfaces)
+	if !static {
+		return nil
+	}
+	files := make([]ignition.File, 0, len(networkInterfaces))
+	var defaultIPv4, defaultIPv6 bool
+	for _, iface := range networkInterfaces {
+		var unit strings.Builder
+		fmt.Fprintf(&unit, "[Match]\nName=%s\n\n[Network]\n", iface.Name)
+		if len(iface.Addresses) == 0 {
+			unit.WriteString("DHCP=ipv4\n")
+		}
+		for _, address := range iface.Addresses {
+			fmt.Fprintf(&unit, "Address=%s\n", address)
+		}
+		for _, gateway := range iface.Gateways {
+			ip := net.ParseIP(gateway)
+			if ip.To4() != nil && !defaultIPv4 {
+				fmt.Fprintf(&unit, "Gateway=%s\n", gateway)
+				defaultIPv4 = true
+			}
+			if ip.To4() == nil && !defaultIPv6 {
+				fmt.Fprintf(&unit, "Gateway=%s\n", gateway)
+				defaultIPv6 = true
+			}
+		}
+		files = append(files, ignition.File{
+			// Sorted before the DHCP default of Flatcar, zz-default.network.
+			Path:    fmt.Sprintf("/etc/systemd/network/10-%s.network", iface.Name),
+			Mode:    0644,
+			Content: unit.String(),
+		})
+	}
+	return files
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// Keys of the MetalSoft credentials Secrets referenced by
+// MetalsoftCluster.Spec.CredentialsRef or configured as the controller default.
+const (
+	// CredentialsEndpointKey holds the base URL of the MetalSoft API.
+	CredentialsEndpointKey = "endpoint"
+	// CredentialsUserEmailKey holds the email of the MetalSoft user.
+	CredentialsUserEmailKey = "userEmail"
+	// CredentialsAPIKeyKey holds the API key of the MetalSoft user.
+	CredentialsAPIKeyKey = "apiKey"
+)
+
+var (
+	// errNoCredentials is returned when a MetalsoftCluster has neither
+	// credentialsRef nor identityRef and the controller has no default
+	// credentials Secret.
+	errNoCredentials = errors.New("spec.credentialsRef is not set and no default MetalSoft credentials Secret is configured")
+	// errNamespaceNotAllowed is returned when the MetalsoftClusterIdentity of
+	// a MetalsoftCluster does not allow its namespace.
+	errNamespaceNotAllowed = errors.New("namespace is not allowed by MetalsoftClusterIdentity")
+)
+
+// newMetalsoftClient builds a MetalSoft client with factory, falling back to
+// the real API client when no factory is configured. The credentials are read
+// from the credentialsRef Secret or the identity of metalsoftCluster or, when
+// it has neither or metalsoftCluster is nil, from defaultSecret. The Secrets
+// of identities are looked up in identityNamespace.
+func newMetalsoftClient(ctx context.Context, c client.Reader, factory metalsoft.ClientFactory, defaultSecret *client.ObjectKey, identityNamespace string, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) (metalsoft.Client, error) {
+	creds, err := getCredentials(ctx, c, defaultSecret, identityNamespace, metalsoftCluster)
+	if err != nil {
+		return nil, err
+	}
+	return newMetalsoftClientFor(factory, creds)
+}
+
+// newMetalsoftClientFor builds a MetalSoft client for creds with factory,
+// falling back to the real API client when no factory is configured.
+func newMetalsoftClientFor(factory metalsoft.ClientFactory, creds metalsoft.Credentials) (metalsoft.Client, error) {
+	if factory == nil {
+		factory = metalsoft.NewClient
+	}
+	msClient, err := factory(creds)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MetalSoft client: %w", err)
+	}
+	return msClient, nil
+}
+
+// credentialsFailureReason returns the condition reason reporting err, an
+// error of newMetalsoftClient.
+func credentialsFailureReason(err error) string {
+	if errors.Is(err, errNamespaceNotAllowed) {
+		return infrastructurev1beta1.NamespaceNotAllowedByIdentityReason
+	}
+	return infrastructurev1beta1.CredentialsUnavailableReason
+}
+
+// getCredentials resolves the MetalSoft credentials used for metalsoftCluster.
+func getCredentials(ctx context.Context, c client.Reader, defaultSecret *client.ObjectKey, identityNamespace string, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) (metalsoft.Credentials, error) {
+	var key client.ObjectKey
+	switch {
+	case metalsoftCluster != nil && metalsoftCluster.Spec.IdentityRef != nil:
+		identity, err := getIdentity(ctx, c, metalsoftCluster)
+		if err != nil {
+			return metalsoft.Credentials{}, err
+		}
+		key = client.ObjectKey{Namespace: identityNamespace, Name: identity.Spec.SecretName}
+	case metalsoftCluster != nil && metalsoftCluster.Spec.CredentialsRef != nil:
+		key = client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: metalsoftCluster.Spec.CredentialsRef.Name}
+	case defaultSecret != nil:
+		key = *defaultSecret
+	default:
+		return metalsoft.Credentials{}, errNoCredentials
+	}
+
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, key, secret); err != nil {
+		return metalsoft.Credentials{}, fmt.Errorf("failed to get MetalSoft credentials Secret %s: %w", key, err)
+	}
+	creds := metalsoft.Credentials{
+		Endpoint:  string(secret.Data[CredentialsEndpointKey]),
+		UserEmail: string(secret.Data[CredentialsUserEmailKey]),
+		APIKey:    string(secret.Data[CredentialsAPIKeyKey]),
+	}
+	if err := creds.Validate(); err != nil {
+		return metalsoft.Credentials{}, fmt.Errorf("invalid MetalSoft credentials Secret %s: %w", key, err)
+	}
+	return creds, nil
+}
+
+// getIdentity returns the MetalsoftClusterIdentity referenced by
+// metalsoftCluster, after checking that it allows the namespace of
+// metalsoftCluster.
+func getIdentity(ctx context.Context, c client.Reader, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) (*infrastructurev1beta1.MetalsoftClusterIdentity, error) {
+	ref := metalsoftCluster.Spec.IdentityRef
+	if ref.Kind != infrastructurev1beta1.MetalsoftClusterIdentityKind {
+		return nil, fmt.Errorf("unsupported identity kind %q", ref.Kind)
+	}
+	identity := &infrastructurev1beta1.MetalsoftClusterIdentity{}
+	if err := c.Get(ctx, client.ObjectKey{Name: ref.Name}, identity); err != nil {
+		return nil, fmt.Errorf("failed to get MetalsoftClusterIdentity %s: %w", ref.Name, err)
+	}
+
+	allowed, err := namespaceAllowed(ctx, c, identity, metalsoftCluster.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, fmt.Errorf("%w: %s does not allow namespace %s", errNamespaceNotAllowed, identity.Name, metalsoftCluster.Namespace)
+	}
+	return identity, nil
+}
+
+// namespaceAllowed reports whether identity may be used by MetalsoftClusters
+// in namespace.
+func namespaceAllowed(ctx context.Context, c client.Reader, identity *infrastructurev1beta1.MetalsoftClusterIdentity, namespace string) (bool, error) {
+	if identity.Spec.AllowedNamespaces == nil {
+		return false, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&identity.Spec.AllowedNamespaces.Selector)
+	if err != nil {
+		return false, fmt.Errorf("invalid allowedNamespaces selector of MetalsoftClusterIdentity %s: %w", identity.Name, err)
+	}
+	ns := &corev1.Namespace{}
+	if err := c.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+		return false, fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+	return selector.Matches(labels.Set(ns.Labels)), nil
+}
@@ -18,45 +18,974 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/klog/v2"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
-	infrastructurev1alpha1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1alpha1"
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// deployRequeueAfter is how long to wait before polling a running MetalSoft deploy again.
+const deployRequeueAfter = 20 * time.Second
+
+// Attributes of the failure domains published in MetalsoftCluster.status.failureDomains.
+const (
+	failureDomainDatacenterAttribute = "datacenter"
+	failureDomainRacksAttribute      = "racks"
 )
 
 // MetalsoftClusterReconciler reconciles a MetalsoftCluster object
 type MetalsoftClusterReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// MetalsoftClientFactory creates the MetalSoft API client used by the
+	// reconciler. It defaults to metalsoft.NewClient.
+	MetalsoftClientFactory metalsoft.ClientFactory
+	// DefaultCredentialsSecret is the Secret holding the MetalSoft
+	// credentials of clusters without spec.credentialsRef. When nil, such
+	// clusters cannot be reconciled.
+	DefaultCredentialsSecret *client.ObjectKey
+	// ControllerNamespace is the namespace the controller runs in, holding
+	// the Secrets of MetalsoftClusterIdentities.
+	ControllerNamespace string
 }
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusteridentities,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the MetalsoftCluster object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile creates or adopts the MetalSoft infrastructure backing a
+// MetalsoftCluster, deploys it and marks the MetalsoftCluster ready once the
+// infrastructure is active and the control plane endpoint is known.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.15.0/pkg/reconcile
-func (r *MetalsoftClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+func (r *MetalsoftClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{}
+	if err := r.Get(ctx, req.NamespacedName, metalsoftCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, metalsoftCluster.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		logger.Info("Waiting for Cluster Controller to set OwnerRef on MetalsoftCluster")
+		return ctrl.Result{}, nil
+	}
+
+	logger = logger.WithValues("Cluster", klog.KObj(cluster))
+	ctx = log.IntoContext(ctx, logger)
+
+	if annotations.IsPaused(cluster, metalsoftCluster) {
+		logger.Info("MetalsoftCluster or linked Cluster is marked as paused, not reconciling")
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(metalsoftCluster, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer func() {
+		conditions.SetSummary(metalsoftCluster,
+			conditions.WithConditions(
+				infrastructurev1beta1.InfrastructureReadyCondition,
+				infrastructurev1beta1.NetworksReadyCondition,
+				infrastructurev1beta1.FailureDomainsReadyCondition,
+				infrastructurev1beta1.ControlPlaneEndpointReadyCondition,
+			),
+		)
+		if err := patchHelper.Patch(ctx, metalsoftCluster, patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrastructurev1beta1.InfrastructureReadyCondition,
+			infrastructurev1beta1.NetworksReadyCondition,
+			infrastructurev1beta1.FailureDomainsReadyCondition,
+			infrastructurev1beta1.ControlPlaneEndpointReadyCondition,
+		}}); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	msClient, err := newMetalsoftClient(ctx, r.Client, r.MetalsoftClientFactory, r.DefaultCredentialsSecret, r.ControllerNamespace, metalsoftCluster)
+	if !metalsoftCluster.DeletionTimestamp.IsZero() {
+		if err != nil {
+			return r.reconcileDeleteWithoutClient(ctx, cluster, metalsoftCluster, err)
+		}
+		return r.reconcileDelete(ctx, cluster, metalsoftCluster, msClient)
+	}
+	if errors.Is(err, errNamespaceNotAllowed) {
+		logger.Info("Refusing to reconcile MetalsoftCluster", "reason", err.Error())
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			infrastructurev1beta1.NamespaceNotAllowedByIdentityReason, clusterv1.ConditionSeverityError, err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			infrastructurev1beta1.CredentialsUnavailableReason, clusterv1.ConditionSeverityError, err.Error())
+		return ctrl.Result{}, err
+	}
+	return r.reconcileNormal(ctx, metalsoftCluster, msClient)
+}
+
+func (r *MetalsoftClusterReconciler) reconcileNormal(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
 
-	// TODO(user): your logic here
+	if metalsoftCluster.Status.FailureReason != nil || metalsoftCluster.Status.FailureMessage != nil {
+		logger.Info("MetalsoftCluster has failed, not reconciling")
+		return ctrl.Result{}, nil
+	}
 
+	// Register the finalizer before creating anything in MetalSoft so that
+	// the infrastructure is never left behind.
+	if controllerutil.AddFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	infrastructure, err := r.reconcileInfrastructure(ctx, metalsoftCluster, msClient)
+	if err != nil || infrastructure == nil {
+		return ctrl.Result{}, err
+	}
+
+	// Networks are created before the first deploy so that it brings them
+	// up together with the infrastructure.
+	networksReady, err := r.reconcileNetworks(ctx, metalsoftCluster, infrastructure.ID, msClient)
+	if err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition,
+			infrastructurev1beta1.NetworksProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if !infrastructure.DeployOngoing() && infrastructure.ServiceStatus != metalsoft.ServiceStatusActive {
+		logger.Info("Deploying MetalSoft infrastructure", "infrastructureID", infrastructure.ID)
+		if err := msClient.DeployInfrastructure(ctx, infrastructure.ID); err != nil {
+			conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+				infrastructurev1beta1.InfrastructureProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to deploy MetalSoft infrastructure %d: %w", infrastructure.ID, err)
+		}
+		if infrastructure, err = msClient.GetInfrastructure(ctx, infrastructure.ID); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to get MetalSoft infrastructure: %w", err)
+		}
+	}
+	if infrastructure.DeployOngoing() || infrastructure.ServiceStatus != metalsoft.ServiceStatusActive {
+		logger.Info("Waiting for MetalSoft infrastructure deploy to finish", "infrastructureID", infrastructure.ID)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			infrastructurev1beta1.InfrastructureDeployingReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+	conditions.MarkTrue(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)
+
+	// Networks added to a deployed infrastructure need another deploy.
+	if !networksReady {
+		logger.Info("Waiting for MetalSoft networks to be deployed", "infrastructureID", infrastructure.ID)
+		if err := deployInfrastructure(ctx, msClient, infrastructure.ID); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	if err := r.reconcileFailureDomains(ctx, metalsoftCluster, msClient); err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition,
+			infrastructurev1beta1.FailureDomainsUnresolvedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if metalsoftCluster.Spec.ControlPlaneEndpoint.Port == 0 {
+		metalsoftCluster.Spec.ControlPlaneEndpoint.Port = infrastructurev1beta1.DefaultAPIServerPort
+	}
+	if metalsoftCluster.Spec.ControlPlaneLoadBalancer != nil {
+		ready, err := r.reconcileControlPlaneLoadBalancer(ctx, metalsoftCluster, infrastructure.ID, msClient)
+		if err != nil {
+			conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.ControlPlaneEndpointReadyCondition,
+				infrastructurev1beta1.ControlPlaneEndpointProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+		if !ready {
+			return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+		}
+	}
+	if metalsoftCluster.Spec.ControlPlaneEndpoint.Host == "" {
+		logger.Info("Waiting for spec.controlPlaneEndpoint.host to be set")
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.ControlPlaneEndpointReadyCondition,
+			infrastructurev1beta1.WaitingForControlPlaneEndpointReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+	conditions.MarkTrue(metalsoftCluster, infrastructurev1beta1.ControlPlaneEndpointReadyCondition)
+
+	metalsoftCluster.Status.Ready = true
 	return ctrl.Result{}, nil
 }
 
+// reconcileInfrastructure returns the MetalSoft infrastructure of the cluster,
+// adopting or creating it as needed. A nil infrastructure with a nil error
+// means the cluster has been marked as failed.
+func (r *MetalsoftClusterReconciler) reconcileInfrastructure(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (*metalsoft.Infrastructure, error) {
+	logger := log.FromContext(ctx)
+
+	var infrastructure *metalsoft.Infrastructure
+	var err error
+	switch {
+	case metalsoftCluster.Status.InfrastructureID != nil:
+		infrastructure, err = msClient.GetInfrastructure(ctx, *metalsoftCluster.Status.InfrastructureID)
+	case metalsoftCluster.Spec.InfrastructureID != nil:
+		infrastructure, err = msClient.GetInfrastructure(ctx, *metalsoftCluster.Spec.InfrastructureID)
+	default:
+		infrastructure, err = msClient.GetInfrastructureByLabel(ctx, infrastructureLabel(metalsoftCluster))
+		if metalsoft.IsNotFound(err) {
+			logger.Info("Creating MetalSoft infrastructure", "label", infrastructureLabel(metalsoftCluster))
+			infrastructure, err = msClient.CreateInfrastructure(ctx, metalsoft.Infrastructure{
+				Label:      infrastructureLabel(metalsoftCluster),
+				Datacenter: metalsoftCluster.Spec.Datacenter,
+			})
+			metalsoftCluster.Status.InfrastructureCreated = err == nil
+		}
+	}
+	if metalsoft.IsNotFound(err) {
+		r.setFailure(metalsoftCluster, capierrors.InvalidConfigurationClusterError,
+			fmt.Sprintf("MetalSoft infrastructure not found: %v", err))
+		return nil, nil
+	}
+	if err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			infrastructurev1beta1.InfrastructureProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, fmt.Errorf("failed to reconcile MetalSoft infrastructure: %w", err)
+	}
+
+	if infrastructure.Datacenter != metalsoftCluster.Spec.Datacenter {
+		r.setFailure(metalsoftCluster, capierrors.InvalidConfigurationClusterError,
+			fmt.Sprintf("MetalSoft infrastructure %d is in datacenter %q, not %q",
+				infrastructure.ID, infrastructure.Datacenter, metalsoftCluster.Spec.Datacenter))
+		return nil, nil
+	}
+
+	metalsoftCluster.Status.InfrastructureID = &infrastructure.ID
+	return infrastructure, nil
+}
+
+// reconcileNetworks creates the networks of spec.networks missing from the
+// infrastructure, adopting networks that already have their name, and returns
+// false while any of them is not deployed yet.
+func (r *MetalsoftClusterReconciler) reconcileNetworks(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (bool, error) {
+	if len(metalsoftCluster.Spec.Networks) == 0 {
+		return true, nil
+	}
+
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	byLabel := map[string]*metalsoft.Network{}
+	for i := range networks {
+		byLabel[networks[i].Label] = &networks[i]
+	}
+	ready := true
+	statuses := make([]infrastructurev1beta1.NetworkStatus, 0, len(metalsoftCluster.Spec.Networks))
+	for _, spec := range metalsoftCluster.Spec.Networks {
+		networkType := strings.ToLower(string(spec.Type))
+		network, ok := byLabel[spec.Name]
+		if !ok {
+			log.FromContext(ctx).Info("Creating MetalSoft network", "name", spec.Name, "type", spec.Type)
+			network, err = msClient.CreateNetwork(ctx, infrastructureID, metalsoft.Network{
+				Label:            spec.Name,
+				Type:             networkType,
+				SubnetPool:       spec.SubnetPool,
+				SubnetPrefixSize: spec.PrefixLength,
+				VLANID:           spec.VLANID,
+			})
+			if err != nil {
+				return false, fmt.Errorf("failed to create MetalSoft network %q: %w", spec.Name, err)
+			}
+			// The created networks are only ever added to, so that a
+			// reconcile of a stale MetalsoftCluster leaves them unpatched.
+			metalsoftCluster.Status.CreatedNetworkIDs = append(metalsoftCluster.Status.CreatedNetworkIDs, network.ID)
+		}
+		if network.Type != networkType {
+			return false, fmt.Errorf("MetalSoft network %q is a %s network, not %s", spec.Name, strings.ToUpper(network.Type), spec.Type)
+		}
+		statuses = append(statuses, infrastructurev1beta1.NetworkStatus{
+			Name: spec.Name,
+			ID:   network.ID,
+			Type: spec.Type,
+		})
+		ready = ready && network.ServiceStatus == metalsoft.ServiceStatusActive
+	}
+	metalsoftCluster.Status.Networks = statuses
+
+	if !ready {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition,
+			infrastructurev1beta1.NetworksProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		return false, nil
+	}
+	conditions.MarkTrue(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition)
+	return true, nil
+}
+
+// reconcileFailureDomains publishes the failure domains of the cluster,
+// resolving the switch groups of spec.failureDomains to their racks.
+func (r *MetalsoftClusterReconciler) reconcileFailureDomains(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) error {
+	datacenter := metalsoftCluster.Spec.Datacenter
+	if len(metalsoftCluster.Spec.FailureDomains) == 0 {
+		metalsoftCluster.Status.FailureDomains = clusterv1.FailureDomains{
+			datacenter: clusterv1.FailureDomainSpec{
+				ControlPlane: true,
+				Attributes:   map[string]string{failureDomainDatacenterAttribute: datacenter},
+			},
+		}
+		conditions.MarkTrue(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition)
+		return nil
+	}
+
+	racks, err := msClient.ListRacks(ctx, datacenter)
+	if err != nil {
+		return fmt.Errorf("failed to list MetalSoft racks of datacenter %q: %w", datacenter, err)
+	}
+
+	known := map[string]bool{}
+	for _, rack := range racks {
+		known[rack.Label] = true
+	}
+
+	failureDomains := clusterv1.FailureDomains{}
+	for _, spec := range metalsoftCluster.Spec.FailureDomains {
+		var labels []string
+		inDomain := map[string]bool{}
+		for _, label := range spec.Racks {
+			if !known[label] {
+				return fmt.Errorf("rack %q of failure domain %q not found in datacenter %q", label, spec.Name, datacenter)
+			}
+			if !inDomain[label] {
+				labels = append(labels, label)
+				inDomain[label] = true
+			}
+		}
+		if spec.SwitchGroup != "" {
+			switchGroupRacks := 0
+			for _, rack := range racks {
+				if rack.SwitchGroup != spec.SwitchGroup {
+					continue
+				}
+				switchGroupRacks++
+				if !inDomain[rack.Label] {
+					labels = append(labels, rack.Label)
+					inDomain[rack.Label] = true
+				}
+			}
+			if switchGroupRacks == 0 {
+				return fmt.Errorf("switch group %q of failure domain %q has no racks in datacenter %q", spec.SwitchGroup, spec.Name, datacenter)
+			}
+		}
+		failureDomains[spec.Name] = clusterv1.FailureDomainSpec{
+			ControlPlane: spec.ControlPlane,
+			Attributes: map[string]string{
+				failureDomainDatacenterAttribute: datacenter,
+				failureDomainRacksAttribute:      strings.Join(labels, ","),
+			},
+		}
+	}
+	metalsoftCluster.Status.FailureDomains = failureDomains
+	conditions.MarkTrue(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition)
+	return nil
+}
+
+// reconcileControlPlaneLoadBalancer allocates the MetalSoft load balancer,
+// floating IP or reserved IP of the control plane endpoint and fills in the
+// endpoint host from its address. It returns false while the endpoint is not deployed yet.
+func (r *MetalsoftClusterReconciler) reconcileControlPlaneLoadBalancer(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (bool, error) {
+	var status infrastructurev1beta1.ControlPlaneLoadBalancerStatus
+	var serviceStatus string
+	switch metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type {
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP:
+		reservedIP, prefixLength, err := r.reconcileReservedIP(ctx, metalsoftCluster, infrastructureID, msClient)
+		if err != nil {
+			return false, err
+		}
+		status = infrastructurev1beta1.ControlPlaneLoadBalancerStatus{ID: reservedIP.ID, Address: reservedIP.Address, PrefixLength: prefixLength}
+		serviceStatus = reservedIP.ServiceStatus
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP:
+		floatingIP, err := r.reconcileFloatingIP(ctx, metalsoftCluster, infrastructureID, msClient)
+		if err != nil {
+			return false, err
+		}
+		status = infrastructurev1beta1.ControlPlaneLoadBalancerStatus{ID: floatingIP.ID, Address: floatingIP.Address}
+		serviceStatus = floatingIP.ServiceStatus
+	default:
+		loadBalancer, err := r.reconcileLoadBalancer(ctx, metalsoftCluster, infrastructureID, msClient)
+		if err != nil {
+			return false, err
+		}
+		status = infrastructurev1beta1.ControlPlaneLoadBalancerStatus{ID: loadBalancer.ID, Address: loadBalancer.Address}
+		serviceStatus = loadBalancer.ServiceStatus
+	}
+	metalsoftCluster.Status.ControlPlaneLoadBalancer = &status
+
+	// The address is allocated when the resource is created, so the endpoint
+	// is known before the deploy finishes. A host set beforehand, e.g. a DNS
+	// name pointing at the address, is kept.
+	if metalsoftCluster.Spec.ControlPlaneEndpoint.Host == "" {
+		metalsoftCluster.Spec.ControlPlaneEndpoint.Host = status.Address
+	}
+
+	if serviceStatus != metalsoft.ServiceStatusActive {
+		log.FromContext(ctx).Info("Waiting for the control plane endpoint to be deployed", "id", status.ID, "address", status.Address)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.ControlPlaneEndpointReadyCondition,
+			infrastructurev1beta1.ControlPlaneEndpointProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		return false, deployInfrastructure(ctx, msClient, infrastructureID)
+	}
+	return true, nil
+}
+
+// reconcileLoadBalancer returns the MetalSoft load balancer of the control
+// plane endpoint, adopting or creating it as needed.
+func (r *MetalsoftClusterReconciler) reconcileLoadBalancer(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (*metalsoft.LoadBalancer, error) {
+	if status := metalsoftCluster.Status.ControlPlaneLoadBalancer; status != nil {
+		loadBalancer, err := msClient.GetLoadBalancer(ctx, status.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get MetalSoft load balancer %d: %w", status.ID, err)
+		}
+		return loadBalancer, nil
+	}
+
+	// The load balancer may already exist if a previous reconcile was
+	// interrupted before the status was patched.
+	label := controlPlaneEndpointLabel(metalsoftCluster)
+	loadBalancers, err := msClient.ListLoadBalancers(ctx, infrastructureID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MetalSoft load balancers: %w", err)
+	}
+	for i := range loadBalancers {
+		if loadBalancers[i].Label == label {
+			return &loadBalancers[i], nil
+		}
+	}
+
+	log.FromContext(ctx).Info("Creating MetalSoft load balancer for the control plane endpoint", "label", label)
+	loadBalancer, err := msClient.CreateLoadBalancer(ctx, infrastructureID, metalsoft.LoadBalancer{
+		Label: label,
+		Port:  int(metalsoftCluster.Spec.ControlPlaneEndpoint.Port),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MetalSoft load balancer: %w", err)
+	}
+	return loadBalancer, nil
+}
+
+// reconcileFloatingIP returns the MetalSoft floating IP of the control plane
+// endpoint, adopting or creating it as needed.
+func (r *MetalsoftClusterReconciler) reconcileFloatingIP(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (*metalsoft.FloatingIP, error) {
+	if status := metalsoftCluster.Status.ControlPlaneLoadBalancer; status != nil {
+		floatingIP, err := msClient.GetFloatingIP(ctx, status.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get MetalSoft floating IP %d: %w", status.ID, err)
+		}
+		return floatingIP, nil
+	}
+
+	// The floating IP may already exist if a previous reconcile was
+	// interrupted before the status was patched.
+	label := controlPlaneEndpointLabel(metalsoftCluster)
+	floatingIPs, err := msClient.ListFloatingIPs(ctx, infrastructureID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MetalSoft floating IPs: %w", err)
+	}
+	for i := range floatingIPs {
+		if floatingIPs[i].Label == label {
+			return &floatingIPs[i], nil
+		}
+	}
+
+	log.FromContext(ctx).Info("Reserving MetalSoft floating IP for the control plane endpoint", "label", label)
+	floatingIP, err := msClient.CreateFloatingIP(ctx, infrastructureID, metalsoft.FloatingIP{Label: label})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create MetalSoft floating IP: %w", err)
+	}
+	return floatingIP, nil
+}
+
+// reconcileReservedIP returns the MetalSoft reserved IP of a kube-vip control
+// plane endpoint, adopting or creating it as needed, and the prefix length of
+// its subnet. kube-vip announces the address over ARP, so it is reserved in
+// the subnet of the WAN network the control plane machines are attached to
+// rather than routed to them by MetalSoft.
+func (r *MetalsoftClusterReconciler) reconcileReservedIP(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (*metalsoft.ReservedIP, int, error) {
+	if status := metalsoftCluster.Status.ControlPlaneLoadBalancer; status != nil {
+		reservedIP, err := msClient.GetReservedIP(ctx, status.ID)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to get MetalSoft reserved IP %d: %w", status.ID, err)
+		}
+		return reservedIP, status.PrefixLength, nil
+	}
+
+	networkName := "wan"
+	if kubeVIP := metalsoftCluster.Spec.ControlPlaneLoadBalancer.KubeVIP; kubeVIP != nil && kubeVIP.Network != "" {
+		networkName = kubeVIP.Network
+	}
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	var subnets []metalsoft.Subnet
+	for _, network := range networks {
+		if network.Label == networkName {
+			if subnets, err = msClient.ListSubnets(ctx, network.ID); err != nil {
+				return nil, 0, fmt.Errorf("failed to list the subnets of MetalSoft network %q: %w", networkName, err)
+			}
+			break
+		}
+	}
+	var subnet *metalsoft.Subnet
+	for i := range subnets {
+		if subnets[i].Type == "ipv4" {
+			subnet = &subnets[i]
+			break
+		}
+	}
+	if subnet == nil {
+		return nil, 0, fmt.Errorf("MetalSoft network %q has no IPv4 subnet for the kube-vip address", networkName)
+	}
+
+	// The reserved IP may already exist if a previous reconcile was
+	// interrupted before the status was patched.
+	label := controlPlaneEndpointLabel(metalsoftCluster)
+	reservedIPs, err := msClient.ListReservedIPs(ctx, infrastructureID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list MetalSoft reserved IPs: %w", err)
+	}
+	for i := range reservedIPs {
+		if reservedIPs[i].Label == label {
+			return &reservedIPs[i], subnet.PrefixSize, nil
+		}
+	}
+
+	log.FromContext(ctx).Info("Reserving MetalSoft IP for the control plane endpoint", "label", label, "network", networkName)
+	reservedIP, err := msClient.CreateReservedIP(ctx, subnet.ID, metalsoft.ReservedIP{Label: label})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create MetalSoft reserved IP: %w", err)
+	}
+	return reservedIP, subnet.PrefixSize, nil
+}
+
+func (r *MetalsoftClusterReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	metalsoftCluster.Status.Ready = false
+
+	waiting, err := r.waitForMachinesDeletion(ctx, cluster, metalsoftCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	if metalsoftCluster.Status.InfrastructureID == nil {
+		controllerutil.RemoveFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer)
+		return ctrl.Result{}, nil
+	}
+	infrastructureID := *metalsoftCluster.Status.InfrastructureID
+
+	// The finalizer is only removed once MetalSoft reports the
+	// infrastructure as gone; any other error keeps it so that deployed
+	// hardware is never orphaned and left billed.
+	infrastructure, err := msClient.GetInfrastructure(ctx, infrastructureID)
+	if metalsoft.IsNotFound(err) {
+		logger.Info("MetalSoft infrastructure is gone", "infrastructureID", infrastructureID)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		controllerutil.RemoveFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to get MetalSoft infrastructure %d: %w", infrastructureID, err)
+	}
+	if infrastructure.DeployOngoing() {
+		logger.Info("Waiting for MetalSoft infrastructure deploy to finish", "infrastructureID", infrastructureID)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+	if !metalsoftCluster.Status.InfrastructureCreated {
+		return r.reconcileDeleteAdopted(ctx, metalsoftCluster, infrastructureID, msClient)
+	}
+
+	// Instance arrays not backed by a MetalsoftMachine, e.g. because a
+	// finalizer was removed by hand, are stopped and deleted before the
+	// infrastructure so that their servers are released cleanly.
+	instanceArrays, err := msClient.ListInstanceArrays(ctx, infrastructureID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalSoft instance arrays: %w", err)
+	}
+	if len(instanceArrays) > 0 {
+		if err := deleteInstanceArrays(ctx, infrastructureID, instanceArrays, msClient); err != nil {
+			conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+				clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "%d instance arrays remaining", len(instanceArrays))
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	if err := deleteControlPlaneLoadBalancer(ctx, metalsoftCluster, msClient); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	for _, network := range networks {
+		if err := msClient.DeleteNetwork(ctx, network.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to delete MetalSoft network %d: %w", network.ID, err)
+		}
+	}
+
+	logger.Info("Deleting MetalSoft infrastructure", "infrastructureID", infrastructureID)
+	conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+		clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+	if err := msClient.DeleteInfrastructure(ctx, infrastructureID); err != nil && !metalsoft.IsNotFound(err) {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to delete MetalSoft infrastructure %d: %w", infrastructureID, err)
+	}
+	if err := deployInfrastructure(ctx, msClient, infrastructureID); err != nil && !metalsoft.IsNotFound(err) {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, err
+	}
+	if _, err := msClient.GetInfrastructure(ctx, infrastructureID); metalsoft.IsNotFound(err) {
+		logger.Info("MetalSoft infrastructure deleted", "infrastructureID", infrastructureID)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		controllerutil.RemoveFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer)
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+}
+
+// reconcileDeleteAdopted releases the resources created for the cluster in an
+// infrastructure it adopted: the networks it created and the control plane
+// endpoint. The infrastructure and everything else in it, like the instance
+// arrays of other users, are left alone.
+func (r *MetalsoftClusterReconciler) reconcileDeleteAdopted(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, infrastructureID int, msClient metalsoft.Client) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	exists := map[int]bool{}
+	for _, network := range networks {
+		exists[network.ID] = true
+	}
+	var createdNetworks []int
+	for _, id := range metalsoftCluster.Status.CreatedNetworkIDs {
+		if exists[id] {
+			createdNetworks = append(createdNetworks, id)
+		}
+	}
+	endpointExists, err := controlPlaneLoadBalancerExists(ctx, metalsoftCluster, msClient)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if len(createdNetworks) == 0 && !endpointExists {
+		logger.Info("Leaving adopted MetalSoft infrastructure", "infrastructureID", infrastructureID)
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		controllerutil.RemoveFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer)
+		return ctrl.Result{}, nil
+	}
+
+	logger.Info("Deleting the MetalSoft resources of the cluster from the adopted infrastructure", "infrastructureID", infrastructureID)
+	if endpointExists {
+		if err := deleteControlPlaneLoadBalancer(ctx, metalsoftCluster, msClient); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	for _, id := range createdNetworks {
+		if err := msClient.DeleteNetwork(ctx, id); err != nil && !metalsoft.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to delete MetalSoft network %d: %w", id, err)
+		}
+	}
+	conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+		clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "")
+	if err := deployInfrastructure(ctx, msClient, infrastructureID); err != nil {
+		conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+}
+
+// reconcileDeleteWithoutClient deletes a MetalsoftCluster whose MetalSoft
+// credentials cannot be resolved, e.g. because its credentials Secret was
+// deleted first. The finalizer is only removed when no infrastructure was
+// created; otherwise credentialsErr is reported until the credentials are
+// back, so that deployed hardware is never orphaned.
+func (r *MetalsoftClusterReconciler) reconcileDeleteWithoutClient(ctx context.Context, cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, credentialsErr error) (ctrl.Result, error) {
+	metalsoftCluster.Status.Ready = false
+
+	waiting, err := r.waitForMachinesDeletion(ctx, cluster, metalsoftCluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if waiting {
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	if metalsoftCluster.Status.InfrastructureID == nil {
+		controllerutil.RemoveFinalizer(metalsoftCluster, infrastructurev1beta1.ClusterFinalizer)
+		return ctrl.Result{}, nil
+	}
+	log.FromContext(ctx).Info("Waiting for MetalSoft credentials to delete the infrastructure",
+		"infrastructureID", *metalsoftCluster.Status.InfrastructureID, "reason", credentialsErr.Error())
+	conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+		credentialsFailureReason(credentialsErr), clusterv1.ConditionSeverityError, credentialsErr.Error())
+	return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+}
+
+// waitForMachinesDeletion returns true while MetalsoftMachines of the cluster
+// remain. Their instance arrays live in the infrastructure, so it must outlive
+// them to let the machines release their servers cleanly.
+func (r *MetalsoftClusterReconciler) waitForMachinesDeletion(ctx context.Context, cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) (bool, error) {
+	metalsoftMachines := &infrastructurev1beta1.MetalsoftMachineList{}
+	if err := r.List(ctx, metalsoftMachines, client.InNamespace(metalsoftCluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name}); err != nil {
+		return false, fmt.Errorf("failed to list MetalsoftMachines: %w", err)
+	}
+	if len(metalsoftMachines.Items) == 0 {
+		return false, nil
+	}
+	log.FromContext(ctx).Info("Waiting for MetalsoftMachines to be deleted", "count", len(metalsoftMachines.Items))
+	conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+		infrastructurev1beta1.WaitingForMachinesDeletionReason, clusterv1.ConditionSeverityInfo,
+		"%d MetalsoftMachines remaining", len(metalsoftMachines.Items))
+	return true, nil
+}
+
+func (r *MetalsoftClusterReconciler) setFailure(metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, reason capierrors.ClusterStatusError, message string) {
+	metalsoftCluster.Status.FailureReason = &reason
+	metalsoftCluster.Status.FailureMessage = &message
+	conditions.MarkFalse(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition,
+		infrastructurev1beta1.InfrastructureProvisionFailedReason, clusterv1.ConditionSeverityError, message)
+}
+
+// deleteInstanceArrays stops the active instance arrays of an infrastructure
+// and deletes the others with their drive arrays, then deploys the changes.
+// Stopped instance arrays are deleted by the next call, once the deploy has
+// powered their servers off.
+func deleteInstanceArrays(ctx context.Context, infrastructureID int, instanceArrays []metalsoft.InstanceArray, msClient metalsoft.Client) error {
+	logger := log.FromContext(ctx)
+
+	driveArrays, err := msClient.ListDriveArrays(ctx, infrastructureID)
+	if err != nil {
+		return fmt.Errorf("failed to list MetalSoft drive arrays: %w", err)
+	}
+	for _, instanceArray := range instanceArrays {
+		if instanceArray.ServiceStatus == metalsoft.ServiceStatusActive {
+			logger.Info("Stopping MetalSoft instance array left in the infrastructure", "instanceArrayID", instanceArray.ID, "label", instanceArray.Label)
+			if err := msClient.StopInstanceArray(ctx, instanceArray.ID); err != nil && !metalsoft.IsNotFound(err) && !metalsoft.IsConflict(err) {
+				return fmt.Errorf("failed to stop MetalSoft instance array %d: %w", instanceArray.ID, err)
+			}
+			continue
+		}
+
+		logger.Info("Deleting MetalSoft instance array left in the infrastructure", "instanceArrayID", instanceArray.ID, "label", instanceArray.Label)
+		for _, driveArray := range driveArrays {
+			if driveArray.InstanceArrayID != instanceArray.ID {
+				continue
+			}
+			if err := msClient.DeleteDriveArray(ctx, driveArray.ID); err != nil && !metalsoft.IsNotFound(err) {
+				return fmt.Errorf("failed to delete MetalSoft drive array %d: %w", driveArray.ID, err)
+			}
+		}
+		if err := msClient.DeleteInstanceArray(ctx, instanceArray.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to delete MetalSoft instance array %d: %w", instanceArray.ID, err)
+		}
+	}
+	return deployInfrastructure(ctx, msClient, infrastructureID)
+}
+
+// deleteControlPlaneLoadBalancer marks the MetalSoft load balancer, floating
+// IP or reserved IP of the control plane endpoint for deletion.
+func deleteControlPlaneLoadBalancer(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) error {
+	status := metalsoftCluster.Status.ControlPlaneLoadBalancer
+	if metalsoftCluster.Spec.ControlPlaneLoadBalancer == nil || status == nil {
+		return nil
+	}
+	switch metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type {
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP:
+		if err := msClient.DeleteReservedIP(ctx, status.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to delete MetalSoft reserved IP %d: %w", status.ID, err)
+		}
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP:
+		if err := msClient.DeleteFloatingIP(ctx, status.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to delete MetalSoft floating IP %d: %w", status.ID, err)
+		}
+	default:
+		if err := msClient.DeleteLoadBalancer(ctx, status.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to delete MetalSoft load balancer %d: %w", status.ID, err)
+		}
+	}
+	return nil
+}
+
+// controlPlaneLoadBalancerExists tells whether the MetalSoft load balancer,
+// floating IP or reserved IP of the control plane endpoint still exists.
+func controlPlaneLoadBalancerExists(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (bool, error) {
+	status := metalsoftCluster.Status.ControlPlaneLoadBalancer
+	if metalsoftCluster.Spec.ControlPlaneLoadBalancer == nil || status == nil {
+		return false, nil
+	}
+	var err error
+	switch metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type {
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP:
+		_, err = msClient.GetReservedIP(ctx, status.ID)
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP:
+		_, err = msClient.GetFloatingIP(ctx, status.ID)
+	default:
+		_, err = msClient.GetLoadBalancer(ctx, status.ID)
+	}
+	if metalsoft.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get the MetalSoft control plane endpoint %d: %w", status.ID, err)
+	}
+	return true, nil
+}
+
+// controlPlaneEndpointLabel returns the label of the MetalSoft load balancer,
+// floating IP or reserved IP of the control plane endpoint of
+// metalsoftCluster.
+func controlPlaneEndpointLabel(metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) string {
+	return metalsoftCluster.Name + "-apiserver"
+}
+
+// infrastructureLabel returns the label of the MetalSoft infrastructure backing metalsoftCluster.
+func infrastructureLabel(metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) string {
+	if metalsoftCluster.Spec.InfrastructureLabel != "" {
+		return metalsoftCluster.Spec.InfrastructureLabel
+	}
+	return metalsoftCluster.Name
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MetalsoftClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	logger := mgr.GetLogger().WithName("metalsoftcluster")
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrastructurev1alpha1.MetalsoftCluster{}).
+		For(&infrastructurev1beta1.MetalsoftCluster{}).
+		WithEventFilter(predicates.ResourceNotPaused(logger)).
+		Watches(
+			&clusterv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(util.ClusterToInfrastructureMapFunc(context.Background(),
+				infrastructurev1beta1.GroupVersion.WithKind("MetalsoftCluster"), mgr.GetClient(), &infrastructurev1beta1.MetalsoftCluster{})),
+			builder.WithPredicates(predicates.ClusterUnpaused(logger)),
+		).
+		Watches(
+			&infrastructurev1beta1.MetalsoftMachine{},
+			handler.EnqueueRequestsFromMapFunc(r.metalsoftMachineToMetalsoftCluster),
+			builder.WithPredicates(predicate.Funcs{
+				CreateFunc:  func(event.CreateEvent) bool { return false },
+				UpdateFunc:  func(event.UpdateEvent) bool { return false },
+				DeleteFunc:  func(event.DeleteEvent) bool { return true },
+				GenericFunc: func(event.GenericEvent) bool { return false },
+			}),
+		).
+		Watches(
+			&infrastructurev1beta1.MetalsoftClusterIdentity{},
+			handler.EnqueueRequestsFromMapFunc(r.identityToMetalsoftClusters),
+		).
+		Watches(
+			&corev1.Namespace{},
+			handler.EnqueueRequestsFromMapFunc(r.namespaceToMetalsoftClusters),
+		).
 		Complete(r)
 }
+
+// identityToMetalsoftClusters maps a MetalsoftClusterIdentity to the
+// MetalsoftClusters using it, so that they notice changes of the allowed
+// namespaces.
+func (r *MetalsoftClusterReconciler) identityToMetalsoftClusters(ctx context.Context, o client.Object) []ctrl.Request {
+	metalsoftClusters := &infrastructurev1beta1.MetalsoftClusterList{}
+	if err := r.List(ctx, metalsoftClusters); err != nil {
+		return nil
+	}
+	var requests []ctrl.Request
+	for _, metalsoftCluster := range metalsoftClusters.Items {
+		if ref := metalsoftCluster.Spec.IdentityRef; ref != nil && ref.Name == o.GetName() {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&metalsoftCluster)})
+		}
+	}
+	return requests
+}
+
+// namespaceToMetalsoftClusters maps a Namespace to the MetalsoftClusters in it
+// using an identity, so that they notice label changes of the namespace.
+func (r *MetalsoftClusterReconciler) namespaceToMetalsoftClusters(ctx context.Context, o client.Object) []ctrl.Request {
+	metalsoftClusters := &infrastructurev1beta1.MetalsoftClusterList{}
+	if err := r.List(ctx, metalsoftClusters, client.InNamespace(o.GetName())); err != nil {
+		return nil
+	}
+	var requests []ctrl.Request
+	for _, metalsoftCluster := range metalsoftClusters.Items {
+		if metalsoftCluster.Spec.IdentityRef != nil {
+			requests = append(requests, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(&metalsoftCluster)})
+		}
+	}
+	return requests
+}
+
+// metalsoftMachineToMetalsoftCluster maps a MetalsoftMachine to the
+// MetalsoftCluster of its cluster, so that a cluster being deleted notices
+// when its last machine is gone.
+func (r *MetalsoftClusterReconciler) metalsoftMachineToMetalsoftCluster(ctx context.Context, o client.Object) []ctrl.Request {
+	clusterName, ok := o.GetLabels()[clusterv1.ClusterNameLabel]
+	if !ok {
+		return nil
+	}
+	cluster := &clusterv1.Cluster{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: o.GetNamespace(), Name: clusterName}, cluster); err != nil {
+		return nil
+	}
+	if cluster.Spec.InfrastructureRef == nil || cluster.Spec.InfrastructureRef.Kind != "MetalsoftCluster" {
+		return nil
+	}
+	return []ctrl.Request{{NamespacedName: client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}}}
+}
@@ -0,0 +1,556 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft/fake"
+)
+
+// newTestCluster creates a namespace holding a Cluster owning a MetalsoftCluster.
+func newTestCluster(name string, mutate func(*infrastructurev1beta1.MetalsoftCluster)) (*clusterv1.Cluster, *infrastructurev1beta1.MetalsoftCluster) {
+	namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: name + "-"}}
+	Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+	cluster := &clusterv1.Cluster{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace.Name},
+		Spec: clusterv1.ClusterSpec{
+			InfrastructureRef: &corev1.ObjectReference{
+				APIVersion: infrastructurev1beta1.GroupVersion.String(),
+				Kind:       "MetalsoftCluster",
+				Name:       name,
+				Namespace:  namespace.Name,
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+	metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace.Name,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: name},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Cluster",
+				Name:       cluster.Name,
+				UID:        cluster.UID,
+			}},
+		},
+		Spec: infrastructurev1beta1.MetalsoftClusterSpec{
+			Datacenter:          "us-chi-qts01-dc",
+			InfrastructureLabel: namespace.Name,
+			ControlPlaneEndpoint: clusterv1.APIEndpoint{
+				Host: "api." + name + ".example.com",
+			},
+		},
+	}
+	if mutate != nil {
+		mutate(metalsoftCluster)
+	}
+	Expect(k8sClient.Create(ctx, metalsoftCluster)).To(Succeed())
+	return cluster, metalsoftCluster
+}
+
+var _ = Describe("MetalsoftCluster controller", func() {
+	It("creates and deploys a MetalSoft infrastructure", func() {
+		_, metalsoftCluster := newTestCluster("deploy", nil)
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(metalsoftCluster.Finalizers).To(ContainElement(infrastructurev1beta1.ClusterFinalizer))
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(6443))
+		Expect(conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)).To(BeTrue())
+		Expect(metalsoftCluster.Status.InfrastructureID).NotTo(BeNil())
+		Expect(metalsoftCluster.Status.FailureDomains).To(HaveKeyWithValue("us-chi-qts01-dc", clusterv1.FailureDomainSpec{
+			ControlPlane: true,
+			Attributes:   map[string]string{"datacenter": "us-chi-qts01-dc"},
+		}))
+
+		infrastructure, ok := metalsoftServer.Infrastructure(*metalsoftCluster.Status.InfrastructureID)
+		Expect(ok).To(BeTrue())
+		Expect(infrastructure.Label).To(Equal(metalsoftCluster.Spec.InfrastructureLabel))
+		Expect(infrastructure.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	})
+
+	It("deletes the infrastructure once all machines are gone", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("delete", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "delete-md-0", nil)
+		setBootstrapData(machine, testBootstrapData)
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftMachine), metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+		infrastructureID := *metalsoftCluster.Status.InfrastructureID
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)).
+				To(Equal(infrastructurev1beta1.WaitingForMachinesDeletionReason))
+		}, timeout, interval).Should(Succeed())
+		_, ok := metalsoftServer.Infrastructure(infrastructureID)
+		Expect(ok).To(BeTrue())
+
+		Expect(k8sClient.Delete(ctx, metalsoftMachine)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+		_, ok = metalsoftServer.Infrastructure(infrastructureID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("stops and deletes instance arrays left in the infrastructure", func() {
+		_, metalsoftCluster := newReadyTestCluster("leftover", nil)
+		infrastructureID := *metalsoftCluster.Status.InfrastructureID
+
+		msClient, err := metalsoft.NewClient(metalsoftServer.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		instanceArray, err := msClient.CreateInstanceArray(ctx, infrastructureID, metalsoft.InstanceArray{Label: "leftover"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(msClient.DeployInfrastructure(ctx, infrastructureID)).To(Succeed())
+		Eventually(func(g Gomega) {
+			ia, ok := metalsoftServer.InstanceArray(instanceArray.ID)
+			g.Expect(ok).To(BeTrue())
+			g.Expect(ia.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+		}, timeout, interval).Should(Succeed())
+
+		stops := metalsoftServer.Calls("instance_array_stop")
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+
+		Expect(metalsoftServer.Calls("instance_array_stop")).To(BeNumerically(">", stops))
+		_, ok := metalsoftServer.InstanceArray(instanceArray.ID)
+		Expect(ok).To(BeFalse())
+		_, ok = metalsoftServer.Infrastructure(infrastructureID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("only deletes its own resources from an adopted infrastructure", func() {
+		msClient, err := metalsoft.NewClient(metalsoftServer.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		infrastructure, err := msClient.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "shared", Datacenter: "us-chi-qts01-dc"})
+		Expect(err).NotTo(HaveOccurred())
+		foreignNetwork, err := msClient.CreateNetwork(ctx, infrastructure.ID, metalsoft.Network{Label: "shared-lan", Type: metalsoft.NetworkTypeLAN})
+		Expect(err).NotTo(HaveOccurred())
+		foreignArray, err := msClient.CreateInstanceArray(ctx, infrastructure.ID, metalsoft.InstanceArray{Label: "foreign"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, metalsoftCluster := newTestCluster("adopted", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.InfrastructureID = &infrastructure.ID
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{
+				{Name: "shared-lan", Type: infrastructurev1beta1.NetworkTypeLAN},
+				{Name: "private", Type: infrastructurev1beta1.NetworkTypeLAN},
+			}
+			mc.Spec.ControlPlaneEndpoint.Host = ""
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP,
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftCluster.Status.InfrastructureCreated).To(BeFalse())
+		Expect(metalsoftCluster.Status.Networks).To(ContainElement(
+			infrastructurev1beta1.NetworkStatus{Name: "shared-lan", ID: foreignNetwork.ID, Type: infrastructurev1beta1.NetworkTypeLAN}))
+		Expect(metalsoftCluster.Status.CreatedNetworkIDs).To(HaveLen(1))
+		Expect(metalsoftCluster.Status.CreatedNetworkIDs).NotTo(ContainElement(foreignNetwork.ID))
+		floatingIPID := metalsoftCluster.Status.ControlPlaneLoadBalancer.ID
+
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+
+		_, ok := metalsoftServer.Infrastructure(infrastructure.ID)
+		Expect(ok).To(BeTrue())
+		_, ok = metalsoftServer.InstanceArray(foreignArray.ID)
+		Expect(ok).To(BeTrue())
+		_, ok = metalsoftServer.FloatingIP(floatingIPID)
+		Expect(ok).To(BeFalse())
+		var labels []string
+		for _, network := range metalsoftServer.Networks(infrastructure.ID) {
+			labels = append(labels, network.Label)
+		}
+		Expect(labels).To(ConsistOf("wan", "shared-lan"))
+	})
+
+	It("allocates a load balancer for the control plane endpoint", func() {
+		_, metalsoftCluster := newTestCluster("endpoint-lb", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type).To(Equal(infrastructurev1beta1.ControlPlaneLoadBalancerTypeLoadBalancer))
+		Expect(metalsoftCluster.Status.ControlPlaneLoadBalancer).NotTo(BeNil())
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(metalsoftCluster.Status.ControlPlaneLoadBalancer.Address))
+		Expect(conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.ControlPlaneEndpointReadyCondition)).To(BeTrue())
+
+		loadBalancerID := metalsoftCluster.Status.ControlPlaneLoadBalancer.ID
+		loadBalancer, ok := metalsoftServer.LoadBalancer(loadBalancerID)
+		Expect(ok).To(BeTrue())
+		Expect(loadBalancer.Port).To(Equal(6443))
+		Expect(loadBalancer.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+		_, ok = metalsoftServer.LoadBalancer(loadBalancerID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("reserves a floating IP for the control plane endpoint", func() {
+		_, metalsoftCluster := newTestCluster("endpoint-fip", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP,
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		floatingIP, ok := metalsoftServer.FloatingIP(metalsoftCluster.Status.ControlPlaneLoadBalancer.ID)
+		Expect(ok).To(BeTrue())
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(floatingIP.Address))
+		Expect(floatingIP.InstanceID).To(BeZero())
+	})
+
+	It("reserves the kube-vip address in the subnet of its WAN network", func() {
+		_, metalsoftCluster := newTestCluster("endpoint-vip", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{
+				{Name: "public", Type: infrastructurev1beta1.NetworkTypeWAN, PrefixLength: 28},
+			}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type:    infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP,
+				KubeVIP: &infrastructurev1beta1.KubeVIPSpec{Network: "public"},
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		status := metalsoftCluster.Status.ControlPlaneLoadBalancer
+		Expect(status.PrefixLength).To(Equal(28))
+		reservedIP, ok := metalsoftServer.ReservedIP(status.ID)
+		Expect(ok).To(BeTrue())
+		Expect(reservedIP.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(reservedIP.Address))
+		Expect(status.Address).To(Equal(reservedIP.Address))
+
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+		_, ok = metalsoftServer.ReservedIP(status.ID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("creates the networks of the cluster", func() {
+		_, metalsoftCluster := newTestCluster("networks", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{
+				{Name: "private", Type: infrastructurev1beta1.NetworkTypeLAN, VLANID: 100},
+				{Name: "storage", Type: infrastructurev1beta1.NetworkTypeSAN, SubnetPool: "san-pool", PrefixLength: 24},
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+		Expect(conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition)).To(BeTrue())
+		Expect(metalsoftCluster.Status.Networks).To(HaveLen(2))
+
+		networks := map[string]metalsoft.Network{}
+		for _, network := range metalsoftServer.Networks(*metalsoftCluster.Status.InfrastructureID) {
+			Expect(network.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+			networks[network.Label] = network
+		}
+		Expect(networks).To(HaveKey("wan"))
+		Expect(networks["private"].Type).To(Equal(metalsoft.NetworkTypeLAN))
+		Expect(networks["private"].VLANID).To(Equal(100))
+		Expect(networks["storage"].Type).To(Equal(metalsoft.NetworkTypeSAN))
+		Expect(networks["storage"].SubnetPool).To(Equal("san-pool"))
+		Expect(networks["storage"].SubnetPrefixSize).To(Equal(24))
+		for _, status := range metalsoftCluster.Status.Networks {
+			Expect(status.ID).To(Equal(networks[status.Name].ID))
+		}
+
+		By("adding a network to the deployed infrastructure")
+		metalsoftCluster.Spec.Networks = append(metalsoftCluster.Spec.Networks,
+			infrastructurev1beta1.NetworkSpec{Name: "backup", Type: infrastructurev1beta1.NetworkTypeLAN})
+		Expect(k8sClient.Update(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Networks).To(HaveLen(3))
+			g.Expect(conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("publishes failure domains from the racks of the datacenter", func() {
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "nyc-r1", Datacenter: "us-nyc-dc", SwitchGroup: "nyc-sg-a"})
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "nyc-r2", Datacenter: "us-nyc-dc", SwitchGroup: "nyc-sg-a"})
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "nyc-r3", Datacenter: "us-nyc-dc", SwitchGroup: "nyc-sg-b"})
+
+		_, metalsoftCluster := newTestCluster("failure-domains", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-nyc-dc"
+			mc.Spec.FailureDomains = []infrastructurev1beta1.FailureDomainSpec{
+				{Name: "zone-a", SwitchGroup: "nyc-sg-a", ControlPlane: true},
+				{Name: "zone-b", Racks: []string{"nyc-r3"}},
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+		Expect(conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition)).To(BeTrue())
+		Expect(metalsoftCluster.Status.FailureDomains).To(Equal(clusterv1.FailureDomains{
+			"zone-a": clusterv1.FailureDomainSpec{
+				ControlPlane: true,
+				Attributes:   map[string]string{"datacenter": "us-nyc-dc", "racks": "nyc-r1,nyc-r2"},
+			},
+			"zone-b": clusterv1.FailureDomainSpec{
+				Attributes: map[string]string{"datacenter": "us-nyc-dc", "racks": "nyc-r3"},
+			},
+		}))
+	})
+
+	It("reports failure domains referencing unknown racks", func() {
+		_, metalsoftCluster := newTestCluster("unknown-rack", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.FailureDomains = []infrastructurev1beta1.FailureDomainSpec{
+				{Name: "zone-a", Racks: []string{"does-not-exist"}},
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition)).
+				To(Equal(infrastructurev1beta1.FailureDomainsUnresolvedReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(conditions.GetMessage(metalsoftCluster, infrastructurev1beta1.FailureDomainsReadyCondition)).To(ContainSubstring("does-not-exist"))
+		Expect(metalsoftCluster.Status.Ready).To(BeFalse())
+	})
+
+	It("does not reconcile a paused cluster", func() {
+		_, metalsoftCluster := newTestCluster("paused", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Annotations = map[string]string{clusterv1.PausedAnnotation: "true"}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Consistently(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Finalizers).To(BeEmpty())
+			g.Expect(metalsoftCluster.Status.InfrastructureID).To(BeNil())
+		}, "2s", interval).Should(Succeed())
+	})
+
+	It("uses the MetalSoft tenant of its credentials Secret", func() {
+		tenant := fake.NewServer()
+		defer tenant.Close()
+
+		_, metalsoftCluster := newTestCluster("tenant", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			secret := newCredentialsSecret(mc.Namespace, "tenant-credentials", tenant.Credentials())
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: secret.Name}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		for server, exists := range map[*fake.Server]bool{tenant: true, metalsoftServer: false} {
+			msClient, err := metalsoft.NewClient(server.Credentials())
+			Expect(err).NotTo(HaveOccurred())
+			_, err = msClient.GetInfrastructureByLabel(ctx, metalsoftCluster.Spec.InfrastructureLabel)
+			Expect(metalsoft.IsNotFound(err)).To(Equal(!exists))
+		}
+	})
+
+	It("reports a missing credentials Secret", func() {
+		_, metalsoftCluster := newTestCluster("no-credentials", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: "does-not-exist"}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)).
+				To(Equal(infrastructurev1beta1.CredentialsUnavailableReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftCluster.Status.InfrastructureID).To(BeNil())
+	})
+
+	It("is deleted without credentials when no infrastructure was created", func() {
+		_, metalsoftCluster := newTestCluster("delete-no-credentials", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Finalizers = []string{infrastructurev1beta1.ClusterFinalizer}
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: "does-not-exist"}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("keeps its finalizer until the credentials to delete the infrastructure are back", func() {
+		var secret *corev1.Secret
+		_, metalsoftCluster := newTestCluster("delete-credentials", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			secret = newCredentialsSecret(mc.Namespace, "credentials", metalsoftServer.Credentials())
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: secret.Name}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+		infrastructureID := *metalsoftCluster.Status.InfrastructureID
+
+		Expect(k8sClient.Delete(ctx, secret)).To(Succeed())
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)).
+				To(Equal(infrastructurev1beta1.CredentialsUnavailableReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftCluster.Finalizers).To(ContainElement(infrastructurev1beta1.ClusterFinalizer))
+		_, ok := metalsoftServer.Infrastructure(infrastructureID)
+		Expect(ok).To(BeTrue())
+
+		newCredentialsSecret(metalsoftCluster.Namespace, secret.Name, metalsoftServer.Credentials())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+		_, ok = metalsoftServer.Infrastructure(infrastructureID)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("only uses an identity from the namespaces it allows", func() {
+		secret := newCredentialsSecret("capms-system", "identity-credentials", metalsoftServer.Credentials())
+		identity := &infrastructurev1beta1.MetalsoftClusterIdentity{
+			ObjectMeta: metav1.ObjectMeta{Name: "identity"},
+			Spec: infrastructurev1beta1.MetalsoftClusterIdentitySpec{
+				SecretName: secret.Name,
+				AllowedNamespaces: &infrastructurev1beta1.AllowedNamespaces{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"metalsoft.io/tenant": "identity"}},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, identity)).To(Succeed())
+
+		_, metalsoftCluster := newTestCluster("identity", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.IdentityRef = &infrastructurev1beta1.MetalsoftClusterIdentityReference{
+				Kind: infrastructurev1beta1.MetalsoftClusterIdentityKind,
+				Name: identity.Name,
+			}
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftCluster, infrastructurev1beta1.InfrastructureReadyCondition)).
+				To(Equal(infrastructurev1beta1.NamespaceNotAllowedByIdentityReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftCluster.Status.InfrastructureID).To(BeNil())
+
+		namespace := &corev1.Namespace{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Name: metalsoftCluster.Namespace}, namespace)).To(Succeed())
+		namespace.Labels["metalsoft.io/tenant"] = "identity"
+		Expect(k8sClient.Update(ctx, namespace)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("rejects both credentialsRef and identityRef", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "exclusive-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "exclusive", Namespace: namespace.Name},
+			Spec: infrastructurev1beta1.MetalsoftClusterSpec{
+				Datacenter:     "us-chi-qts01-dc",
+				CredentialsRef: &corev1.LocalObjectReference{Name: "credentials"},
+				IdentityRef: &infrastructurev1beta1.MetalsoftClusterIdentityReference{
+					Kind: infrastructurev1beta1.MetalsoftClusterIdentityKind,
+					Name: "identity",
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, metalsoftCluster)).To(MatchError(ContainSubstring("mutually exclusive")))
+	})
+
+	It("fails when adopting an infrastructure from another datacenter", func() {
+		msClient, err := metalsoft.NewClient(metalsoftServer.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		infrastructure, err := msClient.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "elsewhere", Datacenter: "eu-fra-dc"})
+		Expect(err).NotTo(HaveOccurred())
+
+		_, metalsoftCluster := newTestCluster("adopt", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.InfrastructureID = &infrastructure.ID
+		})
+		key := client.ObjectKeyFromObject(metalsoftCluster)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.FailureReason).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftCluster.Status.Ready).To(BeFalse())
+	})
+})
@@ -0,0 +1,187 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// newTestClusterClass creates a ClusterClass backed by a MetalsoftClusterTemplate
+// and a KubeadmControlPlaneTemplate running on MetalsoftMachineTemplate
+// machines. The control plane endpoint host is patched in from the Cluster
+// name, as the template is shared by all the clusters of the class. The port
+// is set explicitly since the defaulting webhook is not served by envtest.
+func newTestClusterClass(namespace string) *clusterv1.ClusterClass {
+	clusterTemplate := &infrastructurev1beta1.MetalsoftClusterTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "metalsoft", Namespace: namespace},
+		Spec: infrastructurev1beta1.MetalsoftClusterTemplateSpec{
+			Template: infrastructurev1beta1.MetalsoftClusterTemplateResource{
+				Spec: infrastructurev1beta1.MetalsoftClusterSpec{
+					Datacenter: "us-chi-qts01-dc",
+					ControlPlaneEndpoint: clusterv1.APIEndpoint{
+						Port: infrastructurev1beta1.DefaultAPIServerPort,
+					},
+				},
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, clusterTemplate)).To(Succeed())
+
+	machineTemplate := &infrastructurev1beta1.MetalsoftMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{Name: "metalsoft-control-plane", Namespace: namespace},
+		Spec: infrastructurev1beta1.MetalsoftMachineTemplateSpec{
+			Template: infrastructurev1beta1.MetalsoftMachineTemplateResource{
+				Spec: infrastructurev1beta1.MetalsoftMachineSpec{
+					ServerType:      "M.16.64.v2",
+					OSTemplate:      "ubuntu-22-04",
+					BootDriveSizeGB: 40,
+				},
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, machineTemplate)).To(Succeed())
+
+	controlPlaneTemplate := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "controlplane.cluster.x-k8s.io/v1beta1",
+		"kind":       "KubeadmControlPlaneTemplate",
+		"metadata":   map[string]interface{}{"name": "kubeadm", "namespace": namespace},
+		"spec": map[string]interface{}{
+			"template": map[string]interface{}{
+				"spec": map[string]interface{}{
+					"kubeadmConfigSpec": map[string]interface{}{},
+				},
+			},
+		},
+	}}
+	Expect(k8sClient.Create(ctx, controlPlaneTemplate)).To(Succeed())
+
+	clusterClass := &clusterv1.ClusterClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "metalsoft", Namespace: namespace},
+		Spec: clusterv1.ClusterClassSpec{
+			Infrastructure: clusterv1.LocalObjectTemplate{
+				Ref: objectReference(clusterTemplate, "MetalsoftClusterTemplate"),
+			},
+			ControlPlane: clusterv1.ControlPlaneClass{
+				LocalObjectTemplate: clusterv1.LocalObjectTemplate{
+					Ref: &corev1.ObjectReference{
+						APIVersion: controlPlaneTemplate.GetAPIVersion(),
+						Kind:       controlPlaneTemplate.GetKind(),
+						Name:       controlPlaneTemplate.GetName(),
+						Namespace:  namespace,
+					},
+				},
+				MachineInfrastructure: &clusterv1.LocalObjectTemplate{
+					Ref: objectReference(machineTemplate, "MetalsoftMachineTemplate"),
+				},
+			},
+			Patches: []clusterv1.ClusterClassPatch{{
+				Name: "controlPlaneEndpoint",
+				Definitions: []clusterv1.PatchDefinition{{
+					Selector: clusterv1.PatchSelector{
+						APIVersion:     infrastructurev1beta1.GroupVersion.String(),
+						Kind:           "MetalsoftClusterTemplate",
+						MatchResources: clusterv1.PatchSelectorMatch{InfrastructureCluster: true},
+					},
+					JSONPatches: []clusterv1.JSONPatch{{
+						Op:   "add",
+						Path: "/spec/template/spec/controlPlaneEndpoint/host",
+						ValueFrom: &clusterv1.JSONPatchValue{
+							Template: pointer.String("api.{{ .builtin.cluster.name }}.example.com"),
+						},
+					}},
+				}},
+			}},
+		},
+	}
+	Expect(k8sClient.Create(ctx, clusterClass)).To(Succeed())
+	return clusterClass
+}
+
+func objectReference(obj client.Object, kind string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{
+		APIVersion: infrastructurev1beta1.GroupVersion.String(),
+		Kind:       kind,
+		Name:       obj.GetName(),
+		Namespace:  obj.GetNamespace(),
+	}
+}
+
+var _ = Describe("MetalsoftClusterTemplate", func() {
+	It("provisions a MetalsoftCluster for a ClusterClass topology", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "topology-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+		clusterClass := newTestClusterClass(namespace.Name)
+
+		cluster := &clusterv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "topology", Namespace: namespace.Name},
+			Spec: clusterv1.ClusterSpec{
+				Topology: &clusterv1.Topology{
+					Class:   clusterClass.Name,
+					Version: "v1.27.3",
+					ControlPlane: clusterv1.ControlPlaneTopology{
+						Replicas: pointer.Int32(1),
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, cluster)).To(Succeed())
+
+		By("waiting for the topology controller to create the MetalsoftCluster")
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cluster), cluster)).To(Succeed())
+			g.Expect(cluster.Spec.InfrastructureRef).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(cluster.Spec.InfrastructureRef.Kind).To(Equal("MetalsoftCluster"))
+
+		metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{}
+		key := client.ObjectKey{Namespace: namespace.Name, Name: cluster.Spec.InfrastructureRef.Name}
+		Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+		Expect(metalsoftCluster.Labels).To(HaveKeyWithValue(clusterv1.ClusterNameLabel, cluster.Name))
+		Expect(metalsoftCluster.Spec.Datacenter).To(Equal("us-chi-qts01-dc"))
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal("api.topology.example.com"))
+
+		By("taking ownership of the MetalsoftCluster like the Cluster controller would")
+		metalsoftCluster.OwnerReferences = append(metalsoftCluster.OwnerReferences, metav1.OwnerReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "Cluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		})
+		Expect(k8sClient.Update(ctx, metalsoftCluster)).To(Succeed())
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+			g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		infrastructure, ok := metalsoftServer.Infrastructure(*metalsoftCluster.Status.InfrastructureID)
+		Expect(ok).To(BeTrue())
+		Expect(infrastructure.Label).To(Equal(metalsoftCluster.Name))
+		Expect(infrastructure.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	})
+})
@@ -0,0 +1,363 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// errHostOfOtherInventory is returned when mirroring a server onto a
+// MetalsoftHost mirrored from another MetalSoft inventory.
+var errHostOfOtherInventory = errors.New("MetalsoftHost mirrored from another MetalSoft inventory")
+
+// DefaultHostResyncPeriod is how often the MetalsoftHosts of a datacenter are
+// refreshed from the MetalSoft server inventory by default.
+const DefaultHostResyncPeriod = 5 * time.Minute
+
+// MetalsoftHostReconciler mirrors the MetalSoft server inventory of the
+// datacenter of each MetalsoftCluster into MetalsoftHosts of its namespace.
+type MetalsoftHostReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// MetalsoftClientFactory creates the MetalSoft API client used by the
+	// reconciler. It defaults to metalsoft.NewClient.
+	MetalsoftClientFactory metalsoft.ClientFactory
+	// DefaultCredentialsSecret is the Secret holding the MetalSoft
+	// credentials of clusters without spec.credentialsRef. When nil, such
+	// clusters cannot be reconciled.
+	DefaultCredentialsSecret *client.ObjectKey
+	// ControllerNamespace is the namespace the controller runs in, holding
+	// the Secrets of MetalsoftClusterIdentities.
+	ControllerNamespace string
+	// ResyncPeriod is how often the inventory is refreshed. It defaults to
+	// DefaultHostResyncPeriod.
+	ResyncPeriod time.Duration
+}
+
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsofthosts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsofthosts/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftclusters,verbs=get;list;watch
+
+// Reconcile creates, refreshes and deletes the MetalsoftHosts of the
+// datacenter of a MetalsoftCluster so they match the MetalSoft servers of
+// the datacenter, and requeues itself to pick up inventory changes. Once the
+// cluster is deleted, the hosts no other cluster mirrors are deleted.
+func (r *MetalsoftHostReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	resyncPeriod := r.ResyncPeriod
+	if resyncPeriod == 0 {
+		resyncPeriod = DefaultHostResyncPeriod
+	}
+
+	// The hosts are shared by the clusters of the namespace, so they outlive
+	// the cluster they were mirrored for until no other cluster mirrors them.
+	metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{}
+	if err := r.Get(ctx, req.NamespacedName, metalsoftCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.reconcileOrphanedHosts(ctx, req.Namespace, resyncPeriod)
+		}
+		return ctrl.Result{}, err
+	}
+	if !metalsoftCluster.DeletionTimestamp.IsZero() {
+		return r.reconcileOrphanedHosts(ctx, req.Namespace, resyncPeriod)
+	}
+	// Keep polling paused clusters, as unpausing them does not change
+	// their generation.
+	if annotations.HasPaused(metalsoftCluster) {
+		logger.Info("MetalsoftCluster is marked as paused, not refreshing its hosts")
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+
+	creds, err := getCredentials(ctx, r.Client, r.DefaultCredentialsSecret, r.ControllerNamespace, metalsoftCluster)
+	if errors.Is(err, errNamespaceNotAllowed) {
+		logger.Info("Refusing to mirror the MetalSoft inventory", "reason", err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	msClient, err := newMetalsoftClientFor(r.MetalsoftClientFactory, creds)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileHosts(ctx, metalsoftCluster, inventoryID(creds), msClient); err != nil {
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+}
+
+// reconcileHosts mirrors the servers of the datacenter of the cluster into
+// MetalsoftHosts named after their server ID and the inventory they were
+// mirrored from, which they are also labelled with.
+func (r *MetalsoftHostReconciler) reconcileHosts(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, inventoryID string, msClient metalsoft.Client) error {
+	logger := log.FromContext(ctx)
+	datacenter := metalsoftCluster.Spec.Datacenter
+
+	servers, err := msClient.ListServers(ctx, datacenter)
+	if err != nil {
+		return fmt.Errorf("failed to list MetalSoft servers of datacenter %q: %w", datacenter, err)
+	}
+	serverTypes, err := msClient.ListServerTypes(ctx, datacenter)
+	if err != nil {
+		return fmt.Errorf("failed to list MetalSoft server types: %w", err)
+	}
+	serverTypeNames := map[int]string{}
+	for _, serverType := range serverTypes {
+		serverTypeNames[serverType.ID] = serverType.Name
+	}
+
+	inventory := map[string]bool{}
+	now := metav1.Now()
+	for i := range servers {
+		server := &servers[i]
+		host := &infrastructurev1beta1.MetalsoftHost{ObjectMeta: metav1.ObjectMeta{
+			Name:      hostName(inventoryID, server.ID),
+			Namespace: metalsoftCluster.Namespace,
+		}}
+		inventory[host.Name] = true
+		result, err := controllerutil.CreateOrPatch(ctx, r.Client, host, func() error {
+			if host.Labels == nil {
+				host.Labels = map[string]string{}
+			}
+			if inventory, ok := host.Labels[infrastructurev1beta1.HostInventoryLabel]; ok && inventory != inventoryID {
+				return errHostOfOtherInventory
+			}
+			host.Labels[infrastructurev1beta1.HostDatacenterLabel] = server.Datacenter
+			host.Labels[infrastructurev1beta1.HostInventoryLabel] = inventoryID
+			setOrDeleteLabel(host.Labels, infrastructurev1beta1.HostServerTypeLabel, serverTypeNames[server.ServerTypeID])
+			setOrDeleteLabel(host.Labels, infrastructurev1beta1.HostRackLabel, server.RackLabel)
+			host.Spec.ServerID = server.ID
+			return nil
+		})
+		if errors.Is(err, errHostOfOtherInventory) {
+			logger.Info("Not mirroring MetalSoft server over a MetalsoftHost of another inventory", "MetalsoftHost", host.Name, "serverID", server.ID)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to mirror MetalSoft server %d: %w", server.ID, err)
+		}
+		if result == controllerutil.OperationResultCreated {
+			logger.Info("Mirrored MetalSoft server", "MetalsoftHost", host.Name, "serverID", server.ID)
+		}
+		// The status is not written when a host is created, so it is always
+		// patched on its own.
+		base := host.DeepCopy()
+		host.Status = hostStatus(server, serverTypeNames[server.ServerTypeID], now)
+		if err := r.Status().Patch(ctx, host, client.MergeFrom(base)); err != nil {
+			return fmt.Errorf("failed to update the status of MetalsoftHost %s: %w", host.Name, err)
+		}
+	}
+
+	// Hosts mirrored from the inventory of other credentials are left to
+	// the clusters using them.
+	hosts := &infrastructurev1beta1.MetalsoftHostList{}
+	if err := r.List(ctx, hosts, client.InNamespace(metalsoftCluster.Namespace), client.MatchingLabels{
+		infrastructurev1beta1.HostDatacenterLabel: datacenter,
+		infrastructurev1beta1.HostInventoryLabel:  inventoryID,
+	}); err != nil {
+		return fmt.Errorf("failed to list MetalsoftHosts: %w", err)
+	}
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		if inventory[host.Name] {
+			continue
+		}
+		// A claimed host is kept until its machine releases it, as the
+		// machine tracks its server through it.
+		if host.Spec.ConsumerRef != nil {
+			if host.Status.AllocationState == infrastructurev1beta1.AllocationStateGone {
+				continue
+			}
+			logger.Info("Server of a claimed MetalsoftHost is gone from the MetalSoft inventory", "MetalsoftHost", host.Name)
+			base := host.DeepCopy()
+			host.Status.AllocationState = infrastructurev1beta1.AllocationStateGone
+			host.Status.LastUpdated = &now
+			if err := r.Status().Patch(ctx, host, client.MergeFrom(base)); err != nil && !apierrors.IsNotFound(err) {
+				return fmt.Errorf("failed to update the status of MetalsoftHost %s: %w", host.Name, err)
+			}
+			continue
+		}
+		logger.Info("Deleting MetalsoftHost of a server gone from the MetalSoft inventory", "MetalsoftHost", host.Name)
+		if err := r.Delete(ctx, host); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("failed to delete MetalsoftHost %s: %w", host.Name, err)
+		}
+	}
+	return nil
+}
+
+// mirroredInventory is an inventory and datacenter whose servers are
+// mirrored into MetalsoftHosts.
+type mirroredInventory struct {
+	inventoryID string
+	datacenter  string
+}
+
+// reconcileOrphanedHosts deletes the unclaimed MetalsoftHosts of a namespace
+// mirrored from an inventory and datacenter no MetalsoftCluster of the
+// namespace mirrors anymore. Claimed hosts are kept until their machines
+// release them, so it is requeued after resyncPeriod while any is left.
+func (r *MetalsoftHostReconciler) reconcileOrphanedHosts(ctx context.Context, namespace string, resyncPeriod time.Duration) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	metalsoftClusters := &infrastructurev1beta1.MetalsoftClusterList{}
+	if err := r.List(ctx, metalsoftClusters, client.InNamespace(namespace)); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalsoftClusters: %w", err)
+	}
+	mirrored := map[mirroredInventory]bool{}
+	for i := range metalsoftClusters.Items {
+		metalsoftCluster := &metalsoftClusters.Items[i]
+		if !metalsoftCluster.DeletionTimestamp.IsZero() {
+			continue
+		}
+		creds, err := getCredentials(ctx, r.Client, r.DefaultCredentialsSecret, r.ControllerNamespace, metalsoftCluster)
+		if errors.Is(err, errNamespaceNotAllowed) {
+			continue
+		}
+		// Hosts cannot be told orphaned without the inventory of every
+		// cluster that may mirror them.
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		mirrored[mirroredInventory{inventoryID: inventoryID(creds), datacenter: metalsoftCluster.Spec.Datacenter}] = true
+	}
+
+	hosts := &infrastructurev1beta1.MetalsoftHostList{}
+	if err := r.List(ctx, hosts, client.InNamespace(namespace), client.HasLabels{infrastructurev1beta1.HostInventoryLabel}); err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalsoftHosts: %w", err)
+	}
+	claimed := false
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		if mirrored[mirroredInventory{
+			inventoryID: host.Labels[infrastructurev1beta1.HostInventoryLabel],
+			datacenter:  host.Labels[infrastructurev1beta1.HostDatacenterLabel],
+		}] {
+			continue
+		}
+		if host.Spec.ConsumerRef != nil {
+			claimed = true
+			continue
+		}
+		logger.Info("Deleting MetalsoftHost no MetalsoftCluster mirrors anymore", "MetalsoftHost", host.Name)
+		if err := r.Delete(ctx, host); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to delete MetalsoftHost %s: %w", host.Name, err)
+		}
+	}
+	if claimed {
+		return ctrl.Result{RequeueAfter: resyncPeriod}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// inventoryID returns the value of the inventory label of the hosts mirrored
+// with creds, identifying the MetalSoft account they belong to. The API key
+// is left out so that rotating it keeps the hosts.
+func inventoryID(creds metalsoft.Credentials) string {
+	sum := sha256.Sum256([]byte(creds.Endpoint + "\n" + creds.UserEmail))
+	return hex.EncodeToString(sum[:16])
+}
+
+// hostName returns the name of the MetalsoftHost of a MetalSoft server of
+// an inventory. Server IDs are only unique within a MetalSoft deployment, so
+// the name ends with the start of the inventory ID to keep apart the hosts of
+// accounts of different deployments mirrored in the same namespace.
+func hostName(inventoryID string, serverID int) string {
+	return fmt.Sprintf("server-%d-%s", serverID, inventoryID[:8])
+}
+
+// hostStatus returns the MetalsoftHost status of a MetalSoft server.
+func hostStatus(server *metalsoft.Server, serverType string, now metav1.Time) infrastructurev1beta1.MetalsoftHostStatus {
+	status := infrastructurev1beta1.MetalsoftHostStatus{
+		Datacenter:   server.Datacenter,
+		ServerType:   serverType,
+		Rack:         server.RackLabel,
+		SerialNumber: server.SerialNumber,
+		Hardware: &infrastructurev1beta1.HardwareDetails{
+			CPU: infrastructurev1beta1.CPU{
+				Model: server.ProcessorName,
+				Count: server.ProcessorCount,
+				Cores: server.ProcessorCoreCount,
+			},
+			RAMGB: server.RAMGB,
+		},
+		PowerState:      infrastructurev1beta1.PowerStateOff,
+		AllocationState: infrastructurev1beta1.AllocationStateAvailable,
+		LastUpdated:     &now,
+	}
+	for _, disk := range server.Disks {
+		status.Hardware.Disks = append(status.Hardware.Disks, infrastructurev1beta1.Disk{SizeGB: disk.SizeGB, Type: disk.Type})
+	}
+	for _, nic := range server.Interfaces {
+		status.Hardware.NICs = append(status.Hardware.NICs, infrastructurev1beta1.NIC{MACAddress: nic.MACAddress, SpeedMbps: nic.CapacityMbps})
+	}
+	if server.PowerStatus == metalsoft.ServerPowerStatusOn {
+		status.PowerState = infrastructurev1beta1.PowerStateOn
+	}
+	switch server.Status {
+	case metalsoft.ServerStatusAvailable:
+	case metalsoft.ServerStatusReserved:
+		status.AllocationState = infrastructurev1beta1.AllocationStateReserved
+	default:
+		status.AllocationState = infrastructurev1beta1.AllocationStateAllocated
+	}
+	if server.InstanceID != 0 {
+		instanceID := server.InstanceID
+		status.InstanceID = &instanceID
+	}
+	return status
+}
+
+// setOrDeleteLabel sets a label, or deletes it when value is empty.
+func setOrDeleteLabel(labels map[string]string, key, value string) {
+	if value == "" {
+		delete(labels, key)
+		return
+	}
+	labels[key] = value
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *MetalsoftHostReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// The inventory is refreshed periodically; status changes of the
+	// clusters do not need to trigger a refresh.
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("metalsofthost").
+		For(&infrastructurev1beta1.MetalsoftCluster{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Complete(r)
+}
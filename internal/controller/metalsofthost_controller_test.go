@@ -0,0 +1,522 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft/fake"
+)
+
+// claimingClient claims MetalsoftHosts for consumerRef right before updating
+// them, like another machine reconciled concurrently would.
+type claimingClient struct {
+	client.Client
+	consumerRef *corev1.ObjectReference
+}
+
+func (c claimingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	if host, ok := obj.(*infrastructurev1beta1.MetalsoftHost); ok {
+		current := &infrastructurev1beta1.MetalsoftHost{}
+		if err := c.Client.Get(ctx, client.ObjectKeyFromObject(host), current); err != nil {
+			return err
+		}
+		current.Spec.ConsumerRef = c.consumerRef
+		if err := c.Client.Update(ctx, current); err != nil {
+			return err
+		}
+	}
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// testHostName returns the name of the MetalsoftHost of a server of the
+// fake MetalSoft API used by default.
+func testHostName(serverID int) string {
+	return hostName(inventoryID(metalsoftServer.Credentials()), serverID)
+}
+
+var _ = Describe("MetalsoftHost controller", func() {
+	It("mirrors the servers of the datacenter of a cluster", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		server := metalsoftServer.AddServer(metalsoft.Server{
+			ServerTypeID:       serverType.ID,
+			Datacenter:         "us-sea-dc",
+			RackLabel:          "sea-r1",
+			SerialNumber:       "SN-0001",
+			ProcessorName:      "Intel Xeon Silver 4314",
+			ProcessorCount:     1,
+			ProcessorCoreCount: 16,
+			RAMGB:              64,
+			Disks:              []metalsoft.ServerDisk{{SizeGB: 960, Type: metalsoft.DiskTypeSSD}},
+			Interfaces:         []metalsoft.ServerInterface{{MACAddress: "0c:42:a1:00:00:01", CapacityMbps: 25000}},
+		})
+		decommissioned := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-sea-dc"})
+		elsewhere := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-den-dc"})
+
+		_, metalsoftCluster := newTestCluster("hosts", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-sea-dc"
+		})
+
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(server.ID)}, host)).To(Succeed())
+			g.Expect(host.Status.LastUpdated).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(host.Spec.ServerID).To(Equal(server.ID))
+		Expect(host.Labels).To(HaveKeyWithValue(infrastructurev1beta1.HostDatacenterLabel, "us-sea-dc"))
+		Expect(host.Labels).To(HaveKeyWithValue(infrastructurev1beta1.HostServerTypeLabel, "M.16.64.v2"))
+		Expect(host.Labels).To(HaveKeyWithValue(infrastructurev1beta1.HostRackLabel, "sea-r1"))
+		Expect(host.Status.SerialNumber).To(Equal("SN-0001"))
+		Expect(host.Status.PowerState).To(Equal(infrastructurev1beta1.PowerStateOff))
+		Expect(host.Status.AllocationState).To(Equal(infrastructurev1beta1.AllocationStateAvailable))
+		Expect(host.Status.Hardware).NotTo(BeNil())
+		Expect(host.Status.Hardware.CPU.Cores).To(Equal(16))
+		Expect(host.Status.Hardware.RAMGB).To(Equal(64))
+		Expect(host.Status.Hardware.Disks).To(Equal([]infrastructurev1beta1.Disk{{SizeGB: 960, Type: metalsoft.DiskTypeSSD}}))
+		Expect(host.Status.Hardware.NICs).To(Equal([]infrastructurev1beta1.NIC{{MACAddress: "0c:42:a1:00:00:01", SpeedMbps: 25000}}))
+
+		decommissionedKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(decommissioned.ID)}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, decommissionedKey, &infrastructurev1beta1.MetalsoftHost{})).To(Succeed())
+		}, timeout, interval).Should(Succeed())
+		err := k8sClient.Get(ctx, client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(elsewhere.ID)}, &infrastructurev1beta1.MetalsoftHost{})
+		Expect(apierrors.IsNotFound(err)).To(BeTrue())
+
+		By("deleting the hosts of servers gone from the inventory")
+		metalsoftServer.RemoveServer(decommissioned.ID)
+		Eventually(func(g Gomega) {
+			err := k8sClient.Get(ctx, decommissionedKey, &infrastructurev1beta1.MetalsoftHost{})
+			g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("only deletes the unclaimed hosts of its own inventory", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		kept := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-mia-dc"})
+		claimed := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-mia-dc"})
+
+		_, metalsoftCluster := newTestCluster("hosts-shared", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-mia-dc"
+		})
+		keptKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(kept.ID)}
+		claimedKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(claimed.ID)}
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, keptKey, host)).To(Succeed())
+			g.Expect(k8sClient.Get(ctx, claimedKey, host)).To(Succeed())
+		}, timeout, interval).Should(Succeed())
+		Expect(host.Labels).To(HaveKeyWithValue(infrastructurev1beta1.HostInventoryLabel, inventoryID(metalsoftServer.Credentials())))
+		Expect(k8sClient.Get(ctx, keptKey, host)).To(Succeed())
+		keptUID := host.UID
+
+		By("mirroring the same datacenter with credentials that see none of its servers")
+		tenant := fake.NewServer()
+		defer tenant.Close()
+		secret := newCredentialsSecret(metalsoftCluster.Namespace, "tenant-credentials", tenant.Credentials())
+		Expect(k8sClient.Create(ctx, &infrastructurev1beta1.MetalsoftCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "hosts-tenant", Namespace: metalsoftCluster.Namespace},
+			Spec: infrastructurev1beta1.MetalsoftClusterSpec{
+				Datacenter:     "us-mia-dc",
+				CredentialsRef: &corev1.LocalObjectReference{Name: secret.Name},
+			},
+		})).To(Succeed())
+		Eventually(func() int {
+			return tenant.Calls("servers")
+		}, timeout, interval).Should(BeNumerically(">=", 2))
+		Expect(k8sClient.Get(ctx, keptKey, host)).To(Succeed())
+		Expect(host.UID).To(Equal(keptUID))
+
+		By("keeping claimed hosts of servers gone from the inventory")
+		Expect(k8sClient.Get(ctx, claimedKey, host)).To(Succeed())
+		host.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "MetalsoftMachine", Namespace: host.Namespace, Name: "consumer", UID: "consumer-uid"}
+		Expect(k8sClient.Update(ctx, host)).To(Succeed())
+		metalsoftServer.RemoveServer(claimed.ID)
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, claimedKey, host)).To(Succeed())
+			g.Expect(host.Status.AllocationState).To(Equal(infrastructurev1beta1.AllocationStateGone))
+		}, timeout, interval).Should(Succeed())
+
+		host.Spec.ConsumerRef = nil
+		Expect(k8sClient.Update(ctx, host)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, claimedKey, host))
+		}, timeout, interval).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, keptKey, host)).To(Succeed())
+		Expect(host.UID).To(Equal(keptUID))
+	})
+
+	It("deletes the unclaimed hosts of an inventory once no cluster mirrors it", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		server := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-atl-dc"})
+		tenant := fake.NewServer()
+		defer tenant.Close()
+		tenantServerType, ok := tenant.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		tenantServer := tenant.AddServer(metalsoft.Server{ServerTypeID: tenantServerType.ID, Datacenter: "us-atl-dc"})
+
+		_, metalsoftCluster := newTestCluster("hosts-gc", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-atl-dc"
+		})
+		newMetalsoftCluster := func(name string, credentialsRef *corev1.LocalObjectReference) *infrastructurev1beta1.MetalsoftCluster {
+			mc := &infrastructurev1beta1.MetalsoftCluster{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: metalsoftCluster.Namespace},
+				Spec:       infrastructurev1beta1.MetalsoftClusterSpec{Datacenter: "us-atl-dc", CredentialsRef: credentialsRef},
+			}
+			Expect(k8sClient.Create(ctx, mc)).To(Succeed())
+			return mc
+		}
+		secret := newCredentialsSecret(metalsoftCluster.Namespace, "tenant-credentials", tenant.Credentials())
+		tenantCluster := newMetalsoftCluster("hosts-gc-tenant", &corev1.LocalObjectReference{Name: secret.Name})
+		otherCluster := newMetalsoftCluster("hosts-gc-other", nil)
+
+		hostKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(server.ID)}
+		tenantHostKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: hostName(inventoryID(tenant.Credentials()), tenantServer.ID)}
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+			g.Expect(k8sClient.Get(ctx, tenantHostKey, host)).To(Succeed())
+		}, timeout, interval).Should(Succeed())
+
+		By("deleting the hosts of the inventory of the deleted tenant cluster")
+		Expect(k8sClient.Delete(ctx, tenantCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, tenantHostKey, host))
+		}, timeout, interval).Should(BeTrue())
+
+		By("keeping the hosts of an inventory another cluster still mirrors")
+		Expect(k8sClient.Delete(ctx, otherCluster)).To(Succeed())
+		Consistently(func() error {
+			return k8sClient.Get(ctx, hostKey, host)
+		}, 3*time.Second, interval).Should(Succeed())
+
+		By("keeping claimed hosts until their machines release them")
+		// The status of the host is refreshed meanwhile, so it is patched
+		// rather than updated.
+		base := host.DeepCopy()
+		host.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "MetalsoftMachine", Namespace: host.Namespace, Name: "consumer", UID: "consumer-uid"}
+		Expect(k8sClient.Patch(ctx, host, client.MergeFrom(base))).To(Succeed())
+		Expect(k8sClient.Delete(ctx, metalsoftCluster)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftCluster), metalsoftCluster))
+		}, timeout, interval).Should(BeTrue())
+		Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+
+		base = host.DeepCopy()
+		host.Spec.ConsumerRef = nil
+		Expect(k8sClient.Patch(ctx, host, client.MergeFrom(base))).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, hostKey, host))
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("mirrors servers with the same ID from different inventories", func() {
+		inventories := []*fake.Server{fake.NewServer(), fake.NewServer()}
+		var servers []metalsoft.Server
+		for i, inventory := range inventories {
+			defer inventory.Close()
+			serverType, ok := inventory.ServerType("M.16.64.v2")
+			Expect(ok).To(BeTrue())
+			servers = append(servers, inventory.AddServer(metalsoft.Server{
+				ServerTypeID: serverType.ID,
+				Datacenter:   "us-dfw-dc",
+				SerialNumber: fmt.Sprintf("SN-%d", i),
+			}))
+		}
+		Expect(servers[0].ID).To(Equal(servers[1].ID))
+
+		cluster, metalsoftCluster := newReadyTestCluster("same-id", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-dfw-dc"
+			secret := newCredentialsSecret(mc.Namespace, "credentials-0", inventories[0].Credentials())
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: secret.Name}
+		})
+		secret := newCredentialsSecret(metalsoftCluster.Namespace, "credentials-1", inventories[1].Credentials())
+		Expect(k8sClient.Create(ctx, &infrastructurev1beta1.MetalsoftCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "same-id-other", Namespace: metalsoftCluster.Namespace},
+			Spec: infrastructurev1beta1.MetalsoftClusterSpec{
+				Datacenter:     "us-dfw-dc",
+				CredentialsRef: &corev1.LocalObjectReference{Name: secret.Name},
+			},
+		})).To(Succeed())
+
+		hostKeys := make([]client.ObjectKey, len(inventories))
+		for i, inventory := range inventories {
+			hostKeys[i] = client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: hostName(inventoryID(inventory.Credentials()), servers[i].ID)}
+		}
+		expectHosts := func(g Gomega) {
+			for i, inventory := range inventories {
+				host := &infrastructurev1beta1.MetalsoftHost{}
+				g.Expect(k8sClient.Get(ctx, hostKeys[i], host)).To(Succeed())
+				g.Expect(host.Labels).To(HaveKeyWithValue(infrastructurev1beta1.HostInventoryLabel, inventoryID(inventory.Credentials())))
+				g.Expect(host.Status.SerialNumber).To(Equal(fmt.Sprintf("SN-%d", i)))
+			}
+		}
+		Eventually(expectHosts, timeout, interval).Should(Succeed())
+		calls := inventories[1].Calls("servers")
+		Eventually(func() int {
+			return inventories[1].Calls("servers")
+		}, timeout, interval).Should(BeNumerically(">=", calls+2))
+		expectHosts(Default)
+
+		By("claiming the host of the inventory of the machine")
+		machine, metalsoftMachine := newTestMachine(cluster, "same-id-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.HardwareRequirements = &infrastructurev1beta1.HardwareRequirements{}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		waitForReadyMachines(metalsoftMachine)
+		instanceArray, ok := inventories[0].InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.ServerID).To(Equal(servers[0].ID))
+
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Expect(k8sClient.Get(ctx, hostKeys[0], host)).To(Succeed())
+		Expect(host.Spec.ConsumerRef).NotTo(BeNil())
+		Expect(host.Spec.ConsumerRef.UID).To(Equal(metalsoftMachine.UID))
+		Expect(k8sClient.Get(ctx, hostKeys[1], host)).To(Succeed())
+		Expect(host.Spec.ConsumerRef).To(BeNil())
+	})
+
+	It("deploys machines on the hosts they select", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-pdx-dc"})
+		storage := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-pdx-dc"})
+
+		cluster, metalsoftCluster := newReadyTestCluster("host-selector", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-pdx-dc"
+		})
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		hostKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(storage.ID)}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+		}, timeout, interval).Should(Succeed())
+		host.Labels["tier"] = "storage"
+		Expect(k8sClient.Update(ctx, host)).To(Succeed())
+
+		machine, metalsoftMachine := newTestMachine(cluster, "host-selector-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.ServerType = ""
+			mm.Spec.HostSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "storage"}}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		waitForReadyMachines(metalsoftMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.ServerID).To(Equal(storage.ID))
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+			g.Expect(host.Status.AllocationState).To(Equal(infrastructurev1beta1.AllocationStateAllocated))
+			g.Expect(host.Status.PowerState).To(Equal(infrastructurev1beta1.PowerStateOn))
+			g.Expect(host.Status.InstanceID).NotTo(BeNil())
+			g.Expect(host.Spec.ConsumerRef).NotTo(BeNil())
+			g.Expect(host.Spec.ConsumerRef.Name).To(Equal(metalsoftMachine.Name))
+		}, timeout, interval).Should(Succeed())
+	})
+
+	It("selects hosts in the racks of the failure domain of the machine", func() {
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "phx-r1", Datacenter: "us-phx-dc"})
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "phx-r2", Datacenter: "us-phx-dc"})
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		// The server outside of the failure domain is the first one the
+		// selection would pick otherwise.
+		outside := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-phx-dc", RackLabel: "phx-r1"})
+		inside := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-phx-dc", RackLabel: "phx-r2"})
+		metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-phx-dc", RackLabel: "phx-r2"})
+
+		cluster, metalsoftCluster := newReadyTestCluster("host-domain", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-phx-dc"
+			mc.Spec.FailureDomains = []infrastructurev1beta1.FailureDomainSpec{
+				{Name: "zone-a", Racks: []string{"phx-r1"}},
+				{Name: "zone-b", Racks: []string{"phx-r2"}},
+			}
+		})
+		for _, server := range []metalsoft.Server{outside, inside} {
+			host := &infrastructurev1beta1.MetalsoftHost{}
+			hostKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(server.ID)}
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+			}, timeout, interval).Should(Succeed())
+			host.Labels["tier"] = "storage"
+			Expect(k8sClient.Update(ctx, host)).To(Succeed())
+		}
+
+		var metalsoftMachines []*infrastructurev1beta1.MetalsoftMachine
+		for i := 0; i < 2; i++ {
+			machine, metalsoftMachine := newTestMachine(cluster, fmt.Sprintf("host-domain-md-%d", i), func(mm *infrastructurev1beta1.MetalsoftMachine) {
+				mm.Spec.ServerType = ""
+				mm.Spec.HostSelector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "storage"}}
+			})
+			machine.Spec.FailureDomain = pointer.String("zone-b")
+			Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+			setBootstrapData(machine, testBootstrapData)
+			metalsoftMachines = append(metalsoftMachines, metalsoftMachine)
+		}
+
+		By("deploying one machine on the selected host of the failure domain")
+		var ready, waiting *infrastructurev1beta1.MetalsoftMachine
+		Eventually(func(g Gomega) {
+			ready, waiting = nil, nil
+			for _, metalsoftMachine := range metalsoftMachines {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftMachine), metalsoftMachine)).To(Succeed())
+				switch {
+				case metalsoftMachine.Status.Ready:
+					ready = metalsoftMachine
+				case conditions.GetReason(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition) == infrastructurev1beta1.NoMatchingServerReason:
+					waiting = metalsoftMachine
+				}
+			}
+			g.Expect(ready).NotTo(BeNil())
+			g.Expect(waiting).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*ready.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.ServerID).To(Equal(inside.ID))
+		Expect(ready.Status.Placement).NotTo(BeNil())
+		Expect(ready.Status.Placement.Rack).To(Equal("phx-r2"))
+
+		By("leaving the other machine waiting instead of using the host outside of the failure domain")
+		Expect(waiting.Status.InstanceArrayID).To(BeNil())
+		Expect(conditions.GetMessage(waiting, infrastructurev1beta1.ServerSelectedCondition)).To(ContainSubstring("phx-r2"))
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(outside.ID)}, host)).To(Succeed())
+		Expect(host.Spec.ConsumerRef).To(BeNil())
+	})
+
+	It("skips a host claimed by another machine while claiming it", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		server := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-phx-dc"})
+		cluster, metalsoftCluster := newTestCluster("claim-conflict", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-phx-dc"
+		})
+		hostKey := client.ObjectKey{Namespace: metalsoftCluster.Namespace, Name: testHostName(server.ID)}
+		Eventually(func() error {
+			return k8sClient.Get(ctx, hostKey, &infrastructurev1beta1.MetalsoftHost{})
+		}, timeout, interval).Should(Succeed())
+		// Without bootstrap data, the machine is only claimed for below.
+		_, metalsoftMachine := newTestMachine(cluster, "claim-conflict-md-0", nil)
+
+		other := &corev1.ObjectReference{Kind: "MetalsoftMachine", Namespace: metalsoftCluster.Namespace, Name: "other", UID: "other"}
+		r := &MetalsoftMachineReconciler{Client: claimingClient{Client: k8sClient, consumerRef: other}}
+		msClient, err := metalsoft.NewClient(metalsoftServer.Credentials())
+		Expect(err).NotTo(HaveOccurred())
+		claimed, err := r.claimServer(ctx, metalsoftMachine, inventoryID(metalsoftServer.Credentials()), &server, msClient)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(claimed).To(BeFalse())
+
+		host := &infrastructurev1beta1.MetalsoftHost{}
+		Expect(k8sClient.Get(ctx, hostKey, host)).To(Succeed())
+		Expect(host.Spec.ConsumerRef).To(Equal(other))
+	})
+
+	It("allocates each server to a single machine when machines race for it", func() {
+		serverType, ok := metalsoftServer.ServerType("M.16.64.v2")
+		Expect(ok).To(BeTrue())
+		for i := 0; i < 3; i++ {
+			metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-bos-dc"})
+		}
+
+		// The clusters live in different namespaces, each with its own
+		// MetalsoftHosts for the same servers, so only the MetalSoft
+		// reservations keep their machines apart.
+		var clusters []*clusterv1.Cluster
+		for _, name := range []string{"race-a", "race-b"} {
+			cluster, metalsoftCluster := newReadyTestCluster(name, func(mc *infrastructurev1beta1.MetalsoftCluster) {
+				mc.Spec.Datacenter = "us-bos-dc"
+			})
+			Eventually(func(g Gomega) {
+				hosts := &infrastructurev1beta1.MetalsoftHostList{}
+				g.Expect(k8sClient.List(ctx, hosts, client.InNamespace(metalsoftCluster.Namespace))).To(Succeed())
+				g.Expect(hosts.Items).To(HaveLen(3))
+			}, timeout, interval).Should(Succeed())
+			clusters = append(clusters, cluster)
+		}
+
+		// Slow reservations down so that the machines search for servers
+		// while the reservations of the others are still in flight.
+		metalsoftServer.SetMethodLatency("server_reserve", 200*time.Millisecond)
+		DeferCleanup(metalsoftServer.SetMethodLatency, "server_reserve", time.Duration(0))
+
+		By("releasing the bootstrap data of all the machines at once")
+		var machines []*clusterv1.Machine
+		var metalsoftMachines []*infrastructurev1beta1.MetalsoftMachine
+		for i := 0; i < 3; i++ {
+			for _, cluster := range clusters {
+				machine, metalsoftMachine := newTestMachine(cluster, fmt.Sprintf("%s-md-%d", cluster.Name, i), func(mm *infrastructurev1beta1.MetalsoftMachine) {
+					mm.Spec.HardwareRequirements = &infrastructurev1beta1.HardwareRequirements{}
+				})
+				machines = append(machines, machine)
+				metalsoftMachines = append(metalsoftMachines, metalsoftMachine)
+			}
+		}
+		for _, machine := range machines {
+			setBootstrapData(machine, testBootstrapData)
+		}
+
+		var ready, waiting []*infrastructurev1beta1.MetalsoftMachine
+		Eventually(func(g Gomega) {
+			ready, waiting = nil, nil
+			for _, metalsoftMachine := range metalsoftMachines {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftMachine), metalsoftMachine)).To(Succeed())
+				switch {
+				case metalsoftMachine.Status.Ready:
+					ready = append(ready, metalsoftMachine)
+				case conditions.GetReason(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition) == infrastructurev1beta1.NoMatchingServerReason:
+					waiting = append(waiting, metalsoftMachine)
+				}
+			}
+			g.Expect(ready).To(HaveLen(3))
+			g.Expect(waiting).To(HaveLen(3))
+		}, timeout, interval).Should(Succeed())
+
+		servers := map[int]bool{}
+		for _, metalsoftMachine := range ready {
+			instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+			Expect(ok).To(BeTrue())
+			Expect(servers).NotTo(HaveKey(instanceArray.ServerID))
+			servers[instanceArray.ServerID] = true
+
+			host := &infrastructurev1beta1.MetalsoftHost{}
+			Expect(k8sClient.Get(ctx, client.ObjectKey{Namespace: metalsoftMachine.Namespace, Name: testHostName(instanceArray.ServerID)}, host)).To(Succeed())
+			Expect(host.Spec.ConsumerRef).NotTo(BeNil())
+			Expect(host.Spec.ConsumerRef.UID).To(Equal(metalsoftMachine.UID))
+		}
+		for _, metalsoftMachine := range waiting {
+			Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+		}
+	})
+})
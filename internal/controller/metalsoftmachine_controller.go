@@ -18,45 +18,1106 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
 
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"k8s.io/klog/v2"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/predicates"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
-	infrastructurev1alpha1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1alpha1"
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/cloudinit"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/ignition"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/kubevip"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
 )
 
+// ProviderIDPrefix is the prefix of the provider IDs of MetalSoft machines,
+// followed by the MetalSoft instance ID.
+const ProviderIDPrefix = "metalsoft://"
+
+// superAdminKubeconfigVersion is the first Kubernetes version whose kubeadm
+// init writes the super-admin kubeconfig.
+var superAdminKubeconfigVersion = utilversion.MustParseGeneric("v1.29.0")
+
 // MetalsoftMachineReconciler reconciles a MetalsoftMachine object
 type MetalsoftMachineReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// MetalsoftClientFactory creates the MetalSoft API client used by the
+	// reconciler. It defaults to metalsoft.NewClient.
+	MetalsoftClientFactory metalsoft.ClientFactory
+	// DefaultCredentialsSecret is the Secret holding the MetalSoft
+	// credentials of clusters without spec.credentialsRef. When nil, such
+	// clusters cannot be reconciled.
+	DefaultCredentialsSecret *client.ObjectKey
+	// ControllerNamespace is the namespace the controller runs in, holding
+	// the Secrets of MetalsoftClusterIdentities.
+	ControllerNamespace string
+	// MaxConcurrentReconciles is the number of MetalsoftMachines reconciled
+	// in parallel. It defaults to 1.
+	MaxConcurrentReconciles int
 }
 
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftmachines,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftmachines/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsoftmachines/finalizers,verbs=update
+//+kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
+//+kubebuilder:rbac:groups=bootstrap.cluster.x-k8s.io,resources=kubeadmconfigs,verbs=get;list;watch
+//+kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalsofthosts,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
 
-// Reconcile is part of the main kubernetes reconciliation loop which aims to
-// move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the MetalsoftMachine object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// Reconcile provisions the MetalSoft instance array backing a
+// MetalsoftMachine once the cluster infrastructure is ready and the bootstrap
+// data of the owning Machine is available, and reports the provider ID and
+// addresses of the deployed server.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.15.0/pkg/reconcile
-func (r *MetalsoftMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	_ = log.FromContext(ctx)
+func (r *MetalsoftMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	logger := log.FromContext(ctx)
+
+	metalsoftMachine := &infrastructurev1beta1.MetalsoftMachine{}
+	if err := r.Get(ctx, req.NamespacedName, metalsoftMachine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	machine, err := util.GetOwnerMachine(ctx, r.Client, metalsoftMachine.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if machine == nil {
+		logger.Info("Waiting for Machine Controller to set OwnerRef on MetalsoftMachine")
+		return ctrl.Result{}, nil
+	}
+
+	logger = logger.WithValues("Machine", klog.KObj(machine))
+	ctx = log.IntoContext(ctx, logger)
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machine.ObjectMeta)
+	if err != nil {
+		logger.Info("Machine is missing cluster label or cluster does not exist")
+		return ctrl.Result{}, nil
+	}
+
+	logger = logger.WithValues("Cluster", klog.KObj(cluster))
+	ctx = log.IntoContext(ctx, logger)
+
+	if annotations.IsPaused(cluster, metalsoftMachine) {
+		logger.Info("MetalsoftMachine or linked Cluster is marked as paused, not reconciling")
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(metalsoftMachine, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	defer func() {
+		conditions.SetSummary(metalsoftMachine,
+			conditions.WithConditions(
+				infrastructurev1beta1.InstanceReadyCondition,
+				infrastructurev1beta1.IPAddressClaimedCondition,
+				infrastructurev1beta1.ServerSelectedCondition,
+			),
+		)
+		if err := patchHelper.Patch(ctx, metalsoftMachine, patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.IPAddressClaimedCondition,
+			infrastructurev1beta1.ServerSelectedCondition,
+		}}); err != nil {
+			reterr = kerrors.NewAggregate([]error{reterr, err})
+		}
+	}()
+
+	metalsoftCluster, err := r.getMetalsoftCluster(ctx, cluster)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	msClient, err := newMetalsoftClient(ctx, r.Client, r.MetalsoftClientFactory, r.DefaultCredentialsSecret, r.ControllerNamespace, metalsoftCluster)
+	if !metalsoftMachine.DeletionTimestamp.IsZero() {
+		if err != nil {
+			return r.reconcileDeleteWithoutClient(ctx, metalsoftMachine, err)
+		}
+		return r.reconcileDelete(ctx, cluster, machine, metalsoftCluster, metalsoftMachine, msClient)
+	}
+	if errors.Is(err, errNamespaceNotAllowed) {
+		logger.Info("Refusing to reconcile MetalsoftMachine", "reason", err.Error())
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.NamespaceNotAllowedByIdentityReason, clusterv1.ConditionSeverityError, err.Error())
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.CredentialsUnavailableReason, clusterv1.ConditionSeverityError, err.Error())
+		return ctrl.Result{}, err
+	}
+	return r.reconcileNormal(ctx, cluster, machine, metalsoftMachine, metalsoftCluster, msClient)
+}
+
+func (r *MetalsoftMachineReconciler) reconcileNormal(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if metalsoftMachine.Status.FailureReason != nil || metalsoftMachine.Status.FailureMessage != nil {
+		logger.Info("MetalsoftMachine has failed, not reconciling")
+		return ctrl.Result{}, nil
+	}
+
+	// Register the finalizer before creating anything in MetalSoft so that
+	// the instance array is never left behind.
+	if controllerutil.AddFinalizer(metalsoftMachine, infrastructurev1beta1.MachineFinalizer) {
+		return ctrl.Result{}, nil
+	}
+
+	if !cluster.Status.InfrastructureReady {
+		logger.Info("Waiting for MetalsoftCluster infrastructure to be ready")
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	if metalsoftCluster == nil || metalsoftCluster.Status.InfrastructureID == nil {
+		logger.Info("Waiting for MetalsoftCluster infrastructure to be created")
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.WaitingForClusterInfrastructureReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		logger.Info("Waiting for bootstrap data to be available")
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{}, nil
+	}
+
+	if metalsoftMachine.Status.InstanceArrayID == nil && !clusterNetworksReady(metalsoftMachine, metalsoftCluster) {
+		logger.Info("Waiting for the networks of the MetalsoftCluster to be deployed")
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.WaitingForNetworksReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	var staticIPs map[int][]metalsoft.StaticIP
+	if metalsoftMachine.Status.InstanceArrayID == nil {
+		var ok bool
+		var err error
+		staticIPs, ok, err = r.reconcileIPAddressClaims(ctx, cluster, metalsoftMachine)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !ok {
+			logger.Info("Waiting for the IP addresses of the network interfaces to be allocated")
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				infrastructurev1beta1.WaitingForIPAddressReason, clusterv1.ConditionSeverityInfo, "")
+			return ctrl.Result{}, nil
+		}
+	}
+
+	instanceArray, err := r.reconcileInstanceArray(ctx, machine, metalsoftMachine, metalsoftCluster, staticIPs, msClient)
+	if err != nil || instanceArray == nil {
+		return ctrl.Result{}, err
+	}
+
+	pending, err := r.reconcileDriveArrays(ctx, metalsoftMachine, instanceArray, msClient)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if pending || instanceArray.ServiceStatus == metalsoft.ServiceStatusOrdered {
+		if err := deployInfrastructure(ctx, msClient, instanceArray.InfrastructureID); err != nil {
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				infrastructurev1beta1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+
+	instances, err := msClient.ListInstances(ctx, instanceArray.ID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list instances of MetalSoft instance array %d: %w", instanceArray.ID, err)
+	}
+	if len(instances) == 0 {
+		logger.Info("Waiting for MetalSoft instance to be created", "instanceArrayID", instanceArray.ID)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.InstanceDeployingReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+	instance := instances[0]
+	instanceState := infrastructurev1beta1.InstanceState(instance.ServiceStatus)
+	metalsoftMachine.Status.InstanceID = &instance.ID
+	metalsoftMachine.Status.InstanceState = &instanceState
+
+	if instance.ServiceStatus != metalsoft.ServiceStatusActive {
+		logger.Info("Waiting for MetalSoft instance to be active", "instanceID", instance.ID, "state", instance.ServiceStatus)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.InstanceDeployingReason, clusterv1.ConditionSeverityInfo, "")
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	addresses, err := instanceAddresses(ctx, msClient, instanceArray.InfrastructureID, &instance)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	metalsoftMachine.Status.Addresses = addresses
+	metalsoftMachine.Status.Placement = &infrastructurev1beta1.Placement{
+		Datacenter:    metalsoftCluster.Spec.Datacenter,
+		FailureDomain: pointer.StringDeref(machine.Spec.FailureDomain, ""),
+		Rack:          instance.RackLabel,
+	}
 
-	// TODO(user): your logic here
+	providerID := fmt.Sprintf("%s%d", ProviderIDPrefix, instance.ID)
+	metalsoftMachine.Spec.ProviderID = &providerID
 
+	if util.IsControlPlaneMachine(machine) {
+		if err := registerControlPlaneBackend(ctx, cluster, metalsoftCluster, instance.ID, msClient); err != nil {
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				infrastructurev1beta1.ControlPlaneBackendRegistrationFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+	}
+
+	conditions.MarkTrue(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition)
+	metalsoftMachine.Status.Ready = true
 	return ctrl.Result{}, nil
 }
 
+// reconcileInstanceArray returns the MetalSoft instance array of the machine,
+// adopting or creating it as needed with the static IPs claimed for its
+// network interfaces. A nil instance array with a nil error means the machine
+// has been marked as failed.
+func (r *MetalsoftMachineReconciler) reconcileInstanceArray(ctx context.Context, machine *clusterv1.Machine, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, staticIPs map[int][]metalsoft.StaticIP, msClient metalsoft.Client) (*metalsoft.InstanceArray, error) {
+	logger := log.FromContext(ctx)
+
+	if metalsoftMachine.Status.InstanceArrayID != nil {
+		instanceArray, err := msClient.GetInstanceArray(ctx, *metalsoftMachine.Status.InstanceArrayID)
+		if metalsoft.IsNotFound(err) {
+			r.setFailure(metalsoftMachine, capierrors.UpdateMachineError,
+				fmt.Sprintf("MetalSoft instance array %d was deleted outside of Cluster API", *metalsoftMachine.Status.InstanceArrayID))
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get MetalSoft instance array %d: %w", *metalsoftMachine.Status.InstanceArrayID, err)
+		}
+		return instanceArray, nil
+	}
+
+	infrastructureID := *metalsoftCluster.Status.InfrastructureID
+
+	// The instance array may already exist if a previous reconcile was
+	// interrupted before the status was patched.
+	instanceArray, err := instanceArrayByLabel(ctx, msClient, infrastructureID, metalsoftMachine.Name)
+	if err != nil {
+		return nil, err
+	}
+	if instanceArray != nil {
+		metalsoftMachine.Status.InstanceArrayID = &instanceArray.ID
+		return instanceArray, nil
+	}
+
+	rackLabels, ok, err := r.failureDomainRacks(machine, metalsoftMachine, metalsoftCluster)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	var serverTypeID, serverID int
+	var reservationKey string
+	if metalsoftMachine.Spec.HostSelector != nil || metalsoftMachine.Spec.HardwareRequirements != nil {
+		server, err := r.selectServer(ctx, metalsoftMachine, metalsoftCluster, rackLabels, msClient)
+		if err != nil || server == nil {
+			return nil, err
+		}
+		serverID = server.ID
+		reservationKey = serverReservationKey(metalsoftMachine)
+	} else {
+		serverType, err := r.resolveServerType(ctx, metalsoftMachine, metalsoftCluster, msClient)
+		if err != nil || serverType == nil {
+			return nil, err
+		}
+		serverTypeID = serverType.ID
+	}
+
+	osTemplate, err := msClient.GetOSTemplate(ctx, metalsoftMachine.Spec.OSTemplate)
+	if metalsoft.IsNotFound(err) {
+		r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+			fmt.Sprintf("MetalSoft OS template %q not found", metalsoftMachine.Spec.OSTemplate))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get MetalSoft OS template %q: %w", metalsoftMachine.Spec.OSTemplate, err)
+	}
+
+	interfaces, ok, err := r.resolveNetworkInterfaces(ctx, metalsoftMachine, infrastructureID, staticIPs, msClient)
+	if err != nil || !ok {
+		return nil, err
+	}
+	networkConfig, err := staticNetworkConfig(interfaces)
+	if err != nil {
+		r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+			fmt.Sprintf("failed to render the network configuration: %v", err))
+		return nil, nil
+	}
+
+	bootstrapData, format, err := r.getBootstrapData(ctx, machine)
+	if err != nil {
+		return nil, err
+	}
+	if !osTemplate.SupportsBootstrapFormat(format) {
+		r.setBootstrapFailure(metalsoftMachine, infrastructurev1beta1.BootstrapFormatUnsupportedReason,
+			fmt.Sprintf("MetalSoft OS template %q does not support %s bootstrap data", osTemplate.Label, format))
+		return nil, nil
+	}
+	if util.IsControlPlaneMachine(machine) {
+		kubeconfig, err := r.kubeVIPKubeconfig(ctx, machine)
+		if err != nil {
+			return nil, err
+		}
+		bootstrapData, err = withKubeVIP(metalsoftCluster, bootstrapData, format, kubeconfig)
+		if err != nil {
+			r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+				fmt.Sprintf("failed to add kube-vip to the bootstrap data: %v", err))
+			return nil, nil
+		}
+	}
+	if files := staticNetworkFiles(interfaces); format == metalsoft.BootstrapFormatIgnition && len(files) > 0 {
+		data, err := ignition.AddFiles([]byte(bootstrapData), files...)
+		if err != nil {
+			r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+				fmt.Sprintf("failed to add the network configuration to the bootstrap data: %v", err))
+			return nil, nil
+		}
+		bootstrapData = string(data)
+	}
+
+	spec := metalsoft.InstanceArray{
+		Label:            metalsoftMachine.Name,
+		InstanceCount:    1,
+		ServerTypeID:     serverTypeID,
+		ServerID:         serverID,
+		ReservationKey:   reservationKey,
+		OSTemplateID:     osTemplate.ID,
+		BootDriveSizeMB:  metalsoftMachine.Spec.BootDriveSizeGB * 1024,
+		SSHKeyIDs:        metalsoftMachine.Spec.SSHKeyIDs,
+		CustomVariables:  metalsoftMachine.Spec.CustomVariables,
+		CloudInitNetwork: networkConfig,
+		Interfaces:       interfaces,
+		RackLabels:       rackLabels,
+	}
+	if ok, err := r.deliverBootstrapData(metalsoftMachine, &spec, bootstrapData); err != nil || !ok {
+		return nil, err
+	}
+	logger.Info("Creating MetalSoft instance array", "serverTypeID", serverTypeID, "serverID", serverID,
+		"osTemplate", osTemplate.Label, "failureDomain", machine.Spec.FailureDomain)
+	instanceArray, err = msClient.CreateInstanceArray(ctx, infrastructureID, spec)
+	if err != nil {
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, fmt.Errorf("failed to create MetalSoft instance array: %w", err)
+	}
+
+	metalsoftMachine.Status.InstanceArrayID = &instanceArray.ID
+	return instanceArray, nil
+}
+
+// resolveNetworkInterfaces returns the instance array interfaces attaching
+// the server to the networks of spec.networkInterfaces with their static IPs.
+// ok is false when the machine has been marked as failed.
+func (r *MetalsoftMachineReconciler) resolveNetworkInterfaces(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, infrastructureID int, staticIPs map[int][]metalsoft.StaticIP, msClient metalsoft.Client) ([]metalsoft.InstanceArrayInterface, bool, error) {
+	if len(metalsoftMachine.Spec.NetworkInterfaces) == 0 {
+		return nil, true, nil
+	}
+
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	networkIDs := map[string]int{}
+	for _, network := range networks {
+		networkIDs[network.Label] = network.ID
+	}
+
+	interfaces := make([]metalsoft.InstanceArrayInterface, 0, len(metalsoftMachine.Spec.NetworkInterfaces))
+	for _, networkInterface := range metalsoftMachine.Spec.NetworkInterfaces {
+		networkID, ok := networkIDs[networkInterface.Network]
+		if !ok {
+			r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+				fmt.Sprintf("network %q of interface %d not found in MetalSoft infrastructure %d",
+					networkInterface.Network, networkInterface.Index, infrastructureID))
+			return nil, false, nil
+		}
+		interfaces = append(interfaces, metalsoft.InstanceArrayInterface{
+			Index:     networkInterface.Index,
+			NetworkID: networkID,
+			StaticIPs: staticIPs[networkInterface.Index],
+		})
+	}
+	return interfaces, true, nil
+}
+
+// failureDomainRacks returns the racks of the failure domain of the machine
+// the server must be allocated from, or nil if it can be allocated anywhere
+// in the datacenter. ok is false when the machine has been marked as failed.
+func (r *MetalsoftMachineReconciler) failureDomainRacks(machine *clusterv1.Machine, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) ([]string, bool, error) {
+	if machine.Spec.FailureDomain == nil || *machine.Spec.FailureDomain == "" {
+		return nil, true, nil
+	}
+	name := *machine.Spec.FailureDomain
+
+	failureDomain, found := metalsoftCluster.Status.FailureDomains[name]
+	if !found {
+		for _, spec := range metalsoftCluster.Spec.FailureDomains {
+			if spec.Name == name {
+				return nil, false, fmt.Errorf("failure domain %q of MetalsoftCluster %s is not resolved yet", name, metalsoftCluster.Name)
+			}
+		}
+		r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+			fmt.Sprintf("failure domain %q is not a failure domain of MetalsoftCluster %s", name, metalsoftCluster.Name))
+		return nil, false, nil
+	}
+	if racks := failureDomain.Attributes[failureDomainRacksAttribute]; racks != "" {
+		return strings.Split(racks, ","), true, nil
+	}
+	return nil, true, nil
+}
+
+// resolveServerType returns the MetalSoft server type requested by the
+// machine. A nil server type with a nil error means the machine has been
+// marked as failed.
+func (r *MetalsoftMachineReconciler) resolveServerType(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, msClient metalsoft.Client) (*metalsoft.ServerType, error) {
+	serverTypes, err := msClient.ListServerTypes(ctx, metalsoftCluster.Spec.Datacenter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MetalSoft server types: %w", err)
+	}
+
+	if metalsoftMachine.Spec.ServerType != "" {
+		for i := range serverTypes {
+			if serverTypes[i].Name == metalsoftMachine.Spec.ServerType {
+				return &serverTypes[i], nil
+			}
+		}
+		r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+			fmt.Sprintf("MetalSoft server type %q is not offered in datacenter %q",
+				metalsoftMachine.Spec.ServerType, metalsoftCluster.Spec.Datacenter))
+		return nil, nil
+	}
+
+	selector := metalsoftMachine.Spec.ServerTypeSelector
+	if selector == nil {
+		r.setFailure(metalsoftMachine, capierrors.InvalidConfigurationMachineError,
+			"one of spec.serverType or spec.serverTypeSelector must be set")
+		return nil, nil
+	}
+
+	var candidates []metalsoft.ServerType
+	for _, serverType := range serverTypes {
+		if serverType.AvailableCount > 0 &&
+			serverType.ProcessorCoreCount >= selector.MinCores &&
+			serverType.RAMGB >= selector.MinRAMGB {
+			candidates = append(candidates, serverType)
+		}
+	}
+	if len(candidates) == 0 {
+		err := fmt.Errorf("no available MetalSoft server type in datacenter %q has at least %d cores and %d GB of RAM",
+			metalsoftCluster.Spec.Datacenter, selector.MinCores, selector.MinRAMGB)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			infrastructurev1beta1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return nil, err
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].ProcessorCoreCount != candidates[j].ProcessorCoreCount {
+			return candidates[i].ProcessorCoreCount < candidates[j].ProcessorCoreCount
+		}
+		return candidates[i].RAMGB < candidates[j].RAMGB
+	})
+	return &candidates[0], nil
+}
+
+// reconcileDriveArrays creates the additional drive arrays of the machine and
+// returns true if any of them is still waiting to be deployed.
+func (r *MetalsoftMachineReconciler) reconcileDriveArrays(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, instanceArray *metalsoft.InstanceArray, msClient metalsoft.Client) (bool, error) {
+	if len(metalsoftMachine.Spec.AdditionalDriveArrays) == 0 {
+		return false, nil
+	}
+
+	driveArrays, err := msClient.ListDriveArrays(ctx, instanceArray.InfrastructureID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list MetalSoft drive arrays: %w", err)
+	}
+
+	pending := false
+	for _, spec := range metalsoftMachine.Spec.AdditionalDriveArrays {
+		label := fmt.Sprintf("%s-%s", metalsoftMachine.Name, spec.Label)
+
+		var existing *metalsoft.DriveArray
+		for i := range driveArrays {
+			if driveArrays[i].InstanceArrayID == instanceArray.ID && driveArrays[i].Label == label {
+				existing = &driveArrays[i]
+				break
+			}
+		}
+		if existing != nil {
+			pending = pending || existing.ServiceStatus == metalsoft.ServiceStatusOrdered
+			continue
+		}
+
+		log.FromContext(ctx).Info("Creating MetalSoft drive array", "label", label)
+		if _, err := msClient.CreateDriveArray(ctx, instanceArray.InfrastructureID, metalsoft.DriveArray{
+			InstanceArrayID: instanceArray.ID,
+			Label:           label,
+			StorageType:     spec.StorageType,
+			DriveSizeMB:     spec.SizeGB * 1024,
+		}); err != nil {
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				infrastructurev1beta1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return false, fmt.Errorf("failed to create MetalSoft drive array %q: %w", label, err)
+		}
+		pending = true
+	}
+	return pending, nil
+}
+
+func (r *MetalsoftMachineReconciler) reconcileDelete(ctx context.Context, cluster *clusterv1.Cluster, machine *clusterv1.Machine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, msClient metalsoft.Client) (ctrl.Result, error) {
+	logger := log.FromContext(ctx)
+
+	if metalsoftMachine.Status.InstanceArrayID == nil {
+		// The instance array may have been created by a reconcile that was
+		// interrupted before the status was patched; look it up by label so
+		// the server is not left running and billed.
+		instanceArray, err := findInstanceArray(ctx, metalsoftCluster, metalsoftMachine, msClient)
+		if err != nil {
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, err
+		}
+		if instanceArray == nil {
+			if err := r.releaseIPAddressClaims(ctx, metalsoftMachine); err != nil {
+				return ctrl.Result{}, err
+			}
+			if err := r.releaseHosts(ctx, metalsoftMachine, msClient); err != nil {
+				return ctrl.Result{}, err
+			}
+			controllerutil.RemoveFinalizer(metalsoftMachine, infrastructurev1beta1.MachineFinalizer)
+			return ctrl.Result{}, nil
+		}
+		metalsoftMachine.Status.InstanceArrayID = &instanceArray.ID
+	}
+	instanceArrayID := *metalsoftMachine.Status.InstanceArrayID
+
+	instanceArray, err := msClient.GetInstanceArray(ctx, instanceArrayID)
+	if metalsoft.IsNotFound(err) {
+		logger.Info("MetalSoft instance array is gone, server released", "instanceArrayID", instanceArrayID)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		if err := r.releaseIPAddressClaims(ctx, metalsoftMachine); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.releaseHosts(ctx, metalsoftMachine, msClient); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(metalsoftMachine, infrastructurev1beta1.MachineFinalizer)
+		return ctrl.Result{}, nil
+	}
+	if err != nil {
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to get MetalSoft instance array %d: %w", instanceArrayID, err)
+	}
+	metalsoftMachine.Status.Ready = false
+
+	infrastructure, err := msClient.GetInfrastructure(ctx, instanceArray.InfrastructureID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to get MetalSoft infrastructure %d: %w", instanceArray.InfrastructureID, err)
+	}
+	if infrastructure.DeployOngoing() {
+		logger.Info("Waiting for MetalSoft infrastructure deploy to finish", "infrastructureID", infrastructure.ID)
+		return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+	}
+
+	// Power the server off before deleting the instance array so the
+	// operating system is not torn down while running.
+	if instanceArray.ServiceStatus == metalsoft.ServiceStatusActive {
+		// Take a control plane server out of the endpoint first so no API
+		// request is sent to it while it shuts down.
+		if util.IsControlPlaneMachine(machine) && metalsoftMachine.Status.InstanceID != nil {
+			if err := r.deregisterControlPlaneBackend(ctx, cluster, metalsoftCluster, metalsoftMachine, msClient); err != nil {
+				conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+					clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+				return ctrl.Result{}, err
+			}
+		}
+
+		logger.Info("Stopping MetalSoft instance array", "instanceArrayID", instanceArrayID)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "Stopping instance")
+		if err := msClient.StopInstanceArray(ctx, instanceArrayID); err != nil {
+			conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+				clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+			return ctrl.Result{}, fmt.Errorf("failed to stop MetalSoft instance array %d: %w", instanceArrayID, err)
+		}
+		if err := deployInfrastructure(ctx, msClient, instanceArray.InfrastructureID); err != nil {
+			return ctrl.Result{}, err
+		}
+		if instanceArray, err = msClient.GetInstanceArray(ctx, instanceArrayID); err != nil {
+			return ctrl.Result{}, fmt.Errorf("failed to get MetalSoft instance array %d: %w", instanceArrayID, err)
+		}
+		if instanceArray.ServiceStatus != metalsoft.ServiceStatusStopped {
+			return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+		}
+	}
+
+	logger.Info("Deleting MetalSoft instance array", "instanceArrayID", instanceArrayID)
+	conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+		clusterv1.DeletingReason, clusterv1.ConditionSeverityInfo, "Deleting instance array")
+	driveArrays, err := msClient.ListDriveArrays(ctx, instanceArray.InfrastructureID)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf("failed to list MetalSoft drive arrays: %w", err)
+	}
+	for _, driveArray := range driveArrays {
+		if driveArray.InstanceArrayID != instanceArrayID {
+			continue
+		}
+		if err := msClient.DeleteDriveArray(ctx, driveArray.ID); err != nil && !metalsoft.IsNotFound(err) {
+			return ctrl.Result{}, fmt.Errorf("failed to delete MetalSoft drive array %d: %w", driveArray.ID, err)
+		}
+	}
+	if err := msClient.DeleteInstanceArray(ctx, instanceArrayID); err != nil && !metalsoft.IsNotFound(err) {
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			clusterv1.DeletionFailedReason, clusterv1.ConditionSeverityWarning, err.Error())
+		return ctrl.Result{}, fmt.Errorf("failed to delete MetalSoft instance array %d: %w", instanceArrayID, err)
+	}
+	if err := deployInfrastructure(ctx, msClient, instanceArray.InfrastructureID); err != nil {
+		return ctrl.Result{}, err
+	}
+	if _, err := msClient.GetInstanceArray(ctx, instanceArrayID); metalsoft.IsNotFound(err) {
+		logger.Info("MetalSoft instance array deleted, server released", "instanceArrayID", instanceArrayID)
+		conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+			clusterv1.DeletedReason, clusterv1.ConditionSeverityInfo, "")
+		if err := r.releaseIPAddressClaims(ctx, metalsoftMachine); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.releaseHosts(ctx, metalsoftMachine, msClient); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(metalsoftMachine, infrastructurev1beta1.MachineFinalizer)
+		return ctrl.Result{}, nil
+	}
+	return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+}
+
+// reconcileDeleteWithoutClient deletes a MetalsoftMachine whose MetalSoft
+// credentials cannot be resolved, e.g. because the credentials Secret of its
+// cluster was deleted first. The finalizer is only removed when neither an
+// instance array nor a server reservation was created; otherwise
+// credentialsErr is reported until the credentials are back, so that the
+// server is not left running and billed.
+func (r *MetalsoftMachineReconciler) reconcileDeleteWithoutClient(ctx context.Context, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, credentialsErr error) (ctrl.Result, error) {
+	hosts, err := r.claimedHosts(ctx, metalsoftMachine)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if metalsoftMachine.Status.InstanceArrayID == nil && len(hosts) == 0 {
+		if err := r.releaseIPAddressClaims(ctx, metalsoftMachine); err != nil {
+			return ctrl.Result{}, err
+		}
+		controllerutil.RemoveFinalizer(metalsoftMachine, infrastructurev1beta1.MachineFinalizer)
+		return ctrl.Result{}, nil
+	}
+	metalsoftMachine.Status.Ready = false
+	log.FromContext(ctx).Info("Waiting for MetalSoft credentials to delete the instance array", "reason", credentialsErr.Error())
+	conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+		credentialsFailureReason(credentialsErr), clusterv1.ConditionSeverityError, credentialsErr.Error())
+	return ctrl.Result{RequeueAfter: deployRequeueAfter}, nil
+}
+
+// registerControlPlaneBackend makes the control plane endpoint of the cluster
+// forward to a control plane instance. A floating IP is only routed to the
+// instance when it is not routed to another one already. kube-vip endpoints
+// are announced by the servers themselves and need no registration.
+func registerControlPlaneBackend(ctx context.Context, cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, instanceID int, msClient metalsoft.Client) error {
+	status := metalsoftCluster.Status.ControlPlaneLoadBalancer
+	if metalsoftCluster.Spec.ControlPlaneLoadBalancer == nil || status == nil {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+
+	switch metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type {
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP:
+		return nil
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP:
+		floatingIP, err := msClient.GetFloatingIP(ctx, status.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get MetalSoft floating IP %d: %w", status.ID, err)
+		}
+		if floatingIP.InstanceID != 0 {
+			return nil
+		}
+		logger.Info("Routing control plane floating IP to instance", "floatingIPID", status.ID, "instanceID", instanceID)
+		if err := msClient.AssignFloatingIP(ctx, status.ID, instanceID); err != nil {
+			return fmt.Errorf("failed to assign MetalSoft floating IP %d to instance %d: %w", status.ID, instanceID, err)
+		}
+	default:
+		loadBalancer, err := msClient.GetLoadBalancer(ctx, status.ID)
+		if err != nil {
+			return fmt.Errorf("failed to get MetalSoft load balancer %d: %w", status.ID, err)
+		}
+		for _, backend := range loadBalancer.Backends {
+			if backend.InstanceID == instanceID {
+				return nil
+			}
+		}
+		logger.Info("Adding instance to control plane load balancer", "loadBalancerID", status.ID, "instanceID", instanceID)
+		if err := msClient.AddLoadBalancerBackend(ctx, status.ID, metalsoft.LoadBalancerBackend{
+			InstanceID: instanceID,
+			Port:       apiServerPort(cluster, metalsoftCluster),
+		}); err != nil && !metalsoft.IsConflict(err) {
+			return fmt.Errorf("failed to add instance %d to MetalSoft load balancer %d: %w", instanceID, status.ID, err)
+		}
+	}
+	return nil
+}
+
+// apiServerPort returns the port the API servers of the cluster listen on:
+// spec.clusterNetwork.apiServerPort of the Cluster when set, otherwise the
+// port of the control plane endpoint.
+func apiServerPort(cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) int {
+	if cluster.Spec.ClusterNetwork != nil && cluster.Spec.ClusterNetwork.APIServerPort != nil {
+		return int(*cluster.Spec.ClusterNetwork.APIServerPort)
+	}
+	if port := metalsoftCluster.Spec.ControlPlaneEndpoint.Port; port != 0 {
+		return int(port)
+	}
+	return infrastructurev1beta1.DefaultAPIServerPort
+}
+
+// deregisterControlPlaneBackend stops the control plane endpoint of the
+// cluster from forwarding to the instance of a deleted control plane machine.
+// A floating IP routed to the instance is handed over to another ready
+// control plane machine, if any.
+func (r *MetalsoftMachineReconciler) deregisterControlPlaneBackend(ctx context.Context, cluster *clusterv1.Cluster, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, msClient metalsoft.Client) error {
+	if metalsoftCluster == nil || metalsoftCluster.Spec.ControlPlaneLoadBalancer == nil || metalsoftCluster.Status.ControlPlaneLoadBalancer == nil {
+		return nil
+	}
+	logger := log.FromContext(ctx)
+	id := metalsoftCluster.Status.ControlPlaneLoadBalancer.ID
+	instanceID := *metalsoftMachine.Status.InstanceID
+
+	switch metalsoftCluster.Spec.ControlPlaneLoadBalancer.Type {
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP:
+		// kube-vip moves the address to another server by itself.
+		return nil
+	case infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP:
+	default:
+		logger.Info("Removing instance from control plane load balancer", "loadBalancerID", id, "instanceID", instanceID)
+		if err := msClient.RemoveLoadBalancerBackend(ctx, id, instanceID); err != nil && !metalsoft.IsNotFound(err) {
+			return fmt.Errorf("failed to remove instance %d from MetalSoft load balancer %d: %w", instanceID, id, err)
+		}
+		return nil
+	}
+
+	floatingIP, err := msClient.GetFloatingIP(ctx, id)
+	if metalsoft.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get MetalSoft floating IP %d: %w", id, err)
+	}
+	if floatingIP.InstanceID != instanceID {
+		return nil
+	}
+
+	// Control plane providers copy the control plane label of the Machine
+	// to its infrastructure machine.
+	metalsoftMachines := &infrastructurev1beta1.MetalsoftMachineList{}
+	if err := r.List(ctx, metalsoftMachines, client.InNamespace(metalsoftMachine.Namespace),
+		client.MatchingLabels{clusterv1.ClusterNameLabel: cluster.Name},
+		client.HasLabels{clusterv1.MachineControlPlaneLabel}); err != nil {
+		return fmt.Errorf("failed to list control plane MetalsoftMachines: %w", err)
+	}
+	for _, candidate := range metalsoftMachines.Items {
+		if candidate.Name == metalsoftMachine.Name || !candidate.DeletionTimestamp.IsZero() ||
+			!candidate.Status.Ready || candidate.Status.InstanceID == nil {
+			continue
+		}
+		logger.Info("Handing control plane floating IP over to instance", "floatingIPID", id, "instanceID", *candidate.Status.InstanceID)
+		err := msClient.AssignFloatingIP(ctx, id, *candidate.Status.InstanceID)
+		if err == nil {
+			return nil
+		}
+		if !metalsoft.IsNotFound(err) && !metalsoft.IsConflict(err) {
+			return fmt.Errorf("failed to assign MetalSoft floating IP %d to instance %d: %w", id, *candidate.Status.InstanceID, err)
+		}
+	}
+
+	logger.Info("Unassigning control plane floating IP", "floatingIPID", id, "instanceID", instanceID)
+	if err := msClient.UnassignFloatingIP(ctx, id); err != nil {
+		return fmt.Errorf("failed to unassign MetalSoft floating IP %d: %w", id, err)
+	}
+	return nil
+}
+
+// findInstanceArray looks up the instance array of the machine by label in
+// the cluster infrastructure. It returns nil if there is none.
+func findInstanceArray(ctx context.Context, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, msClient metalsoft.Client) (*metalsoft.InstanceArray, error) {
+	if metalsoftCluster == nil || metalsoftCluster.Status.InfrastructureID == nil {
+		return nil, nil
+	}
+
+	return instanceArrayByLabel(ctx, msClient, *metalsoftCluster.Status.InfrastructureID, metalsoftMachine.Name)
+}
+
+// instanceArrayByLabel returns the instance array with the given label in an
+// infrastructure, or nil if there is none.
+func instanceArrayByLabel(ctx context.Context, msClient metalsoft.Client, infrastructureID int, label string) (*metalsoft.InstanceArray, error) {
+	instanceArrays, err := msClient.ListInstanceArrays(ctx, infrastructureID)
+	if metalsoft.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MetalSoft instance arrays: %w", err)
+	}
+	for i := range instanceArrays {
+		if instanceArrays[i].Label == label && instanceArrays[i].ServiceStatus != metalsoft.ServiceStatusDeleted {
+			return &instanceArrays[i], nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *MetalsoftMachineReconciler) setFailure(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, reason capierrors.MachineStatusError, message string) {
+	metalsoftMachine.Status.FailureReason = &reason
+	metalsoftMachine.Status.FailureMessage = &message
+	conditions.MarkFalse(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition,
+		infrastructurev1beta1.InstanceProvisionFailedReason, clusterv1.ConditionSeverityError, message)
+}
+
+// getMetalsoftCluster returns the MetalsoftCluster referenced by cluster, or
+// nil if it does not exist yet.
+func (r *MetalsoftMachineReconciler) getMetalsoftCluster(ctx context.Context, cluster *clusterv1.Cluster) (*infrastructurev1beta1.MetalsoftCluster, error) {
+	if cluster.Spec.InfrastructureRef == nil {
+		return nil, nil
+	}
+	metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{}
+	key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Get(ctx, key, metalsoftCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return metalsoftCluster, nil
+}
+
+// getBootstrapData returns the bootstrap data generated for machine by the
+// bootstrap provider and its format, cloud-config unless the bootstrap
+// provider sets the format key.
+func (r *MetalsoftMachineReconciler) getBootstrapData(ctx context.Context, machine *clusterv1.Machine) (string, string, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}
+	if err := r.Get(ctx, key, secret); err != nil {
+		return "", "", fmt.Errorf("failed to get bootstrap data secret %s: %w", key, err)
+	}
+	value, ok := secret.Data["value"]
+	if !ok {
+		return "", "", fmt.Errorf("bootstrap data secret %s is missing the value key", key)
+	}
+	format := metalsoft.BootstrapFormatCloudConfig
+	if f, ok := secret.Data["format"]; ok && len(f) > 0 {
+		format = string(f)
+	}
+	return string(value), format, nil
+}
+
+// withKubeVIP returns the bootstrap data of a control plane machine with the
+// kube-vip static pod manifest added when the cluster endpoint is announced
+// by kube-vip, as a cloud-config or Ignition file depending on format.
+// kube-vip uses the kubeconfig at the given path of the server.
+func withKubeVIP(metalsoftCluster *infrastructurev1beta1.MetalsoftCluster, bootstrapData, format, kubeconfig string) (string, error) {
+	loadBalancer := metalsoftCluster.Spec.ControlPlaneLoadBalancer
+	if loadBalancer == nil || loadBalancer.Type != infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP {
+		return bootstrapData, nil
+	}
+
+	config := kubevip.Config{
+		Address:    metalsoftCluster.Spec.ControlPlaneEndpoint.Host,
+		Port:       metalsoftCluster.Spec.ControlPlaneEndpoint.Port,
+		Kubeconfig: kubeconfig,
+	}
+	// The endpoint host may be a DNS name pointing at the reserved address.
+	if status := metalsoftCluster.Status.ControlPlaneLoadBalancer; status != nil {
+		config.Address = status.Address
+		config.PrefixLength = status.PrefixLength
+	}
+	if loadBalancer.KubeVIP != nil {
+		config.Image = loadBalancer.KubeVIP.Image
+		config.Interface = loadBalancer.KubeVIP.Interface
+	}
+	manifest, err := kubevip.Manifest(config)
+	if err != nil {
+		return "", err
+	}
+	if format == metalsoft.BootstrapFormatIgnition {
+		data, err := ignition.AddFiles([]byte(bootstrapData), ignition.File{
+			Path:    kubevip.ManifestPath,
+			Mode:    0644,
+			Content: string(manifest),
+		})
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	data, err := cloudinit.AddWriteFiles([]byte(bootstrapData), cloudinit.WriteFile{
+		Path:        kubevip.ManifestPath,
+		Owner:       "root:root",
+		Permissions: "0644",
+		Content:     string(manifest),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// kubeVIPKubeconfig returns the kubeconfig kube-vip uses on a control plane
+// machine: the super-admin kubeconfig on the machine initializing a cluster of
+// Kubernetes 1.29 or later, and the admin kubeconfig otherwise. The machine
+// initializing the cluster is the one whose KubeadmConfig has no join
+// configuration, like kubeadm control plane providers create for the first
+// control plane machine.
+func (r *MetalsoftMachineReconciler) kubeVIPKubeconfig(ctx context.Context, machine *clusterv1.Machine) (string, error) {
+	ref := machine.Spec.Bootstrap.ConfigRef
+	if machine.Spec.Version == nil || ref == nil ||
+		ref.Kind != "KubeadmConfig" || ref.GroupVersionKind().Group != bootstrapv1.GroupVersion.Group {
+		return kubevip.AdminKubeconfig, nil
+	}
+	version, err := utilversion.ParseGeneric(*machine.Spec.Version)
+	if err != nil || version.LessThan(superAdminKubeconfigVersion) {
+		return kubevip.AdminKubeconfig, nil
+	}
+
+	config := &bootstrapv1.KubeadmConfig{}
+	key := client.ObjectKey{Namespace: machine.Namespace, Name: ref.Name}
+	if err := r.Get(ctx, key, config); err != nil {
+		return "", fmt.Errorf("failed to get KubeadmConfig %s: %w", key, err)
+	}
+	if config.Spec.JoinConfiguration != nil {
+		return kubevip.AdminKubeconfig, nil
+	}
+	return kubevip.SuperAdminKubeconfig, nil
+}
+
+// deployInfrastructure starts a deploy of the infrastructure unless one is
+// already running, in which case the pending changes are picked up by the
+// next deploy.
+func deployInfrastructure(ctx context.Context, msClient metalsoft.Client, infrastructureID int) error {
+	infrastructure, err := msClient.GetInfrastructure(ctx, infrastructureID)
+	if err != nil {
+		return fmt.Errorf("failed to get MetalSoft infrastructure %d: %w", infrastructureID, err)
+	}
+	if infrastructure.DeployOngoing() {
+		return nil
+	}
+	log.FromContext(ctx).Info("Deploying MetalSoft infrastructure", "infrastructureID", infrastructureID)
+	if err := msClient.DeployInfrastructure(ctx, infrastructureID); err != nil && !metalsoft.IsConflict(err) {
+		return fmt.Errorf("failed to deploy MetalSoft infrastructure %d: %w", infrastructureID, err)
+	}
+	return nil
+}
+
+// clusterNetworksReady returns false while a network of spec.networks of the
+// MetalsoftCluster that the machine attaches to is not deployed yet, e.g.
+// because it was just added to a running cluster.
+func clusterNetworksReady(metalsoftMachine *infrastructurev1beta1.MetalsoftMachine, metalsoftCluster *infrastructurev1beta1.MetalsoftCluster) bool {
+	if conditions.IsTrue(metalsoftCluster, infrastructurev1beta1.NetworksReadyCondition) {
+		return true
+	}
+	for _, networkInterface := range metalsoftMachine.Spec.NetworkInterfaces {
+		for _, network := range metalsoftCluster.Spec.Networks {
+			if network.Name == networkInterface.Network {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// instanceAddresses returns the machine addresses of a deployed instance.
+// Addresses on WAN networks are reported as external, all others as internal.
+func instanceAddresses(ctx context.Context, msClient metalsoft.Client, infrastructureID int, instance *metalsoft.Instance) ([]clusterv1.MachineAddress, error) {
+	networks, err := msClient.ListNetworks(ctx, infrastructureID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list MetalSoft networks: %w", err)
+	}
+	networkTypes := map[int]string{}
+	for _, network := range networks {
+		networkTypes[network.ID] = network.Type
+	}
+
+	var addresses []clusterv1.MachineAddress
+	if instance.Hostname != "" {
+		addresses = append(addresses, clusterv1.MachineAddress{Type: clusterv1.MachineHostName, Address: instance.Hostname})
+	}
+	for _, iface := range instance.Interfaces {
+		addressType := clusterv1.MachineInternalIP
+		if networkTypes[iface.NetworkID] == metalsoft.NetworkTypeWAN {
+			addressType = clusterv1.MachineExternalIP
+		}
+		for _, ip := range iface.IPs {
+			addresses = append(addresses, clusterv1.MachineAddress{Type: addressType, Address: ip.Address})
+		}
+	}
+	return addresses, nil
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *MetalsoftMachineReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	logger := mgr.GetLogger().WithName("metalsoftmachine")
+
+	clusterToMetalsoftMachines, err := util.ClusterToObjectsMapper(mgr.GetClient(), &infrastructurev1beta1.MetalsoftMachineList{}, mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&infrastructurev1alpha1.MetalsoftMachine{}).
+		For(&infrastructurev1beta1.MetalsoftMachine{}).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles}).
+		WithEventFilter(predicates.ResourceNotPaused(logger)).
+		Owns(&ipamv1.IPAddressClaim{}).
+		Watches(
+			&clusterv1.Machine{},
+			handler.EnqueueRequestsFromMapFunc(util.MachineToInfrastructureMapFunc(infrastructurev1beta1.GroupVersion.WithKind("MetalsoftMachine"))),
+		).
+		Watches(
+			&clusterv1.Cluster{},
+			handler.EnqueueRequestsFromMapFunc(clusterToMetalsoftMachines),
+			builder.WithPredicates(predicates.ClusterUnpausedAndInfrastructureReady(logger)),
+		).
 		Complete(r)
 }
@@ -0,0 +1,825 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/configdrive"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/kubevip"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+const testBootstrapData = "#cloud-config\nruncmd:\n- kubeadm join\n"
+
+const testIgnitionBootstrapData = `{"ignition":{"version":"2.3.0"},"systemd":{"units":[{"name":"kubeadm.service","enabled":true}]}}`
+
+// newReadyTestCluster creates a cluster with newTestCluster, waits for its
+// MetalsoftCluster to be ready and marks the Cluster infrastructure as ready
+// like the Cluster API cluster controller would.
+func newReadyTestCluster(name string, mutate func(*infrastructurev1beta1.MetalsoftCluster)) (*clusterv1.Cluster, *infrastructurev1beta1.MetalsoftCluster) {
+	cluster, metalsoftCluster := newTestCluster(name, mutate)
+
+	Eventually(func(g Gomega) {
+		g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftCluster), metalsoftCluster)).To(Succeed())
+		g.Expect(metalsoftCluster.Status.Ready).To(BeTrue())
+	}, timeout, interval).Should(Succeed())
+
+	cluster.Status.InfrastructureReady = true
+	Expect(k8sClient.Status().Update(ctx, cluster)).To(Succeed())
+	return cluster, metalsoftCluster
+}
+
+// newTestMachine creates a Machine of cluster owning a MetalsoftMachine. The
+// Machine has no bootstrap data until setBootstrapData is called.
+func newTestMachine(cluster *clusterv1.Cluster, name string, mutate func(*infrastructurev1beta1.MetalsoftMachine)) (*clusterv1.Machine, *infrastructurev1beta1.MetalsoftMachine) {
+	machine := &clusterv1.Machine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cluster.Namespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+		},
+		Spec: clusterv1.MachineSpec{
+			ClusterName: cluster.Name,
+			InfrastructureRef: corev1.ObjectReference{
+				APIVersion: infrastructurev1beta1.GroupVersion.String(),
+				Kind:       "MetalsoftMachine",
+				Name:       name,
+				Namespace:  cluster.Namespace,
+			},
+		},
+	}
+	Expect(k8sClient.Create(ctx, machine)).To(Succeed())
+
+	metalsoftMachine := &infrastructurev1beta1.MetalsoftMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: cluster.Namespace,
+			Labels:    map[string]string{clusterv1.ClusterNameLabel: cluster.Name},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Machine",
+				Name:       machine.Name,
+				UID:        machine.UID,
+			}},
+		},
+		Spec: infrastructurev1beta1.MetalsoftMachineSpec{
+			ServerType:      "M.16.64.v2",
+			OSTemplate:      "ubuntu-22-04",
+			BootDriveSizeGB: 40,
+		},
+	}
+	if mutate != nil {
+		mutate(metalsoftMachine)
+	}
+	Expect(k8sClient.Create(ctx, metalsoftMachine)).To(Succeed())
+	return machine, metalsoftMachine
+}
+
+// newTestControlPlaneMachine creates a control plane Machine of cluster
+// owning a MetalsoftMachine, labelled like a control plane provider would, and
+// provides its bootstrap data.
+func newTestControlPlaneMachine(cluster *clusterv1.Cluster, name string) (*clusterv1.Machine, *infrastructurev1beta1.MetalsoftMachine) {
+	machine, metalsoftMachine := newTestMachine(cluster, name, func(mm *infrastructurev1beta1.MetalsoftMachine) {
+		mm.Labels[clusterv1.MachineControlPlaneLabel] = ""
+	})
+	machine.Labels[clusterv1.MachineControlPlaneLabel] = ""
+	Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+	setBootstrapData(machine, testBootstrapData)
+	return machine, metalsoftMachine
+}
+
+// waitForReadyMachines waits for the given MetalsoftMachines to be ready.
+func waitForReadyMachines(metalsoftMachines ...*infrastructurev1beta1.MetalsoftMachine) {
+	for _, metalsoftMachine := range metalsoftMachines {
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(metalsoftMachine), metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+	}
+}
+
+// setBootstrapData stores bootstrap data in a Secret and references it from
+// machine like a bootstrap provider would.
+func setBootstrapData(machine *clusterv1.Machine, data string) {
+	setBootstrapDataWithFormat(machine, data, "")
+}
+
+// setBootstrapDataWithFormat is like setBootstrapData but also sets the
+// format key of the Secret, unless format is empty.
+func setBootstrapDataWithFormat(machine *clusterv1.Machine, data, format string) {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: machine.Name + "-bootstrap", Namespace: machine.Namespace},
+		Data:       map[string][]byte{"value": []byte(data)},
+	}
+	if format != "" {
+		secret.Data["format"] = []byte(format)
+	}
+	Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+
+	Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+	machine.Spec.Bootstrap.DataSecretName = pointer.String(secret.Name)
+	Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+}
+
+// allocateIPAddress allocates address to claim like an IPAM provider would.
+func allocateIPAddress(claim *ipamv1.IPAddressClaim, address, gateway string) {
+	ipAddress := &ipamv1.IPAddress{
+		ObjectMeta: metav1.ObjectMeta{Name: claim.Name, Namespace: claim.Namespace},
+		Spec: ipamv1.IPAddressSpec{
+			ClaimRef: corev1.LocalObjectReference{Name: claim.Name},
+			PoolRef:  claim.Spec.PoolRef,
+			Address:  address,
+			Prefix:   24,
+			Gateway:  gateway,
+		},
+	}
+	Expect(k8sClient.Create(ctx, ipAddress)).To(Succeed())
+	claim.Status.AddressRef = corev1.LocalObjectReference{Name: ipAddress.Name}
+	Expect(k8sClient.Status().Update(ctx, claim)).To(Succeed())
+}
+
+var _ = Describe("MetalsoftMachine controller", func() {
+	It("provisions a server once bootstrap data is available", func() {
+		cluster, _ := newReadyTestCluster("provision", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "provision-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.AdditionalDriveArrays = []infrastructurev1beta1.DriveArraySpec{{Label: "data", SizeGB: 100}}
+		})
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftMachine, infrastructurev1beta1.InstanceReadyCondition)).
+				To(Equal(infrastructurev1beta1.WaitingForBootstrapDataReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+
+		setBootstrapData(machine, testBootstrapData)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+
+		Expect(metalsoftMachine.Status.InstanceID).NotTo(BeNil())
+		Expect(metalsoftMachine.Spec.ProviderID).To(HaveValue(HavePrefix(ProviderIDPrefix)))
+		Expect(*metalsoftMachine.Status.InstanceState).To(Equal(infrastructurev1beta1.InstanceStateActive))
+		Expect(metalsoftMachine.Status.Addresses).To(ContainElement(HaveField("Type", clusterv1.MachineExternalIP)))
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.Label).To(Equal(metalsoftMachine.Name))
+		Expect(instanceArray.CloudInitData).To(Equal(testBootstrapData))
+		Expect(instanceArray.BootDriveSizeMB).To(Equal(40 * 1024))
+		Expect(instanceArray.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	})
+
+	It("stops and deletes the instance array when deleted", func() {
+		cluster, _ := newReadyTestCluster("teardown", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "teardown-md-0", nil)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		available := func() int {
+			serverType, ok := metalsoftServer.ServerType(metalsoftMachine.Spec.ServerType)
+			Expect(ok).To(BeTrue())
+			return serverType.AvailableCount
+		}
+		// Servers are only allocated once the bootstrap data is available.
+		before := available()
+		setBootstrapData(machine, testBootstrapData)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.Ready).To(BeTrue())
+		}, timeout, interval).Should(Succeed())
+		Expect(available()).To(Equal(before - 1))
+		instanceArrayID := *metalsoftMachine.Status.InstanceArrayID
+
+		Expect(k8sClient.Delete(ctx, metalsoftMachine)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftMachine))
+		}, timeout, interval).Should(BeTrue())
+
+		_, ok := metalsoftServer.InstanceArray(instanceArrayID)
+		Expect(ok).To(BeFalse())
+		Expect(metalsoftServer.Calls("instance_array_stop")).To(BeNumerically(">=", 1))
+		Expect(available()).To(Equal(before))
+	})
+
+	It("is deleted without credentials when no instance array was created", func() {
+		cluster, _ := newTestCluster("teardown-no-credentials", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: "does-not-exist"}
+		})
+		_, metalsoftMachine := newTestMachine(cluster, "teardown-no-credentials-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Finalizers = []string{infrastructurev1beta1.MachineFinalizer}
+		})
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Expect(k8sClient.Delete(ctx, metalsoftMachine)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, key, metalsoftMachine))
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("fails when the OS template does not exist", func() {
+		cluster, _ := newReadyTestCluster("bad-template", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "bad-template-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.OSTemplate = "does-not-exist"
+		})
+		setBootstrapData(machine, testBootstrapData)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.FailureReason).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftMachine.Status.Ready).To(BeFalse())
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+	})
+
+	It("attaches the server interfaces to the networks of the cluster", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("interfaces", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{
+				{Name: "private", Type: infrastructurev1beta1.NetworkTypeLAN},
+				{Name: "storage", Type: infrastructurev1beta1.NetworkTypeSAN},
+			}
+		})
+		machine, metalsoftMachine := newTestMachine(cluster, "interfaces-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.NetworkInterfaces = []infrastructurev1beta1.NetworkInterfaceSpec{
+				{Index: 0, Network: "wan"},
+				{Index: 1, Network: "private"},
+				{Index: 2, Network: "storage"},
+			}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		waitForReadyMachines(metalsoftMachine)
+
+		networkIDs := map[string]int{}
+		for _, network := range metalsoftServer.Networks(*metalsoftCluster.Status.InfrastructureID) {
+			networkIDs[network.Label] = network.ID
+		}
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.Interfaces).To(ConsistOf(
+			metalsoft.InstanceArrayInterface{Index: 0, NetworkID: networkIDs["wan"]},
+			metalsoft.InstanceArrayInterface{Index: 1, NetworkID: networkIDs["private"]},
+			metalsoft.InstanceArrayInterface{Index: 2, NetworkID: networkIDs["storage"]},
+		))
+
+		var external, internal int
+		for _, address := range metalsoftMachine.Status.Addresses {
+			switch address.Type {
+			case clusterv1.MachineExternalIP:
+				external++
+			case clusterv1.MachineInternalIP:
+				internal++
+			}
+		}
+		Expect(external).To(Equal(1))
+		Expect(internal).To(Equal(2))
+	})
+
+	It("fails when an interface references an unknown network", func() {
+		cluster, _ := newReadyTestCluster("bad-network", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "bad-network-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.NetworkInterfaces = []infrastructurev1beta1.NetworkInterfaceSpec{{Index: 0, Network: "does-not-exist"}}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.FailureReason).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(*metalsoftMachine.Status.FailureMessage).To(ContainSubstring("does-not-exist"))
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+	})
+
+	It("configures static addresses claimed from IPAM pools", func() {
+		cluster, _ := newReadyTestCluster("ipam", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{{Name: "private", Type: infrastructurev1beta1.NetworkTypeLAN}}
+		})
+		pool := corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "nodes"}
+		machine, metalsoftMachine := newTestMachine(cluster, "ipam-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.NetworkInterfaces = []infrastructurev1beta1.NetworkInterfaceSpec{
+				{Index: 0, Network: "wan"},
+				{Index: 1, Network: "private", AddressesFromPools: []corev1.TypedLocalObjectReference{pool}},
+			}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		By("waiting for the machine to claim an address")
+		claim := &ipamv1.IPAddressClaim{}
+		claimKey := client.ObjectKey{Namespace: cluster.Namespace, Name: "ipam-md-0-1-0"}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, claimKey, claim)).To(Succeed())
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(conditions.GetReason(metalsoftMachine, infrastructurev1beta1.IPAddressClaimedCondition)).
+				To(Equal(infrastructurev1beta1.WaitingForIPAddressReason))
+		}, timeout, interval).Should(Succeed())
+		Expect(claim.Spec.PoolRef).To(Equal(pool))
+		Expect(metav1.IsControlledBy(claim, metalsoftMachine)).To(BeTrue())
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+
+		By("allocating the address like an IPAM provider would")
+		allocateIPAddress(claim, "10.10.0.20", "10.10.0.1")
+
+		waitForReadyMachines(metalsoftMachine)
+		Expect(conditions.IsTrue(metalsoftMachine, infrastructurev1beta1.IPAddressClaimedCondition)).To(BeTrue())
+		Expect(metalsoftMachine.Status.Addresses).To(ContainElement(
+			clusterv1.MachineAddress{Type: clusterv1.MachineInternalIP, Address: "10.10.0.20"}))
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.Interfaces[1].StaticIPs).To(Equal([]metalsoft.StaticIP{
+			{Address: "10.10.0.20", Prefix: 24, Gateway: "10.10.0.1"},
+		}))
+		Expect(instanceArray.CloudInitNetwork).To(ContainSubstring("10.10.0.20/24"))
+		Expect(instanceArray.CloudInitNetwork).To(ContainSubstring("dhcp4: true"))
+
+		By("releasing the address when the machine is deleted")
+		Expect(k8sClient.Delete(ctx, metalsoftMachine)).To(Succeed())
+		Eventually(func() bool {
+			return apierrors.IsNotFound(k8sClient.Get(ctx, claimKey, claim))
+		}, timeout, interval).Should(BeTrue())
+	})
+
+	It("configures static addresses through config drives and Ignition", func() {
+		cluster, _ := newReadyTestCluster("ipam-os", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Networks = []infrastructurev1beta1.NetworkSpec{{Name: "private", Type: infrastructurev1beta1.NetworkTypeLAN}}
+		})
+		pool := corev1.TypedLocalObjectReference{APIGroup: pointer.String("ipam.cluster.x-k8s.io"), Kind: "InClusterIPPool", Name: "nodes"}
+		networkInterfaces := []infrastructurev1beta1.NetworkInterfaceSpec{
+			{Index: 0, Network: "wan"},
+			{Index: 1, Network: "private", AddressesFromPools: []corev1.TypedLocalObjectReference{pool}},
+		}
+		configDrive, configDriveMachine := newTestMachine(cluster, "ipam-os-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.NetworkInterfaces = networkInterfaces
+			mm.Spec.BootstrapDelivery = infrastructurev1beta1.BootstrapDeliveryConfigDrive
+		})
+		flatcar, flatcarMachine := newTestMachine(cluster, "ipam-os-md-1", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.NetworkInterfaces = networkInterfaces
+			mm.Spec.OSTemplate = "flatcar-stable"
+		})
+		setBootstrapData(configDrive, testBootstrapData)
+		setBootstrapDataWithFormat(flatcar, testIgnitionBootstrapData, metalsoft.BootstrapFormatIgnition)
+
+		for name, address := range map[string]string{"ipam-os-md-0-1-0": "10.10.1.20", "ipam-os-md-1-1-0": "10.10.1.21"} {
+			claim := &ipamv1.IPAddressClaim{}
+			Eventually(func() error {
+				return k8sClient.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: name}, claim)
+			}, timeout, interval).Should(Succeed())
+			allocateIPAddress(claim, address, "10.10.1.1")
+		}
+		waitForReadyMachines(configDriveMachine, flatcarMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*configDriveMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.ConfigDriveISO).To(ContainSubstring(`"ip_address":"10.10.1.20","netmask":"255.255.255.0"`))
+		Expect(instanceArray.ConfigDriveISO).To(ContainSubstring(`"type":"ipv4_dhcp","link":"eth0"`))
+
+		instanceArray, ok = metalsoftServer.InstanceArray(*flatcarMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		config := struct {
+			Storage struct {
+				Files []struct {
+					Path     string `json:"path"`
+					Contents struct {
+						Source string `json:"source"`
+					} `json:"contents"`
+				} `json:"files"`
+			} `json:"storage"`
+		}{}
+		Expect(json.Unmarshal([]byte(instanceArray.CloudInitData), &config)).To(Succeed())
+		units := map[string]string{}
+		for _, file := range config.Storage.Files {
+			content, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(file.Contents.Source, "data:;base64,"))
+			Expect(err).NotTo(HaveOccurred())
+			units[file.Path] = string(content)
+		}
+		Expect(units).To(Equal(map[string]string{
+			"/etc/systemd/network/10-eth0.network": "[Match]\nName=eth0\n\n[Network]\nDHCP=ipv4\n",
+			"/etc/systemd/network/10-eth1.network": "[Match]\nName=eth1\n\n[Network]\nAddress=10.10.1.21/24\nGateway=10.10.1.1\n",
+		}))
+	})
+
+	It("places the server in the racks of its failure domain", func() {
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "lax-r1", Datacenter: "us-lax-dc"})
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "lax-r2", Datacenter: "us-lax-dc"})
+		metalsoftServer.AddRack(metalsoft.Rack{Label: "lax-r3", Datacenter: "us-lax-dc"})
+
+		cluster, _ := newReadyTestCluster("placement", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-lax-dc"
+			mc.Spec.FailureDomains = []infrastructurev1beta1.FailureDomainSpec{
+				{Name: "zone-a", Racks: []string{"lax-r1"}},
+				{Name: "zone-b", Racks: []string{"lax-r2", "lax-r3"}},
+			}
+		})
+		machine, metalsoftMachine := newTestMachine(cluster, "placement-md-0", nil)
+		machine.Spec.FailureDomain = pointer.String("zone-b")
+		Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+		setBootstrapData(machine, testBootstrapData)
+		waitForReadyMachines(metalsoftMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.RackLabels).To(ConsistOf("lax-r2", "lax-r3"))
+
+		Expect(metalsoftMachine.Status.Placement).NotTo(BeNil())
+		Expect(metalsoftMachine.Status.Placement.Datacenter).To(Equal("us-lax-dc"))
+		Expect(metalsoftMachine.Status.Placement.FailureDomain).To(Equal("zone-b"))
+		Expect(metalsoftMachine.Status.Placement.Rack).To(BeElementOf("lax-r2", "lax-r3"))
+	})
+
+	It("deploys the smallest server satisfying its hardware requirements", func() {
+		serverType, ok := metalsoftServer.ServerType("M.40.256.v3")
+		Expect(ok).To(BeTrue())
+		nvme := func(count int) []metalsoft.ServerDisk {
+			var disks []metalsoft.ServerDisk
+			for i := 0; i < count; i++ {
+				disks = append(disks, metalsoft.ServerDisk{SizeGB: 3840, Type: metalsoft.DiskTypeNVMe})
+			}
+			return disks
+		}
+		metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-atl-dc",
+			ProcessorCoreCount: 64, RAMGB: 512, Disks: nvme(4)})
+		storage := metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-atl-dc",
+			ProcessorCoreCount: 32, RAMGB: 256, Disks: nvme(4)})
+		metalsoftServer.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "us-atl-dc",
+			ProcessorCoreCount: 16, RAMGB: 128, Disks: nvme(2)})
+
+		cluster, _ := newReadyTestCluster("hardware", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.Datacenter = "us-atl-dc"
+		})
+		machine, metalsoftMachine := newTestMachine(cluster, "hardware-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.ServerType = ""
+			mm.Spec.HardwareRequirements = &infrastructurev1beta1.HardwareRequirements{
+				DiskType: metalsoft.DiskTypeNVMe,
+				MinDisks: 4,
+			}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		waitForReadyMachines(metalsoftMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*metalsoftMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.ServerID).To(Equal(storage.ID))
+		Expect(conditions.IsTrue(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition)).To(BeTrue())
+	})
+
+	It("reports when no server satisfies its hardware requirements", func() {
+		cluster, _ := newReadyTestCluster("no-hardware", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "no-hardware-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.HardwareRequirements = &infrastructurev1beta1.HardwareRequirements{MinRAMGB: 4096}
+		})
+		setBootstrapData(machine, testBootstrapData)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			condition := conditions.Get(metalsoftMachine, infrastructurev1beta1.ServerSelectedCondition)
+			g.Expect(condition).NotTo(BeNil())
+			g.Expect(condition.Reason).To(Equal(infrastructurev1beta1.NoMatchingServerReason))
+			g.Expect(condition.Message).To(ContainSubstring("server type M.16.64.v2, at least 4096 GB of RAM"))
+		}, timeout, interval).Should(Succeed())
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+		Expect(metalsoftMachine.Status.FailureReason).To(BeNil())
+	})
+
+	It("fails when the machine is in an unknown failure domain", func() {
+		cluster, _ := newReadyTestCluster("bad-failure-domain", nil)
+		machine, metalsoftMachine := newTestMachine(cluster, "bad-failure-domain-md-0", nil)
+		machine.Spec.FailureDomain = pointer.String("does-not-exist")
+		Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+		setBootstrapData(machine, testBootstrapData)
+		key := client.ObjectKeyFromObject(metalsoftMachine)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, metalsoftMachine)).To(Succeed())
+			g.Expect(metalsoftMachine.Status.FailureReason).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(*metalsoftMachine.Status.FailureMessage).To(ContainSubstring("does-not-exist"))
+		Expect(metalsoftMachine.Status.InstanceArrayID).To(BeNil())
+	})
+
+	It("registers control plane machines with the endpoint load balancer", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("lb-backends", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeLoadBalancer,
+			}
+		})
+		loadBalancerID := metalsoftCluster.Status.ControlPlaneLoadBalancer.ID
+
+		_, first := newTestControlPlaneMachine(cluster, "lb-backends-cp-0")
+		_, second := newTestControlPlaneMachine(cluster, "lb-backends-cp-1")
+		worker, workerMachine := newTestMachine(cluster, "lb-backends-md-0", nil)
+		setBootstrapData(worker, testBootstrapData)
+		waitForReadyMachines(first, second, workerMachine)
+
+		backendInstances := func() []int {
+			loadBalancer, ok := metalsoftServer.LoadBalancer(loadBalancerID)
+			Expect(ok).To(BeTrue())
+			var instanceIDs []int
+			for _, backend := range loadBalancer.Backends {
+				instanceIDs = append(instanceIDs, backend.InstanceID)
+			}
+			return instanceIDs
+		}
+		Expect(backendInstances()).To(ConsistOf(*first.Status.InstanceID, *second.Status.InstanceID))
+
+		Expect(k8sClient.Delete(ctx, first)).To(Succeed())
+		Eventually(backendInstances, timeout, interval).Should(ConsistOf(*second.Status.InstanceID))
+	})
+
+	It("forwards the endpoint load balancer to the port of the control plane endpoint", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("lb-port", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Port: 8443}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeLoadBalancer,
+			}
+		})
+
+		_, metalsoftMachine := newTestControlPlaneMachine(cluster, "lb-port-cp-0")
+		waitForReadyMachines(metalsoftMachine)
+
+		loadBalancer, ok := metalsoftServer.LoadBalancer(metalsoftCluster.Status.ControlPlaneLoadBalancer.ID)
+		Expect(ok).To(BeTrue())
+		Expect(loadBalancer.Port).To(Equal(8443))
+		Expect(loadBalancer.Backends).To(ConsistOf(And(
+			HaveField("InstanceID", *metalsoftMachine.Status.InstanceID),
+			HaveField("Port", 8443),
+		)))
+	})
+
+	It("hands the endpoint floating IP over between control plane machines", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("fip-handover", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeFloatingIP,
+			}
+		})
+		floatingIPID := metalsoftCluster.Status.ControlPlaneLoadBalancer.ID
+
+		_, first := newTestControlPlaneMachine(cluster, "fip-handover-cp-0")
+		_, second := newTestControlPlaneMachine(cluster, "fip-handover-cp-1")
+		waitForReadyMachines(first, second)
+
+		routedTo := func() int {
+			floatingIP, ok := metalsoftServer.FloatingIP(floatingIPID)
+			Expect(ok).To(BeTrue())
+			return floatingIP.InstanceID
+		}
+		Expect(routedTo()).To(BeElementOf(*first.Status.InstanceID, *second.Status.InstanceID))
+
+		routed, standby := first, second
+		if routedTo() == *second.Status.InstanceID {
+			routed, standby = second, first
+		}
+		Expect(k8sClient.Delete(ctx, routed)).To(Succeed())
+		Eventually(routedTo, timeout, interval).Should(Equal(*standby.Status.InstanceID))
+	})
+
+	It("adds the kube-vip manifest to the control plane bootstrap data", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("kube-vip", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type:    infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP,
+				KubeVIP: &infrastructurev1beta1.KubeVIPSpec{Interface: "bond0"},
+			}
+		})
+		reservedIP, ok := metalsoftServer.ReservedIP(metalsoftCluster.Status.ControlPlaneLoadBalancer.ID)
+		Expect(ok).To(BeTrue())
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(reservedIP.Address))
+
+		_, controlPlane := newTestControlPlaneMachine(cluster, "kube-vip-cp-0")
+		worker, workerMachine := newTestMachine(cluster, "kube-vip-md-0", nil)
+		setBootstrapData(worker, testBootstrapData)
+		waitForReadyMachines(controlPlane, workerMachine)
+
+		cloudInitData := func(mm *infrastructurev1beta1.MetalsoftMachine) string {
+			instanceArray, ok := metalsoftServer.InstanceArray(*mm.Status.InstanceArrayID)
+			Expect(ok).To(BeTrue())
+			return instanceArray.CloudInitData
+		}
+		Expect(cloudInitData(controlPlane)).To(HavePrefix("#cloud-config\n"))
+		Expect(cloudInitData(controlPlane)).To(ContainSubstring(kubevip.ManifestPath))
+		Expect(cloudInitData(controlPlane)).To(ContainSubstring(reservedIP.Address))
+		Expect(cloudInitData(controlPlane)).To(ContainSubstring("bond0"))
+		Expect(cloudInitData(controlPlane)).To(ContainSubstring("kubeadm join"))
+		Expect(cloudInitData(workerMachine)).To(Equal(testBootstrapData))
+
+		// The address is added with the prefix length of the subnet of the
+		// wan network it is reserved in.
+		Expect(metalsoftCluster.Status.ControlPlaneLoadBalancer.PrefixLength).To(Equal(24))
+		Expect(cloudInitData(controlPlane)).To(MatchRegexp(`name: vip_cidr\s+value: "24"`))
+	})
+
+	It("mounts the super-admin kubeconfig into kube-vip on the machine initializing the cluster", func() {
+		cluster, _ := newReadyTestCluster("kube-vip-init", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint.Host = ""
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP,
+			}
+		})
+
+		// The machines are set up like a kubeadm control plane provider
+		// would, with a KubeadmConfig initializing or joining the cluster.
+		newKubeadmMachine := func(name string, spec bootstrapv1.KubeadmConfigSpec) *infrastructurev1beta1.MetalsoftMachine {
+			Expect(k8sClient.Create(ctx, &bootstrapv1.KubeadmConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: cluster.Namespace},
+				Spec:       spec,
+			})).To(Succeed())
+			machine, metalsoftMachine := newTestMachine(cluster, name, func(mm *infrastructurev1beta1.MetalsoftMachine) {
+				mm.Labels[clusterv1.MachineControlPlaneLabel] = ""
+			})
+			machine.Labels[clusterv1.MachineControlPlaneLabel] = ""
+			machine.Spec.Version = pointer.String("v1.29.0")
+			machine.Spec.Bootstrap.ConfigRef = &corev1.ObjectReference{
+				APIVersion: bootstrapv1.GroupVersion.String(),
+				Kind:       "KubeadmConfig",
+				Name:       name,
+				Namespace:  cluster.Namespace,
+			}
+			Expect(k8sClient.Update(ctx, machine)).To(Succeed())
+			setBootstrapData(machine, testBootstrapData)
+			return metalsoftMachine
+		}
+		initMachine := newKubeadmMachine("kube-vip-init-cp-0", bootstrapv1.KubeadmConfigSpec{
+			InitConfiguration: &bootstrapv1.InitConfiguration{},
+		})
+		joinMachine := newKubeadmMachine("kube-vip-init-cp-1", bootstrapv1.KubeadmConfigSpec{
+			JoinConfiguration: &bootstrapv1.JoinConfiguration{},
+		})
+		waitForReadyMachines(initMachine, joinMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*initMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.CloudInitData).To(ContainSubstring(kubevip.SuperAdminKubeconfig))
+		instanceArray, ok = metalsoftServer.InstanceArray(*joinMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.CloudInitData).To(ContainSubstring(kubevip.ManifestPath))
+		Expect(instanceArray.CloudInitData).NotTo(ContainSubstring(kubevip.SuperAdminKubeconfig))
+	})
+
+	It("delivers the bootstrap data through a custom variable or a config drive", func() {
+		cluster, _ := newReadyTestCluster("delivery", nil)
+		variables, variablesMachine := newTestMachine(cluster, "delivery-md-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.BootstrapDelivery = infrastructurev1beta1.BootstrapDeliveryCustomVariables
+			mm.Spec.CustomVariables = map[string]string{"ntp_server": "10.0.0.1"}
+		})
+		configDrive, configDriveMachine := newTestMachine(cluster, "delivery-md-1", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.BootstrapDelivery = infrastructurev1beta1.BootstrapDeliveryConfigDrive
+		})
+		setBootstrapData(variables, testBootstrapData)
+		setBootstrapData(configDrive, testBootstrapData)
+		waitForReadyMachines(variablesMachine, configDriveMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*variablesMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.CloudInitData).To(BeEmpty())
+		Expect(instanceArray.CustomVariables).To(HaveKeyWithValue("ntp_server", "10.0.0.1"))
+		compressed, err := base64.StdEncoding.DecodeString(instanceArray.CustomVariables[infrastructurev1beta1.BootstrapDataCustomVariable])
+		Expect(err).NotTo(HaveOccurred())
+		r, err := gzip.NewReader(bytes.NewReader(compressed))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(io.ReadAll(r)).To(BeEquivalentTo(testBootstrapData))
+
+		instanceArray, ok = metalsoftServer.InstanceArray(*configDriveMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.CloudInitData).To(BeEmpty())
+		Expect(instanceArray.ConfigDriveISO).To(ContainSubstring(configdrive.Label))
+		Expect(instanceArray.ConfigDriveISO).To(ContainSubstring(testBootstrapData))
+		Expect(instanceArray.ConfigDriveISO).To(ContainSubstring(string(configDriveMachine.UID)))
+	})
+
+	It("fails when the bootstrap data exceeds what its delivery method carries", func() {
+		cluster, _ := newReadyTestCluster("oversized", nil)
+		bootstrapData := testBootstrapData + "write_files:\n- path: /etc/motd\n  content: " +
+			strings.Repeat("a", metalsoft.MaxCloudInitDataBytes) + "\n"
+		cloudInit, cloudInitMachine := newTestMachine(cluster, "oversized-md-0", nil)
+		configDrive, configDriveMachine := newTestMachine(cluster, "oversized-md-1", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.BootstrapDelivery = infrastructurev1beta1.BootstrapDeliveryConfigDrive
+		})
+		setBootstrapData(cloudInit, bootstrapData)
+		setBootstrapData(configDrive, bootstrapData)
+
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(cloudInitMachine), cloudInitMachine)).To(Succeed())
+			g.Expect(conditions.GetReason(cloudInitMachine, infrastructurev1beta1.InstanceReadyCondition)).
+				To(Equal(infrastructurev1beta1.BootstrapDataTooLargeReason))
+			// Conditions are patched before the rest of the status.
+			g.Expect(cloudInitMachine.Status.FailureReason).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+		Expect(*cloudInitMachine.Status.FailureMessage).To(ContainSubstring("config-drive"))
+		Expect(cloudInitMachine.Status.InstanceArrayID).To(BeNil())
+
+		waitForReadyMachines(configDriveMachine)
+	})
+
+	It("passes Ignition bootstrap data through to OS templates running Ignition", func() {
+		cluster, metalsoftCluster := newReadyTestCluster("flatcar", func(mc *infrastructurev1beta1.MetalsoftCluster) {
+			mc.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{}
+			mc.Spec.ControlPlaneLoadBalancer = &infrastructurev1beta1.ControlPlaneLoadBalancer{
+				Type: infrastructurev1beta1.ControlPlaneLoadBalancerTypeKubeVIP,
+			}
+		})
+		flatcar := func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.OSTemplate = "flatcar-stable"
+		}
+		controlPlane, controlPlaneMachine := newTestMachine(cluster, "flatcar-cp-0", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			flatcar(mm)
+			mm.Labels[clusterv1.MachineControlPlaneLabel] = ""
+		})
+		controlPlane.Labels[clusterv1.MachineControlPlaneLabel] = ""
+		Expect(k8sClient.Update(ctx, controlPlane)).To(Succeed())
+		worker, workerMachine := newTestMachine(cluster, "flatcar-md-0", flatcar)
+		setBootstrapDataWithFormat(controlPlane, testIgnitionBootstrapData, metalsoft.BootstrapFormatIgnition)
+		setBootstrapDataWithFormat(worker, testIgnitionBootstrapData, metalsoft.BootstrapFormatIgnition)
+		waitForReadyMachines(controlPlaneMachine, workerMachine)
+
+		instanceArray, ok := metalsoftServer.InstanceArray(*workerMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		Expect(instanceArray.CloudInitData).To(Equal(testIgnitionBootstrapData))
+
+		instanceArray, ok = metalsoftServer.InstanceArray(*controlPlaneMachine.Status.InstanceArrayID)
+		Expect(ok).To(BeTrue())
+		config := struct {
+			Storage struct {
+				Files []struct {
+					Path     string `json:"path"`
+					Contents struct {
+						Source string `json:"source"`
+					} `json:"contents"`
+				} `json:"files"`
+			} `json:"storage"`
+			Systemd map[string]interface{} `json:"systemd"`
+		}{}
+		Expect(json.Unmarshal([]byte(instanceArray.CloudInitData), &config)).To(Succeed())
+		Expect(config.Systemd).To(HaveKey("units"))
+		Expect(config.Storage.Files).To(HaveLen(1))
+		Expect(config.Storage.Files[0].Path).To(Equal(kubevip.ManifestPath))
+		manifest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(config.Storage.Files[0].Contents.Source, "data:;base64,"))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(manifest)).To(ContainSubstring(metalsoftCluster.Spec.ControlPlaneEndpoint.Host))
+	})
+
+	It("fails when the OS template does not support the bootstrap data format", func() {
+		cluster, _ := newReadyTestCluster("format", nil)
+		ignitionOnUbuntu, ignitionOnUbuntuMachine := newTestMachine(cluster, "format-md-0", nil)
+		cloudConfigOnFlatcar, cloudConfigOnFlatcarMachine := newTestMachine(cluster, "format-md-1", func(mm *infrastructurev1beta1.MetalsoftMachine) {
+			mm.Spec.OSTemplate = "flatcar-stable"
+		})
+		setBootstrapDataWithFormat(ignitionOnUbuntu, testIgnitionBootstrapData, metalsoft.BootstrapFormatIgnition)
+		setBootstrapData(cloudConfigOnFlatcar, testBootstrapData)
+
+		for mm, message := range map[*infrastructurev1beta1.MetalsoftMachine]string{
+			ignitionOnUbuntuMachine:     `MetalSoft OS template "ubuntu-22-04" does not support ignition bootstrap data`,
+			cloudConfigOnFlatcarMachine: `MetalSoft OS template "flatcar-stable" does not support cloud-config bootstrap data`,
+		} {
+			Eventually(func(g Gomega) {
+				g.Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(mm), mm)).To(Succeed())
+				g.Expect(conditions.GetReason(mm, infrastructurev1beta1.InstanceReadyCondition)).
+					To(Equal(infrastructurev1beta1.BootstrapFormatUnsupportedReason))
+				// Conditions are patched before the rest of the status.
+				g.Expect(mm.Status.FailureReason).NotTo(BeNil())
+			}, timeout, interval).Should(Succeed())
+			Expect(conditions.GetMessage(mm, infrastructurev1beta1.InstanceReadyCondition)).To(Equal(message))
+			Expect(mm.Status.InstanceArrayID).To(BeNil())
+		}
+	})
+})
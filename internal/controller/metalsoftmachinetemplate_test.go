@@ -0,0 +1,55 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+)
+
+var _ = Describe("MetalsoftMachineTemplate", func() {
+	It("rejects changes to the template spec", func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "template-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+		template := &infrastructurev1beta1.MetalsoftMachineTemplate{
+			ObjectMeta: metav1.ObjectMeta{Name: "md-0", Namespace: namespace.Name},
+			Spec: infrastructurev1beta1.MetalsoftMachineTemplateSpec{
+				Template: infrastructurev1beta1.MetalsoftMachineTemplateResource{
+					Spec: infrastructurev1beta1.MetalsoftMachineSpec{
+						ServerType: "M.16.64.v2",
+						OSTemplate: "ubuntu-22-04",
+					},
+				},
+			},
+		}
+		Expect(k8sClient.Create(ctx, template)).To(Succeed())
+
+		template.Spec.Template.Spec.ServerType = "M.40.256.v3"
+		Expect(k8sClient.Update(ctx, template)).To(MatchError(ContainSubstring("spec is immutable")))
+
+		Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(template), template)).To(Succeed())
+		template.Labels = map[string]string{"tier": "workers"}
+		Expect(k8sClient.Update(ctx, template)).To(Succeed())
+	})
+})
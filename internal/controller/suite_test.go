@@ -17,20 +17,37 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/api/v1beta1/index"
+	bootstrapv1 "sigs.k8s.io/cluster-api/bootstrap/kubeadm/api/v1beta1"
+	capicontrollers "sigs.k8s.io/cluster-api/controllers"
+	ipamv1 "sigs.k8s.io/cluster-api/exp/ipam/api/v1alpha1"
+	runtimev1 "sigs.k8s.io/cluster-api/exp/runtime/api/v1alpha1"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 
-	infrastructurev1alpha1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1alpha1"
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft/fake"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -40,6 +57,14 @@ import (
 var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
+var metalsoftServer *fake.Server
+var ctx context.Context
+var cancel context.CancelFunc
+
+const (
+	timeout  = 30 * time.Second
+	interval = 250 * time.Millisecond
+)
 
 func TestControllers(t *testing.T) {
 	RegisterFailHandler(Fail)
@@ -50,9 +75,17 @@ func TestControllers(t *testing.T) {
 var _ = BeforeSuite(func() {
 	logf.SetLogger(zap.New(zap.WriteTo(GinkgoWriter), zap.UseDevMode(true)))
 
+	ctx, cancel = context.WithCancel(context.TODO())
+
 	By("bootstrapping test environment")
+	capiDir := capiModuleDir()
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		CRDDirectoryPaths: []string{
+			filepath.Join("..", "..", "config", "crd", "bases"),
+			filepath.Join(capiDir, "config", "crd", "bases"),
+			filepath.Join(capiDir, "bootstrap", "kubeadm", "config", "crd", "bases"),
+			filepath.Join(capiDir, "controlplane", "kubeadm", "config", "crd", "bases"),
+		},
 		ErrorIfCRDPathMissing: true,
 	}
 
@@ -62,7 +95,18 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(cfg).NotTo(BeNil())
 
-	err = infrastructurev1alpha1.AddToScheme(scheme.Scheme)
+	err = infrastructurev1beta1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = clusterv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = bootstrapv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = apiextensionsv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	err = ipamv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+	// The ClusterClass controller watches ExtensionConfigs.
+	err = runtimev1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
 	//+kubebuilder:scaffold:scheme
@@ -71,10 +115,121 @@ var _ = BeforeSuite(func() {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(k8sClient).NotTo(BeNil())
 
+	By("labelling CRDs with their Cluster API contract version")
+	for _, crd := range testEnv.CRDs {
+		Expect(setContractLabel(crd)).To(Succeed())
+	}
+
+	By("starting the fake MetalSoft API")
+	metalsoftServer = fake.NewServer()
+
+	controllerNamespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "capms-system"}}
+	Expect(k8sClient.Create(ctx, controllerNamespace)).To(Succeed())
+	defaultCredentials := newCredentialsSecret(controllerNamespace.Name, "capms-manager-credentials", metalsoftServer.Credentials())
+
+	k8sManager, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme:             scheme.Scheme,
+		MetricsBindAddress: "0",
+	})
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&MetalsoftClusterReconciler{
+		Client:                   k8sManager.GetClient(),
+		Scheme:                   k8sManager.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: &client.ObjectKey{Namespace: defaultCredentials.Namespace, Name: defaultCredentials.Name},
+		ControllerNamespace:      controllerNamespace.Name,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&MetalsoftMachineReconciler{
+		Client:                   k8sManager.GetClient(),
+		Scheme:                   k8sManager.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: &client.ObjectKey{Namespace: defaultCredentials.Namespace, Name: defaultCredentials.Name},
+		ControllerNamespace:      controllerNamespace.Name,
+		// Reconcile machines in parallel like the manager does, so that
+		// machines race for the same servers.
+		MaxConcurrentReconciles: 4,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	err = (&MetalsoftHostReconciler{
+		Client:                   k8sManager.GetClient(),
+		Scheme:                   k8sManager.GetScheme(),
+		MetalsoftClientFactory:   metalsoft.NewClient,
+		DefaultCredentialsSecret: &client.ObjectKey{Namespace: defaultCredentials.Namespace, Name: defaultCredentials.Name},
+		ControllerNamespace:      controllerNamespace.Name,
+		ResyncPeriod:             time.Second,
+	}).SetupWithManager(k8sManager)
+	Expect(err).NotTo(HaveOccurred())
+
+	By("running the Cluster API topology controllers")
+	Expect(index.ByClusterClassName(ctx, k8sManager)).To(Succeed())
+	err = (&capicontrollers.ClusterClassReconciler{
+		Client:                    k8sManager.GetClient(),
+		APIReader:                 k8sManager.GetAPIReader(),
+		UnstructuredCachingClient: k8sManager.GetClient(),
+	}).SetupWithManager(ctx, k8sManager, controller.Options{})
+	Expect(err).NotTo(HaveOccurred())
+	err = (&capicontrollers.ClusterTopologyReconciler{
+		Client:                    k8sManager.GetClient(),
+		APIReader:                 k8sManager.GetAPIReader(),
+		UnstructuredCachingClient: k8sManager.GetClient(),
+	}).SetupWithManager(ctx, k8sManager, controller.Options{})
+	Expect(err).NotTo(HaveOccurred())
+
+	go func() {
+		defer GinkgoRecover()
+		err = k8sManager.Start(ctx)
+		Expect(err).NotTo(HaveOccurred(), "failed to run manager")
+	}()
 })
 
 var _ = AfterSuite(func() {
+	cancel()
 	By("tearing down the test environment")
+	if metalsoftServer != nil {
+		metalsoftServer.Close()
+	}
 	err := testEnv.Stop()
 	Expect(err).NotTo(HaveOccurred())
 })
+
+// capiModuleDir returns the directory of the cluster-api module version
+// this provider is built against, which also ships the Cluster API CRDs.
+func capiModuleDir() string {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", "sigs.k8s.io/cluster-api").Output()
+	Expect(err).NotTo(HaveOccurred())
+	return strings.TrimSpace(string(out))
+}
+
+// setContractLabel labels crd with the API versions implementing the Cluster
+// API contract, like the contract label patches of config/crd do. The
+// topology controllers refuse templates whose CRD lacks the label.
+func setContractLabel(crd *apiextensionsv1.CustomResourceDefinition) error {
+	versions := make([]string, 0, len(crd.Spec.Versions))
+	for _, version := range crd.Spec.Versions {
+		versions = append(versions, version.Name)
+	}
+	patch := client.MergeFrom(crd.DeepCopy())
+	if crd.Labels == nil {
+		crd.Labels = map[string]string{}
+	}
+	crd.Labels[clusterv1.GroupVersion.String()] = strings.Join(versions, "_")
+	return k8sClient.Patch(ctx, crd, patch)
+}
+
+// newCredentialsSecret creates a Secret holding MetalSoft credentials.
+func newCredentialsSecret(namespace, name string, creds metalsoft.Credentials) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		StringData: map[string]string{
+			CredentialsEndpointKey:  creds.Endpoint,
+			CredentialsUserEmailKey: creds.UserEmail,
+			CredentialsAPIKeyKey:    creds.APIKey,
+		},
+	}
+	Expect(k8sClient.Create(ctx, secret)).To(Succeed())
+	return secret
+}
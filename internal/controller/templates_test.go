@@ -0,0 +1,164 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1beta1"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client/yamlprocessor"
+	utilyaml "sigs.k8s.io/cluster-api/util/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrastructurev1beta1 "github.com/metalsoft-io/cluster-api-provider-metalsoft/api/v1beta1"
+)
+
+// renderTemplate renders the clusterctl template file of the templates
+// directory like clusterctl generate cluster does.
+func renderTemplate(file string, variables map[string]string) ([]unstructured.Unstructured, error) {
+	raw, err := os.ReadFile(filepath.Join("..", "..", "templates", file))
+	if err != nil {
+		return nil, err
+	}
+	rendered, err := yamlprocessor.NewSimpleProcessor().Process(raw, func(name string) (string, error) {
+		value, ok := variables[name]
+		if !ok {
+			return "", fmt.Errorf("variable %s is not set", name)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return utilyaml.ToUnstructured(rendered)
+}
+
+// defaultClusterClassNamespaces sets the namespace of the ClusterClass
+// template references like the Cluster API defaulting webhook does, as the
+// webhook is not served by the test environment.
+func defaultClusterClassNamespaces(obj *unstructured.Unstructured) error {
+	clusterClass := &clusterv1.ClusterClass{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, clusterClass); err != nil {
+		return err
+	}
+	refs := []*corev1.ObjectReference{clusterClass.Spec.Infrastructure.Ref, clusterClass.Spec.ControlPlane.Ref}
+	if clusterClass.Spec.ControlPlane.MachineInfrastructure != nil {
+		refs = append(refs, clusterClass.Spec.ControlPlane.MachineInfrastructure.Ref)
+	}
+	for _, md := range clusterClass.Spec.Workers.MachineDeployments {
+		refs = append(refs, md.Template.Bootstrap.Ref, md.Template.Infrastructure.Ref)
+	}
+	for _, ref := range refs {
+		if ref != nil && ref.Namespace == "" {
+			ref.Namespace = clusterClass.Namespace
+		}
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(clusterClass)
+	if err != nil {
+		return err
+	}
+	obj.SetUnstructuredContent(content)
+	return nil
+}
+
+var _ = Describe("Cluster templates", func() {
+	var variables map[string]string
+
+	BeforeEach(func() {
+		namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "flavor-"}}
+		Expect(k8sClient.Create(ctx, namespace)).To(Succeed())
+
+		variables = map[string]string{
+			"CLUSTER_NAME":                        "flavor",
+			"NAMESPACE":                           namespace.Name,
+			"KUBERNETES_VERSION":                  "v1.27.3",
+			"CONTROL_PLANE_MACHINE_COUNT":         "1",
+			"WORKER_MACHINE_COUNT":                "2",
+			"METALSOFT_DATACENTER":                "us-chi-qts01-dc",
+			"CONTROL_PLANE_ENDPOINT_HOST":         "192.0.2.10",
+			"METALSOFT_CONTROL_PLANE_SERVER_TYPE": "M.16.64.v2",
+			"METALSOFT_WORKER_SERVER_TYPE":        "M.40.256.v3",
+			"METALSOFT_OS_TEMPLATE":               "ubuntu-22-04",
+		}
+	})
+
+	apply := func(files ...string) {
+		for _, file := range files {
+			objs, err := renderTemplate(file, variables)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objs).NotTo(BeEmpty())
+			for i := range objs {
+				if objs[i].GetKind() == "ClusterClass" {
+					Expect(defaultClusterClassNamespaces(&objs[i])).To(Succeed())
+				}
+				Expect(k8sClient.Create(ctx, &objs[i])).To(Succeed(), "%s: %s %s", file, objs[i].GetKind(), objs[i].GetName())
+			}
+		}
+	}
+
+	getMetalsoftCluster := func(cluster *clusterv1.Cluster) *infrastructurev1beta1.MetalsoftCluster {
+		metalsoftCluster := &infrastructurev1beta1.MetalsoftCluster{}
+		key := client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+		Expect(k8sClient.Get(ctx, key, metalsoftCluster)).To(Succeed())
+		return metalsoftCluster
+	}
+
+	DescribeTable("render and apply against the CRDs",
+		func(file string, loadBalanced bool) {
+			apply(file)
+
+			cluster := &clusterv1.Cluster{}
+			key := client.ObjectKey{Namespace: variables["NAMESPACE"], Name: variables["CLUSTER_NAME"]}
+			Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			metalsoftCluster := getMetalsoftCluster(cluster)
+			Expect(metalsoftCluster.Spec.Datacenter).To(Equal(variables["METALSOFT_DATACENTER"]))
+			if loadBalanced {
+				Expect(metalsoftCluster.Spec.ControlPlaneLoadBalancer).NotTo(BeNil())
+			} else {
+				Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(variables["CONTROL_PLANE_ENDPOINT_HOST"]))
+			}
+		},
+		Entry("default", "cluster-template.yaml", false),
+		Entry("single-node", "cluster-template-single-node.yaml", false),
+		Entry("ha", "cluster-template-ha.yaml", true),
+	)
+
+	It("renders and applies the clusterclass flavor", func() {
+		apply("clusterclass-metalsoft.yaml", "cluster-template-clusterclass.yaml")
+
+		cluster := &clusterv1.Cluster{}
+		key := client.ObjectKey{Namespace: variables["NAMESPACE"], Name: variables["CLUSTER_NAME"]}
+		Eventually(func(g Gomega) {
+			g.Expect(k8sClient.Get(ctx, key, cluster)).To(Succeed())
+			g.Expect(cluster.Spec.InfrastructureRef).NotTo(BeNil())
+		}, timeout, interval).Should(Succeed())
+
+		metalsoftCluster := getMetalsoftCluster(cluster)
+		Expect(metalsoftCluster.Spec.Datacenter).To(Equal(variables["METALSOFT_DATACENTER"]))
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(variables["CONTROL_PLANE_ENDPOINT_HOST"]))
+		Expect(metalsoftCluster.Spec.ControlPlaneEndpoint.Port).To(BeEquivalentTo(6443))
+	})
+})
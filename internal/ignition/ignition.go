@@ -0,0 +1,121 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package ignition edits the Ignition bootstrap data generated by bootstrap
+// providers for operating systems like Flatcar Container Linux, which are
+// configured by Ignition instead of cloud-init.
+package ignition
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrNotIgnition is returned when editing user data that is not an Ignition config.
+var ErrNotIgnition = errors.New("bootstrap data is not an Ignition config")
+
+// File is a file written by Ignition to the root file system.
+type File struct {
+	Path string
+	// Mode is the permissions of the file, e.g. 0644.
+	Mode    int
+	Content string
+}
+
+// Version returns the specification version of an Ignition config, or an
+// empty string if data is not an Ignition config.
+func Version(data []byte) string {
+	var config struct {
+		Ignition struct {
+			Version string `json:"version"`
+		} `json:"ignition"`
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return ""
+	}
+	return config.Ignition.Version
+}
+
+// IsIgnition returns true if data is an Ignition config.
+func IsIgnition(data []byte) bool {
+	return Version(data) != ""
+}
+
+// AddFiles returns data with files appended to its storage.files, replacing
+// any file already written to the same path. Both the 2.x specification
+// emitted by the kubeadm bootstrap provider and the 3.x specification are
+// supported.
+func AddFiles(data []byte, files ...File) ([]byte, error) {
+	version := Version(data)
+	if version == "" {
+		return nil, ErrNotIgnition
+	}
+	if !strings.HasPrefix(version, "2.") && !strings.HasPrefix(version, "3.") {
+		return nil, fmt.Errorf("unsupported Ignition config version %q", version)
+	}
+	config := map[string]interface{}{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse Ignition config: %w", err)
+	}
+
+	storage, _ := config["storage"].(map[string]interface{})
+	if storage == nil {
+		storage = map[string]interface{}{}
+	}
+	existing, _ := storage["files"].([]interface{})
+	var entries []interface{}
+	for _, entry := range existing {
+		if path, _ := entry.(map[string]interface{})["path"].(string); replaced(path, files) {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	for _, file := range files {
+		entry := map[string]interface{}{
+			"path": file.Path,
+			"mode": file.Mode,
+			"contents": map[string]interface{}{
+				"source": "data:;base64," + base64.StdEncoding.EncodeToString([]byte(file.Content)),
+			},
+		}
+		if strings.HasPrefix(version, "2.") {
+			entry["filesystem"] = "root"
+		} else {
+			entry["overwrite"] = true
+		}
+		entries = append(entries, entry)
+	}
+	storage["files"] = entries
+	config["storage"] = storage
+
+	out, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Ignition config: %w", err)
+	}
+	return out, nil
+}
+
+func replaced(path string, files []File) bool {
+	for _, file := range files {
+		if file.Path == path {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package ignition
+
+import (
+	"encoding/json"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const kubeadmIgnition = `{
+  "ignition": {"version": "2.3.0"},
+  "storage": {
+    "files": [
+      {"filesystem": "root", "path": "/etc/kubeadm.yml", "mode": 384, "contents": {"source": "data:,kind%3A%20JoinConfiguration"}},
+      {"filesystem": "root", "path": "/etc/kubernetes/manifests/kube-vip.yaml", "contents": {"source": "data:,stale"}}
+    ]
+  },
+  "systemd": {"units": [{"name": "kubeadm.service", "enabled": true}]}
+}`
+
+func TestIsIgnition(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsIgnition([]byte(kubeadmIgnition))).To(BeTrue())
+	g.Expect(IsIgnition([]byte(`{"ignition":{"version":"3.3.0"}}`))).To(BeTrue())
+	g.Expect(IsIgnition([]byte("#cloud-config\nruncmd:\n- kubeadm join\n"))).To(BeFalse())
+	g.Expect(IsIgnition([]byte(`{"storage":{}}`))).To(BeFalse())
+}
+
+func TestAddFilesReplacesPath(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := AddFiles([]byte(kubeadmIgnition), File{
+		Path:    "/etc/kubernetes/manifests/kube-vip.yaml",
+		Mode:    0644,
+		Content: "fresh",
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(Version(data)).To(Equal("2.3.0"))
+
+	config := map[string]interface{}{}
+	g.Expect(json.Unmarshal(data, &config)).To(Succeed())
+	files := config["storage"].(map[string]interface{})["files"].([]interface{})
+	g.Expect(files).To(HaveLen(2))
+	g.Expect(files[0]).To(HaveKeyWithValue("path", "/etc/kubeadm.yml"))
+	g.Expect(files[1]).To(Equal(map[string]interface{}{
+		"filesystem": "root",
+		"path":       "/etc/kubernetes/manifests/kube-vip.yaml",
+		"mode":       float64(0644),
+		"contents":   map[string]interface{}{"source": "data:;base64,ZnJlc2g="},
+	}))
+	g.Expect(config["systemd"]).NotTo(BeNil())
+}
+
+func TestAddFilesVersion3(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := AddFiles([]byte(`{"ignition":{"version":"3.3.0"}}`), File{Path: "/etc/motd", Mode: 0644, Content: "hello"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	config := map[string]interface{}{}
+	g.Expect(json.Unmarshal(data, &config)).To(Succeed())
+	files := config["storage"].(map[string]interface{})["files"].([]interface{})
+	g.Expect(files).To(ConsistOf(map[string]interface{}{
+		"path":      "/etc/motd",
+		"mode":      float64(0644),
+		"overwrite": true,
+		"contents":  map[string]interface{}{"source": "data:;base64,aGVsbG8="},
+	}))
+}
+
+func TestAddFilesRejectsOtherFormats(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := AddFiles([]byte("#cloud-config\nruncmd:\n- kubeadm join\n"), File{Path: "/etc/motd"})
+	g.Expect(err).To(MatchError(ErrNotIgnition))
+	_, err = AddFiles([]byte(`{"ignition":{"version":"1.0.0"}}`), File{Path: "/etc/motd"})
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,145 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package kubevip renders the kube-vip static pod announcing the control
+// plane endpoint of clusters without a MetalSoft load balancer.
+package kubevip
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// DefaultImage is the kube-vip image used when none is configured.
+const DefaultImage = "ghcr.io/kube-vip/kube-vip:v0.6.2"
+
+// ManifestPath is where the kube-vip static pod manifest is written on
+// control plane servers, so the kubelet starts it before the API server.
+const ManifestPath = "/etc/kubernetes/manifests/kube-vip.yaml"
+
+// AdminKubeconfig is the kubeconfig kube-vip uses for leader election by
+// default. From Kubernetes 1.29 on, kubeadm init only binds its user to a
+// role once it reaches the API server through the virtual IP, so it cannot
+// be used on the server initializing the cluster.
+const AdminKubeconfig = "/etc/kubernetes/admin.conf"
+
+// SuperAdminKubeconfig is the kubeconfig kube-vip uses on the server
+// initializing the cluster from Kubernetes 1.29 on. kubeadm init only writes
+// it on that server.
+const SuperAdminKubeconfig = "/etc/kubernetes/super-admin.conf"
+
+// Config is the configuration of a kube-vip static pod.
+type Config struct {
+	// Address is the virtual IP announced by kube-vip.
+	Address string
+	// Port is the port of the API server behind the virtual IP.
+	Port int32
+	// PrefixLength is the prefix length of the subnet of the virtual IP, with
+	// which it is added to the interface. It defaults to 32.
+	PrefixLength int
+	// Interface is the network interface the virtual IP is announced on.
+	// When empty, kube-vip uses the interface of the default route.
+	Interface string
+	// Image is the kube-vip image. It defaults to DefaultImage.
+	Image string
+	// Kubeconfig is the path of the kubeconfig on the server that kube-vip
+	// uses for leader election. It defaults to AdminKubeconfig.
+	Kubeconfig string
+}
+
+// Manifest returns the static pod manifest running kube-vip in ARP mode with
+// leader election among the control plane servers.
+func Manifest(config Config) ([]byte, error) {
+	if config.Address == "" {
+		return nil, fmt.Errorf("kube-vip address is not set")
+	}
+	image := config.Image
+	if image == "" {
+		image = DefaultImage
+	}
+	prefixLength := config.PrefixLength
+	if prefixLength == 0 {
+		prefixLength = 32
+	}
+	kubeconfig := config.Kubeconfig
+	if kubeconfig == "" {
+		kubeconfig = AdminKubeconfig
+	}
+
+	env := []corev1.EnvVar{
+		{Name: "vip_arp", Value: "true"},
+		{Name: "port", Value: strconv.Itoa(int(config.Port))},
+		{Name: "vip_cidr", Value: strconv.Itoa(prefixLength)},
+		{Name: "cp_enable", Value: "true"},
+		{Name: "cp_namespace", Value: metav1.NamespaceSystem},
+		{Name: "vip_leaderelection", Value: "true"},
+		{Name: "vip_leasename", Value: "plndr-cp-lock"},
+		{Name: "vip_leaseduration", Value: "15"},
+		{Name: "vip_renewdeadline", Value: "10"},
+		{Name: "vip_retryperiod", Value: "2"},
+		{Name: "address", Value: config.Address},
+	}
+	if config.Interface != "" {
+		env = append(env, corev1.EnvVar{Name: "vip_interface", Value: config.Interface})
+	}
+
+	hostPathFile := corev1.HostPathFile
+	pod := &corev1.Pod{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kube-vip",
+			Namespace: metav1.NamespaceSystem,
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:            "kube-vip",
+				Image:           image,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Args:            []string{"manager"},
+				Env:             env,
+				SecurityContext: &corev1.SecurityContext{
+					Capabilities: &corev1.Capabilities{
+						Add: []corev1.Capability{"NET_ADMIN", "NET_RAW"},
+					},
+				},
+				// kube-vip reads its kubeconfig from the admin.conf path,
+				// whichever kubeconfig of the server is mounted there.
+				VolumeMounts: []corev1.VolumeMount{{
+					Name:      "kubeconfig",
+					MountPath: AdminKubeconfig,
+				}},
+			}},
+			// The API server certificate is valid for kubernetes, which
+			// resolves to the local API server until the VIP is up.
+			HostAliases: []corev1.HostAlias{{
+				IP:        "127.0.0.1",
+				Hostnames: []string{"kubernetes"},
+			}},
+			HostNetwork: true,
+			Volumes: []corev1.Volume{{
+				Name: "kubeconfig",
+				VolumeSource: corev1.VolumeSource{
+					HostPath: &corev1.HostPathVolumeSource{Path: kubeconfig, Type: &hostPathFile},
+				},
+			}},
+		},
+	}
+	return yaml.Marshal(pod)
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package kubevip
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/yaml"
+)
+
+func TestManifest(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := Manifest(Config{Address: "192.0.2.10", Port: 6443, PrefixLength: 26, Interface: "bond0"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pod := &corev1.Pod{}
+	g.Expect(yaml.UnmarshalStrict(data, pod)).To(Succeed())
+	g.Expect(pod.Kind).To(Equal("Pod"))
+	g.Expect(pod.Namespace).To(Equal("kube-system"))
+	g.Expect(pod.Spec.HostNetwork).To(BeTrue())
+	g.Expect(pod.Spec.Containers).To(HaveLen(1))
+	container := pod.Spec.Containers[0]
+	g.Expect(container.Image).To(Equal(DefaultImage))
+	g.Expect(container.Env).To(ContainElements(
+		corev1.EnvVar{Name: "address", Value: "192.0.2.10"},
+		corev1.EnvVar{Name: "port", Value: "6443"},
+		corev1.EnvVar{Name: "vip_cidr", Value: "26"},
+		corev1.EnvVar{Name: "vip_interface", Value: "bond0"},
+	))
+}
+
+func TestManifestWithoutInterface(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := Manifest(Config{Address: "192.0.2.10", Port: 6443, Image: "registry.example.com/kube-vip:v0.6.2"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	pod := &corev1.Pod{}
+	g.Expect(yaml.Unmarshal(data, pod)).To(Succeed())
+	g.Expect(pod.Spec.Containers[0].Image).To(Equal("registry.example.com/kube-vip:v0.6.2"))
+	g.Expect(pod.Spec.Containers[0].Env).NotTo(ContainElement(HaveField("Name", "vip_interface")))
+	g.Expect(pod.Spec.Containers[0].Env).To(ContainElement(corev1.EnvVar{Name: "vip_cidr", Value: "32"}))
+}
+
+func TestManifestRequiresAddress(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := Manifest(Config{Port: 6443})
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestManifestKubeconfig(t *testing.T) {
+	g := NewWithT(t)
+
+	data, err := Manifest(Config{Address: "192.0.2.10", Port: 6443})
+	g.Expect(err).NotTo(HaveOccurred())
+	pod := &corev1.Pod{}
+	g.Expect(yaml.Unmarshal(data, pod)).To(Succeed())
+	g.Expect(pod.Spec.Volumes).To(HaveLen(1))
+	g.Expect(pod.Spec.Volumes[0].HostPath.Path).To(Equal(AdminKubeconfig))
+
+	data, err = Manifest(Config{Address: "192.0.2.10", Port: 6443, Kubeconfig: SuperAdminKubeconfig})
+	g.Expect(err).NotTo(HaveOccurred())
+	pod = &corev1.Pod{}
+	g.Expect(yaml.Unmarshal(data, pod)).To(Succeed())
+	g.Expect(pod.Spec.Volumes[0].HostPath.Path).To(Equal(SuperAdminKubeconfig))
+	g.Expect(pod.Spec.Containers[0].VolumeMounts[0].MountPath).To(Equal(AdminKubeconfig))
+}
@@ -0,0 +1,207 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metalsoft provides a client for the subset of the MetalSoft API
+// used by the cluster and machine reconcilers.
+package metalsoft
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Client is the set of MetalSoft operations needed to provision clusters.
+// Implementations must be safe for concurrent use.
+type Client interface {
+	// GetInfrastructure returns the infrastructure with the given ID.
+	GetInfrastructure(ctx context.Context, id int) (*Infrastructure, error)
+	// GetInfrastructureByLabel returns the infrastructure with the given label.
+	GetInfrastructureByLabel(ctx context.Context, label string) (*Infrastructure, error)
+	// CreateInfrastructure creates a new, undeployed infrastructure.
+	CreateInfrastructure(ctx context.Context, infrastructure Infrastructure) (*Infrastructure, error)
+	// DeployInfrastructure applies all pending changes of an infrastructure.
+	DeployInfrastructure(ctx context.Context, id int) error
+	// DeleteInfrastructure marks an infrastructure and everything in it for deletion.
+	DeleteInfrastructure(ctx context.Context, id int) error
+
+	// ListInstanceArrays returns the instance arrays of an infrastructure.
+	ListInstanceArrays(ctx context.Context, infrastructureID int) ([]InstanceArray, error)
+	// GetInstanceArray returns the instance array with the given ID.
+	GetInstanceArray(ctx context.Context, id int) (*InstanceArray, error)
+	// CreateInstanceArray adds an instance array to an infrastructure. An
+	// instance array with a server ID allocates that server of the inventory
+	// to its single instance; a reserved server requires the reservation key
+	// of its reservation.
+	CreateInstanceArray(ctx context.Context, infrastructureID int, instanceArray InstanceArray) (*InstanceArray, error)
+	// StopInstanceArray marks the instances of an active instance array to be powered off.
+	StopInstanceArray(ctx context.Context, id int) error
+	// DeleteInstanceArray marks an instance array for deletion.
+	DeleteInstanceArray(ctx context.Context, id int) error
+	// ListInstances returns the instances of an instance array.
+	ListInstances(ctx context.Context, instanceArrayID int) ([]Instance, error)
+
+	// ListDriveArrays returns the drive arrays of an infrastructure.
+	ListDriveArrays(ctx context.Context, infrastructureID int) ([]DriveArray, error)
+	// CreateDriveArray adds a drive array to an infrastructure.
+	CreateDriveArray(ctx context.Context, infrastructureID int, driveArray DriveArray) (*DriveArray, error)
+	// DeleteDriveArray marks a drive array for deletion.
+	DeleteDriveArray(ctx context.Context, id int) error
+
+	// ListNetworks returns the networks of an infrastructure.
+	ListNetworks(ctx context.Context, infrastructureID int) ([]Network, error)
+	// CreateNetwork adds a network to an infrastructure.
+	CreateNetwork(ctx context.Context, infrastructureID int, network Network) (*Network, error)
+	// DeleteNetwork marks a network for deletion.
+	DeleteNetwork(ctx context.Context, id int) error
+	// ListSubnets returns the subnets of a network.
+	ListSubnets(ctx context.Context, networkID int) ([]Subnet, error)
+
+	// ListLoadBalancers returns the load balancers of an infrastructure.
+	ListLoadBalancers(ctx context.Context, infrastructureID int) ([]LoadBalancer, error)
+	// GetLoadBalancer returns the load balancer with the given ID.
+	GetLoadBalancer(ctx context.Context, id int) (*LoadBalancer, error)
+	// CreateLoadBalancer adds a load balancer to an infrastructure. Its
+	// address is allocated right away, but it only forwards traffic once the
+	// infrastructure is deployed.
+	CreateLoadBalancer(ctx context.Context, infrastructureID int, loadBalancer LoadBalancer) (*LoadBalancer, error)
+	// DeleteLoadBalancer marks a load balancer for deletion.
+	DeleteLoadBalancer(ctx context.Context, id int) error
+	// AddLoadBalancerBackend starts forwarding the traffic of a load balancer
+	// to an instance. It takes effect without a deploy.
+	AddLoadBalancerBackend(ctx context.Context, id int, backend LoadBalancerBackend) error
+	// RemoveLoadBalancerBackend stops forwarding the traffic of a load
+	// balancer to an instance. It takes effect without a deploy.
+	RemoveLoadBalancerBackend(ctx context.Context, id int, instanceID int) error
+
+	// ListFloatingIPs returns the floating IPs of an infrastructure.
+	ListFloatingIPs(ctx context.Context, infrastructureID int) ([]FloatingIP, error)
+	// GetFloatingIP returns the floating IP with the given ID.
+	GetFloatingIP(ctx context.Context, id int) (*FloatingIP, error)
+	// CreateFloatingIP reserves a WAN address in an infrastructure. The
+	// address is allocated right away, but it is only routed once the
+	// infrastructure is deployed.
+	CreateFloatingIP(ctx context.Context, infrastructureID int, floatingIP FloatingIP) (*FloatingIP, error)
+	// DeleteFloatingIP marks a floating IP for deletion.
+	DeleteFloatingIP(ctx context.Context, id int) error
+	// AssignFloatingIP routes a floating IP to an instance, replacing any
+	// previous assignment. It takes effect without a deploy.
+	AssignFloatingIP(ctx context.Context, id int, instanceID int) error
+	// UnassignFloatingIP stops routing a floating IP. It takes effect without a deploy.
+	UnassignFloatingIP(ctx context.Context, id int) error
+
+	// ListReservedIPs returns the reserved IPs of an infrastructure.
+	ListReservedIPs(ctx context.Context, infrastructureID int) ([]ReservedIP, error)
+	// GetReservedIP returns the reserved IP with the given ID.
+	GetReservedIP(ctx context.Context, id int) (*ReservedIP, error)
+	// CreateReservedIP reserves an address of a subnet. The address is
+	// allocated right away, but it is only withheld from instances once the
+	// infrastructure is deployed.
+	CreateReservedIP(ctx context.Context, subnetID int, reservedIP ReservedIP) (*ReservedIP, error)
+	// DeleteReservedIP marks a reserved IP for deletion.
+	DeleteReservedIP(ctx context.Context, id int) error
+
+	// ListServerTypes returns the server types offered in a datacenter.
+	ListServerTypes(ctx context.Context, datacenter string) ([]ServerType, error)
+	// ListRacks returns the server racks of a datacenter.
+	ListRacks(ctx context.Context, datacenter string) ([]Rack, error)
+	// ListServers returns the servers of the inventory of a datacenter.
+	ListServers(ctx context.Context, datacenter string) ([]Server, error)
+	// SearchServers returns the servers of the inventory matching a search.
+	// With a disk type, only the disks of that type are counted, and at least
+	// one is required.
+	SearchServers(ctx context.Context, search ServerSearch) ([]Server, error)
+	// GetServer returns the server with the given ID.
+	GetServer(ctx context.Context, id int) (*Server, error)
+	// ReserveServer reserves an available server under a reservation key so
+	// that only instance arrays with that key can allocate it. Reserving a
+	// server again with the same key succeeds; a server reserved under
+	// another key or allocated to an instance is a conflict. The reservation
+	// ends when the server is allocated.
+	ReserveServer(ctx context.Context, id int, reservationKey string) error
+	// ReleaseServer ends the reservation of a server under a reservation
+	// key. Servers not reserved under that key are left untouched.
+	ReleaseServer(ctx context.Context, id int, reservationKey string) error
+	// GetOSTemplate returns the OS template with the given label.
+	GetOSTemplate(ctx context.Context, label string) (*OSTemplate, error)
+}
+
+// Credentials identify a MetalSoft user on a MetalSoft endpoint.
+type Credentials struct {
+	// Endpoint is the base URL of the MetalSoft API, e.g. https://api.metalsoft.io.
+	Endpoint string
+	// UserEmail is the email of the MetalSoft user owning the infrastructures.
+	UserEmail string
+	// APIKey is the API key of the MetalSoft user.
+	APIKey string
+}
+
+// Validate checks that all credential fields are set.
+func (c Credentials) Validate() error {
+	switch {
+	case c.Endpoint == "":
+		return errors.New("metalsoft endpoint is not set")
+	case c.UserEmail == "":
+		return errors.New("metalsoft user email is not set")
+	case c.APIKey == "":
+		return errors.New("metalsoft API key is not set")
+	}
+	return nil
+}
+
+// ClientFactory creates a Client for the given credentials. Reconcilers use a
+// factory rather than a Client so tests can inject a fake implementation.
+type ClientFactory func(creds Credentials) (Client, error)
+
+// NewClient returns a Client talking to the MetalSoft JSON-RPC API.
+func NewClient(creds Credentials) (Client, error) {
+	if err := creds.Validate(); err != nil {
+		return nil, err
+	}
+	return newRPCClient(creds), nil
+}
+
+// JSON-RPC error codes returned by MetalSoft.
+const (
+	// ErrorCodeNotFound is returned when the requested object does not exist.
+	ErrorCodeNotFound = 404
+	// ErrorCodeConflict is returned when the request conflicts with the current
+	// state of an object, e.g. deploying an infrastructure that is already
+	// being deployed.
+	ErrorCodeConflict = 409
+)
+
+// APIError is an error returned by the MetalSoft API.
+type APIError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("metalsoft API error %d: %s", e.Code, e.Message)
+}
+
+// IsNotFound returns true if err is a MetalSoft API error reporting a missing object.
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == ErrorCodeNotFound
+}
+
+// IsConflict returns true if err is a MetalSoft API error reporting a conflict.
+func IsConflict(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Code == ErrorCodeConflict
+}
@@ -0,0 +1,1671 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake provides an in-process MetalSoft API server speaking the same
+// JSON-RPC protocol as the real API, so reconcilers can be tested offline.
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+// APIKey is the API key accepted by servers created with NewServer.
+const APIKey = "fake-api-key"
+
+// UserEmail is the user email used by Credentials.
+const UserEmail = "capi@metalsoft.test"
+
+// Server is a stateful fake of the MetalSoft API backed by an httptest.Server.
+//
+// Changes to infrastructures, instance arrays, drive arrays, networks, load
+// balancers, floating IPs and reserved IPs are staged like on the real API and only take effect when the infrastructure is
+// deployed. A deploy finishes after the configured deploy duration has elapsed.
+type Server struct {
+	srv *httptest.Server
+
+	mu              sync.Mutex
+	nextID          int
+	nextIP          int
+	latency         time.Duration
+	methodLatencies map[string]time.Duration
+	deployDuration  time.Duration
+	failures        map[string][]*metalsoft.APIError
+	calls           map[string]int
+	infrastructures map[int]*infrastructure
+	instanceArrays  map[int]*metalsoft.InstanceArray
+	instances       map[int]*metalsoft.Instance
+	driveArrays     map[int]*metalsoft.DriveArray
+	networks        map[int]*metalsoft.Network
+	subnets         map[int]*metalsoft.Subnet
+	loadBalancers   map[int]*metalsoft.LoadBalancer
+	floatingIPs     map[int]*metalsoft.FloatingIP
+	reservedIPs     map[int]*metalsoft.ReservedIP
+	serverTypes     []metalsoft.ServerType
+	racks           []metalsoft.Rack
+	servers         map[int]*metalsoft.Server
+	osTemplates     map[string]*metalsoft.OSTemplate
+	deleted         map[int]bool
+	stopping        map[int]bool
+}
+
+type infrastructure struct {
+	metalsoft.Infrastructure
+	deployFinishesAt time.Time
+}
+
+// NewServer starts a fake MetalSoft API server seeded with a few server types
+// and OS templates. Callers must Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		nextID:          100,
+		methodLatencies: map[string]time.Duration{},
+		failures:        map[string][]*metalsoft.APIError{},
+		calls:           map[string]int{},
+		infrastructures: map[int]*infrastructure{},
+		instanceArrays:  map[int]*metalsoft.InstanceArray{},
+		instances:       map[int]*metalsoft.Instance{},
+		driveArrays:     map[int]*metalsoft.DriveArray{},
+		networks:        map[int]*metalsoft.Network{},
+		subnets:         map[int]*metalsoft.Subnet{},
+		loadBalancers:   map[int]*metalsoft.LoadBalancer{},
+		floatingIPs:     map[int]*metalsoft.FloatingIP{},
+		reservedIPs:     map[int]*metalsoft.ReservedIP{},
+		servers:         map[int]*metalsoft.Server{},
+		osTemplates:     map[string]*metalsoft.OSTemplate{},
+		deleted:         map[int]bool{},
+		stopping:        map[int]bool{},
+	}
+	s.AddServerType(metalsoft.ServerType{Name: "M.8.32.v2", ProcessorCoreCount: 8, RAMGB: 32, AvailableCount: 10})
+	s.AddServerType(metalsoft.ServerType{Name: "M.16.64.v2", ProcessorCoreCount: 16, RAMGB: 64, AvailableCount: 10})
+	s.AddServerType(metalsoft.ServerType{Name: "M.40.256.v3", ProcessorCoreCount: 40, RAMGB: 256, AvailableCount: 4})
+	s.AddOSTemplate(metalsoft.OSTemplate{Label: "ubuntu-22-04", DisplayName: "Ubuntu 22.04"})
+	s.AddOSTemplate(metalsoft.OSTemplate{Label: "ubuntu-20-04", DisplayName: "Ubuntu 20.04"})
+	s.AddOSTemplate(metalsoft.OSTemplate{
+		Label:            "flatcar-stable",
+		DisplayName:      "Flatcar Container Linux Stable",
+		BootstrapFormats: []string{metalsoft.BootstrapFormatIgnition},
+	})
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(metalsoft.RPCPath, s.handle)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// URL returns the base URL of the fake API.
+func (s *Server) URL() string {
+	return s.srv.URL
+}
+
+// Credentials returns credentials accepted by the fake API.
+func (s *Server) Credentials() metalsoft.Credentials {
+	return metalsoft.Credentials{Endpoint: s.URL(), UserEmail: UserEmail, APIKey: APIKey}
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// SetMethodLatency delays the responses to calls of method by d, on top of
+// the latency set with SetLatency.
+func (s *Server) SetMethodLatency(method string, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methodLatencies[method] = d
+}
+
+// SetDeployDuration sets how long an infrastructure deploy takes to finish.
+func (s *Server) SetDeployDuration(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deployDuration = d
+}
+
+// FailNext makes the next times calls of method fail with the given error code and message.
+func (s *Server) FailNext(method string, times int, code int, message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < times; i++ {
+		s.failures[method] = append(s.failures[method], &metalsoft.APIError{Code: code, Message: message})
+	}
+}
+
+// Calls returns how many times method has been called.
+func (s *Server) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// AddServerType registers a server type and returns its ID.
+func (s *Server) AddServerType(serverType metalsoft.ServerType) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	serverType.ID = s.newID()
+	s.serverTypes = append(s.serverTypes, serverType)
+	return serverType.ID
+}
+
+// AddRack registers a server rack. Servers are allocated from the racks of
+// the datacenter of their infrastructure, spreading over the racks allowed
+// by their instance array.
+func (s *Server) AddRack(rack metalsoft.Rack) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.racks = append(s.racks, rack)
+}
+
+// AddServer registers an available, powered off server in the inventory and
+// returns it with its ID. Servers are only allocated to instance arrays
+// requesting them by ID; other instance arrays get servers outside of the
+// inventory.
+func (s *Server) AddServer(server metalsoft.Server) metalsoft.Server {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	server.ID = s.newID()
+	server.Status = metalsoft.ServerStatusAvailable
+	server.PowerStatus = metalsoft.ServerPowerStatusOff
+	s.servers[server.ID] = &server
+	return server
+}
+
+// RemoveServer removes a server from the inventory, as when it is
+// decommissioned.
+func (s *Server) RemoveServer(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.servers, id)
+}
+
+// InventoryServer returns a copy of the server of the inventory with the
+// given ID.
+func (s *Server) InventoryServer(id int) (metalsoft.Server, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	server, ok := s.servers[id]
+	if !ok {
+		return metalsoft.Server{}, false
+	}
+	return *server, true
+}
+
+// AddOSTemplate registers an OS template and returns its ID.
+func (s *Server) AddOSTemplate(template metalsoft.OSTemplate) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	template.ID = s.newID()
+	s.osTemplates[template.Label] = &template
+	return template.ID
+}
+
+// ServerType returns a copy of the server type with the given name.
+func (s *Server) ServerType(name string) (metalsoft.ServerType, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, serverType := range s.serverTypes {
+		if serverType.Name == name {
+			return serverType, true
+		}
+	}
+	return metalsoft.ServerType{}, false
+}
+
+// Infrastructure returns a copy of the infrastructure with the given ID.
+func (s *Server) Infrastructure(id int) (metalsoft.Infrastructure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	infra, ok := s.infrastructures[id]
+	if !ok {
+		return metalsoft.Infrastructure{}, false
+	}
+	s.progress(infra)
+	if _, ok := s.infrastructures[id]; !ok {
+		return metalsoft.Infrastructure{}, false
+	}
+	return infra.Infrastructure, true
+}
+
+// InstanceArray returns a copy of the instance array with the given ID.
+func (s *Server) InstanceArray(id int) (metalsoft.InstanceArray, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ia, ok := s.instanceArrays[id]
+	if !ok {
+		return metalsoft.InstanceArray{}, false
+	}
+	return *ia, true
+}
+
+// InstanceArrays returns copies of the instance arrays of an infrastructure.
+func (s *Server) InstanceArrays(infrastructureID int) []metalsoft.InstanceArray {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []metalsoft.InstanceArray
+	for _, ia := range s.instanceArrays {
+		if ia.InfrastructureID == infrastructureID {
+			result = append(result, *ia)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// Networks returns copies of the networks of an infrastructure.
+func (s *Server) Networks(infrastructureID int) []metalsoft.Network {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result []metalsoft.Network
+	for _, n := range s.networks {
+		if n.InfrastructureID == infrastructureID {
+			result = append(result, *n)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// LoadBalancer returns a copy of the load balancer with the given ID.
+func (s *Server) LoadBalancer(id int) (metalsoft.LoadBalancer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lb, ok := s.loadBalancers[id]
+	if !ok {
+		return metalsoft.LoadBalancer{}, false
+	}
+	result := *lb
+	result.Backends = append([]metalsoft.LoadBalancerBackend(nil), lb.Backends...)
+	return result, true
+}
+
+// FloatingIP returns a copy of the floating IP with the given ID.
+func (s *Server) FloatingIP(id int) (metalsoft.FloatingIP, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fip, ok := s.floatingIPs[id]
+	if !ok {
+		return metalsoft.FloatingIP{}, false
+	}
+	return *fip, true
+}
+
+// ReservedIP returns a copy of the reserved IP with the given ID.
+func (s *Server) ReservedIP(id int) (metalsoft.ReservedIP, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ip, ok := s.reservedIPs[id]
+	if !ok {
+		return metalsoft.ReservedIP{}, false
+	}
+	return *ip, true
+}
+
+func (s *Server) newID() int {
+	s.nextID++
+	return s.nextID
+}
+
+func (s *Server) newIP() string {
+	s.nextIP++
+	return fmt.Sprintf("192.0.2.%d", s.nextIP%254+1)
+}
+
+type request struct {
+	JSONRPC string            `json:"jsonrpc"`
+	ID      uint64            `json:"id"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type response struct {
+	JSONRPC string              `json:"jsonrpc"`
+	ID      uint64              `json:"id"`
+	Result  interface{}         `json:"result,omitempty"`
+	Error   *metalsoft.APIError `json:"error,omitempty"`
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer "+APIKey {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	latency := s.latency + s.methodLatencies[req.Method]
+	s.mu.Unlock()
+	if latency > 0 {
+		time.Sleep(latency)
+	}
+
+	result, apiErr := s.dispatch(req.Method, req.Params)
+	resp := response{JSONRPC: "2.0", ID: req.ID, Result: result, Error: apiErr}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+func notFound(kind string, id interface{}) *metalsoft.APIError {
+	return &metalsoft.APIError{Code: metalsoft.ErrorCodeNotFound, Message: fmt.Sprintf("%s %v not found", kind, id)}
+}
+
+func invalidParams(err error) *metalsoft.APIError {
+	return &metalsoft.APIError{Code: -32602, Message: err.Error()}
+}
+
+func param(params []json.RawMessage, i int, v interface{}) *metalsoft.APIError {
+	if i >= len(params) {
+		return invalidParams(fmt.Errorf("missing parameter %d", i))
+	}
+	if err := json.Unmarshal(params[i], v); err != nil {
+		return invalidParams(err)
+	}
+	return nil
+}
+
+func (s *Server) dispatch(method string, params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[method]++
+	if failures := s.failures[method]; len(failures) > 0 {
+		s.failures[method] = failures[1:]
+		return nil, failures[0]
+	}
+
+	switch method {
+	case "infrastructure_get":
+		return s.infrastructureGet(params)
+	case "infrastructure_create":
+		return s.infrastructureCreate(params)
+	case "infrastructure_deploy":
+		return s.infrastructureDeploy(params)
+	case "infrastructure_delete":
+		return s.infrastructureDelete(params)
+	case "instance_arrays":
+		return s.instanceArraysList(params)
+	case "instance_array_get":
+		return s.instanceArrayGet(params)
+	case "instance_array_create":
+		return s.instanceArrayCreate(params)
+	case "instance_array_delete":
+		return s.instanceArrayDelete(params)
+	case "instance_array_stop":
+		return s.instanceArrayStop(params)
+	case "instance_array_instances":
+		return s.instanceArrayInstances(params)
+	case "drive_arrays":
+		return s.driveArraysList(params)
+	case "drive_array_create":
+		return s.driveArrayCreate(params)
+	case "drive_array_delete":
+		return s.driveArrayDelete(params)
+	case "networks":
+		return s.networksList(params)
+	case "network_create":
+		return s.networkCreate(params)
+	case "network_delete":
+		return s.networkDelete(params)
+	case "subnets":
+		return s.subnetsList(params)
+	case "load_balancers":
+		return s.loadBalancersList(params)
+	case "load_balancer_get":
+		return s.loadBalancerGet(params)
+	case "load_balancer_create":
+		return s.loadBalancerCreate(params)
+	case "load_balancer_delete":
+		return s.loadBalancerDelete(params)
+	case "load_balancer_backend_add":
+		return s.loadBalancerBackendAdd(params)
+	case "load_balancer_backend_remove":
+		return s.loadBalancerBackendRemove(params)
+	case "floating_ips":
+		return s.floatingIPsList(params)
+	case "floating_ip_get":
+		return s.floatingIPGet(params)
+	case "floating_ip_create":
+		return s.floatingIPCreate(params)
+	case "floating_ip_delete":
+		return s.floatingIPDelete(params)
+	case "floating_ip_assign":
+		return s.floatingIPAssign(params)
+	case "floating_ip_unassign":
+		return s.floatingIPUnassign(params)
+	case "reserved_ips":
+		return s.reservedIPsList(params)
+	case "reserved_ip_get":
+		return s.reservedIPGet(params)
+	case "reserved_ip_create":
+		return s.reservedIPCreate(params)
+	case "reserved_ip_delete":
+		return s.reservedIPDelete(params)
+	case "server_types":
+		return s.serverTypesList(params)
+	case "os_template_get":
+		return s.osTemplateGet(params)
+	case "servers":
+		return s.serversList(params)
+	case "server_search":
+		return s.serverSearch(params)
+	case "server_get":
+		return s.serverGet(params)
+	case "server_reserve":
+		return s.serverReserve(params)
+	case "server_release":
+		return s.serverRelease(params)
+	case "server_racks":
+		return s.racksList(params)
+	}
+	return nil, &metalsoft.APIError{Code: -32601, Message: fmt.Sprintf("method %q not found", method)}
+}
+
+// lookupInfrastructure resolves an infrastructure by ID or label.
+func (s *Server) lookupInfrastructure(raw json.RawMessage) (*infrastructure, *metalsoft.APIError) {
+	var id int
+	if err := json.Unmarshal(raw, &id); err == nil {
+		if infra, ok := s.infrastructures[id]; ok {
+			return infra, nil
+		}
+		return nil, notFound("infrastructure", id)
+	}
+	var label string
+	if err := json.Unmarshal(raw, &label); err != nil {
+		return nil, invalidParams(err)
+	}
+	for _, infra := range s.infrastructures {
+		if infra.Label == label {
+			return infra, nil
+		}
+	}
+	return nil, notFound("infrastructure", label)
+}
+
+func (s *Server) infrastructureGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	if len(params) < 1 {
+		return nil, invalidParams(fmt.Errorf("missing infrastructure ID or label"))
+	}
+	infra, apiErr := s.lookupInfrastructure(params[0])
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	s.progress(infra)
+	if _, ok := s.infrastructures[infra.ID]; !ok {
+		return nil, notFound("infrastructure", infra.ID)
+	}
+	return infra.Infrastructure, nil
+}
+
+func (s *Server) infrastructureCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var user string
+	var infra metalsoft.Infrastructure
+	if apiErr := param(params, 0, &user); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &infra); apiErr != nil {
+		return nil, apiErr
+	}
+	if infra.Label == "" || infra.Datacenter == "" {
+		return nil, invalidParams(fmt.Errorf("infrastructure label and datacenter are required"))
+	}
+	for _, existing := range s.infrastructures {
+		if existing.Label == infra.Label {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("infrastructure %q already exists", infra.Label)}
+		}
+	}
+	infra.ID = s.newID()
+	infra.ServiceStatus = metalsoft.ServiceStatusOrdered
+	infra.Operation = &metalsoft.InfrastructureOperation{DeployStatus: metalsoft.DeployStatusNotStarted}
+	s.infrastructures[infra.ID] = &infrastructure{Infrastructure: infra}
+
+	wan := &metalsoft.Network{
+		ID:               s.newID(),
+		InfrastructureID: infra.ID,
+		Label:            "wan",
+		Type:             metalsoft.NetworkTypeWAN,
+		ServiceStatus:    metalsoft.ServiceStatusOrdered,
+	}
+	s.networks[wan.ID] = wan
+	s.addSubnet(wan)
+	return infra, nil
+}
+
+func (s *Server) infrastructureDeploy(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	infra, ok := s.infrastructures[id]
+	if !ok {
+		return nil, notFound("infrastructure", id)
+	}
+	s.progress(infra)
+	if infra.Operation.DeployStatus == metalsoft.DeployStatusOngoing {
+		return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("infrastructure %d has a deploy in progress", id)}
+	}
+	infra.Operation = &metalsoft.InfrastructureOperation{DeployStatus: metalsoft.DeployStatusOngoing, DeployType: "edit"}
+	infra.deployFinishesAt = time.Now().Add(s.deployDuration)
+	s.progress(infra)
+	return true, nil
+}
+
+func (s *Server) infrastructureDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[id]; !ok {
+		return nil, notFound("infrastructure", id)
+	}
+	s.deleted[id] = true
+	for _, ia := range s.instanceArrays {
+		if ia.InfrastructureID == id {
+			s.deleted[ia.ID] = true
+		}
+	}
+	for _, da := range s.driveArrays {
+		if da.InfrastructureID == id {
+			s.deleted[da.ID] = true
+		}
+	}
+	for _, n := range s.networks {
+		if n.InfrastructureID == id {
+			s.deleted[n.ID] = true
+		}
+	}
+	for _, lb := range s.loadBalancers {
+		if lb.InfrastructureID == id {
+			s.deleted[lb.ID] = true
+		}
+	}
+	for _, fip := range s.floatingIPs {
+		if fip.InfrastructureID == id {
+			s.deleted[fip.ID] = true
+		}
+	}
+	for _, ip := range s.reservedIPs {
+		if ip.InfrastructureID == id {
+			s.deleted[ip.ID] = true
+		}
+	}
+	return true, nil
+}
+
+// progress finishes the deploy of infra if its deploy duration has elapsed,
+// applying all staged changes.
+func (s *Server) progress(infra *infrastructure) {
+	if infra.Operation == nil || infra.Operation.DeployStatus != metalsoft.DeployStatusOngoing {
+		return
+	}
+	if time.Now().Before(infra.deployFinishesAt) {
+		return
+	}
+	infra.Operation.DeployStatus = metalsoft.DeployStatusFinished
+
+	for id, ia := range s.instanceArrays {
+		if ia.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			for instanceID, instance := range s.instances {
+				if instance.InstanceArrayID == id {
+					s.releaseServer(instance)
+					s.detachInstance(instanceID)
+					delete(s.instances, instanceID)
+				}
+			}
+			delete(s.instanceArrays, id)
+			delete(s.stopping, id)
+			continue
+		}
+		if s.stopping[id] {
+			ia.ServiceStatus = metalsoft.ServiceStatusStopped
+			for _, instance := range s.instances {
+				if instance.InstanceArrayID == id && instance.ServiceStatus == metalsoft.ServiceStatusActive {
+					instance.ServiceStatus = metalsoft.ServiceStatusStopped
+				}
+			}
+			delete(s.stopping, id)
+			continue
+		}
+		if ia.ServiceStatus == metalsoft.ServiceStatusOrdered {
+			ia.ServiceStatus = metalsoft.ServiceStatusActive
+		}
+		for _, instance := range s.instances {
+			if instance.InstanceArrayID == id && instance.ServiceStatus == metalsoft.ServiceStatusOrdered {
+				s.activateInstance(ia, instance)
+			}
+		}
+	}
+	for id, da := range s.driveArrays {
+		if da.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			delete(s.driveArrays, id)
+			continue
+		}
+		da.ServiceStatus = metalsoft.ServiceStatusActive
+	}
+	for id, n := range s.networks {
+		if n.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			for subnetID, subnet := range s.subnets {
+				if subnet.NetworkID == id {
+					delete(s.subnets, subnetID)
+				}
+			}
+			delete(s.networks, id)
+			continue
+		}
+		n.ServiceStatus = metalsoft.ServiceStatusActive
+	}
+	for id, lb := range s.loadBalancers {
+		if lb.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			delete(s.loadBalancers, id)
+			continue
+		}
+		lb.ServiceStatus = metalsoft.ServiceStatusActive
+	}
+	for id, fip := range s.floatingIPs {
+		if fip.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			delete(s.floatingIPs, id)
+			continue
+		}
+		fip.ServiceStatus = metalsoft.ServiceStatusActive
+	}
+	for id, ip := range s.reservedIPs {
+		if ip.InfrastructureID != infra.ID {
+			continue
+		}
+		if s.deleted[id] {
+			delete(s.reservedIPs, id)
+			continue
+		}
+		ip.ServiceStatus = metalsoft.ServiceStatusActive
+	}
+
+	if s.deleted[infra.ID] {
+		delete(s.infrastructures, infra.ID)
+		return
+	}
+	infra.ServiceStatus = metalsoft.ServiceStatusActive
+}
+
+func (s *Server) activateInstance(ia *metalsoft.InstanceArray, instance *metalsoft.Instance) {
+	instance.ServiceStatus = metalsoft.ServiceStatusActive
+	instance.ServerID = s.newID()
+	instance.ServerTypeID = ia.ServerTypeID
+	if infra, ok := s.infrastructures[ia.InfrastructureID]; ok {
+		instance.RackLabel = s.pickRack(infra.Datacenter, ia.RackLabels)
+	}
+	if server, ok := s.servers[ia.ServerID]; ok {
+		server.Status = metalsoft.ServerStatusUsed
+		server.ReservationKey = ""
+		server.PowerStatus = metalsoft.ServerPowerStatusOn
+		server.InstanceID = instance.ID
+		instance.ServerID = server.ID
+		instance.RackLabel = server.RackLabel
+	}
+	if serverType := s.serverType(ia.ServerTypeID); serverType != nil {
+		serverType.AvailableCount--
+	}
+	instance.Hostname = fmt.Sprintf("instance-%d.metalsoft.test", instance.ID)
+	instance.Interfaces = nil
+	for _, iface := range ia.Interfaces {
+		instanceInterface := metalsoft.InstanceInterface{Index: iface.Index, NetworkID: iface.NetworkID}
+		switch {
+		case len(iface.StaticIPs) > 0:
+			for _, ip := range iface.StaticIPs {
+				instanceInterface.IPs = append(instanceInterface.IPs, metalsoft.IP{Address: ip.Address, Type: ipType(ip.Address)})
+			}
+		case iface.NetworkID != 0:
+			instanceInterface.IPs = []metalsoft.IP{{Address: s.newIP(), Type: "ipv4"}}
+		}
+		instance.Interfaces = append(instance.Interfaces, instanceInterface)
+	}
+}
+
+func (s *Server) instanceArraysList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	instanceArrays := []metalsoft.InstanceArray{}
+	for _, ia := range s.instanceArrays {
+		if ia.InfrastructureID == infrastructureID {
+			instanceArrays = append(instanceArrays, *ia)
+		}
+	}
+	sort.Slice(instanceArrays, func(i, j int) bool { return instanceArrays[i].ID < instanceArrays[j].ID })
+	return instanceArrays, nil
+}
+
+// ipType returns the MetalSoft type of an IP address.
+func ipType(address string) string {
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		return "ipv6"
+	}
+	return "ipv4"
+}
+
+// releaseServer returns the server allocated to instance to the pool.
+func (s *Server) releaseServer(instance *metalsoft.Instance) {
+	if instance.ServerID == 0 {
+		return
+	}
+	if serverType := s.serverType(instance.ServerTypeID); serverType != nil {
+		serverType.AvailableCount++
+	}
+	if server, ok := s.servers[instance.ServerID]; ok {
+		server.Status = metalsoft.ServerStatusAvailable
+		server.PowerStatus = metalsoft.ServerPowerStatusOff
+		server.InstanceID = 0
+	}
+	instance.ServerID = 0
+}
+
+func (s *Server) serverType(id int) *metalsoft.ServerType {
+	for i := range s.serverTypes {
+		if s.serverTypes[i].ID == id {
+			return &s.serverTypes[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) instanceArrayGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	ia, ok := s.instanceArrays[id]
+	if !ok {
+		return nil, notFound("instance array", id)
+	}
+	return ia, nil
+}
+
+// validateBootstrapData enforces the size limits of the instance array fields
+// carrying bootstrap data and checks that config drives are ISO 9660 images.
+func validateBootstrapData(ia *metalsoft.InstanceArray) *metalsoft.APIError {
+	if len(ia.CloudInitData) > metalsoft.MaxCloudInitDataBytes {
+		return invalidParams(fmt.Errorf("cloud-init user data of %d bytes exceeds %d bytes", len(ia.CloudInitData), metalsoft.MaxCloudInitDataBytes))
+	}
+	for name, value := range ia.CustomVariables {
+		if len(value) > metalsoft.MaxCustomVariableBytes {
+			return invalidParams(fmt.Errorf("custom variable %q of %d bytes exceeds %d bytes", name, len(value), metalsoft.MaxCustomVariableBytes))
+		}
+	}
+	if ia.ConfigDriveISO != nil {
+		if len(ia.ConfigDriveISO) > metalsoft.MaxConfigDriveBytes {
+			return invalidParams(fmt.Errorf("config drive of %d bytes exceeds %d bytes", len(ia.ConfigDriveISO), metalsoft.MaxConfigDriveBytes))
+		}
+		// The primary volume descriptor follows the 16 sectors of the system area.
+		const descriptor = 16 * 2048
+		if len(ia.ConfigDriveISO) < descriptor+6 || string(ia.ConfigDriveISO[descriptor+1:descriptor+6]) != "CD001" {
+			return invalidParams(fmt.Errorf("config drive is not an ISO 9660 image"))
+		}
+	}
+	return nil
+}
+
+func (s *Server) instanceArrayCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	var ia metalsoft.InstanceArray
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &ia); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok || s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	for _, existing := range s.instanceArrays {
+		if existing.InfrastructureID == infrastructureID && existing.Label == ia.Label && !s.deleted[existing.ID] {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("instance array %q already exists", ia.Label)}
+		}
+	}
+	if ia.ServerID != 0 {
+		server, ok := s.servers[ia.ServerID]
+		if !ok || server.Datacenter != s.infrastructures[infrastructureID].Datacenter {
+			return nil, notFound("server", ia.ServerID)
+		}
+		if ia.InstanceCount > 1 || (ia.ServerTypeID != 0 && ia.ServerTypeID != server.ServerTypeID) {
+			return nil, invalidParams(fmt.Errorf("server %d cannot be allocated to instance array %q", ia.ServerID, ia.Label))
+		}
+		reservedForIA := server.Status == metalsoft.ServerStatusReserved && server.ReservationKey == ia.ReservationKey
+		if server.Status != metalsoft.ServerStatusAvailable && !reservedForIA {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("server %d is not available", ia.ServerID)}
+		}
+		ia.ServerTypeID = server.ServerTypeID
+	}
+	if ia.ServerTypeID != 0 && s.serverType(ia.ServerTypeID) == nil {
+		return nil, notFound("server type", ia.ServerTypeID)
+	}
+	for _, label := range ia.RackLabels {
+		if !s.hasRack(s.infrastructures[infrastructureID].Datacenter, label) {
+			return nil, notFound("rack", label)
+		}
+	}
+	if server, ok := s.servers[ia.ServerID]; ok && len(ia.RackLabels) > 0 && !inRacks(ia.RackLabels, server.RackLabel) {
+		return nil, invalidParams(fmt.Errorf("server %d is not in racks %v", server.ID, ia.RackLabels))
+	}
+	if apiErr := validateBootstrapData(&ia); apiErr != nil {
+		return nil, apiErr
+	}
+	if ia.InstanceCount == 0 {
+		ia.InstanceCount = 1
+	}
+	for _, iface := range ia.Interfaces {
+		if n, ok := s.networks[iface.NetworkID]; iface.NetworkID != 0 && (!ok || n.InfrastructureID != infrastructureID || s.deleted[n.ID]) {
+			return nil, notFound("network", iface.NetworkID)
+		}
+		for _, ip := range iface.StaticIPs {
+			if iface.NetworkID == 0 || net.ParseIP(ip.Address) == nil || ip.Prefix <= 0 {
+				return nil, invalidParams(fmt.Errorf("invalid static IP %s/%d on interface %d", ip.Address, ip.Prefix, iface.Index))
+			}
+		}
+	}
+	if len(ia.Interfaces) == 0 {
+		for _, n := range s.networks {
+			if n.InfrastructureID == infrastructureID && n.Type == metalsoft.NetworkTypeWAN {
+				ia.Interfaces = []metalsoft.InstanceArrayInterface{{Index: 0, NetworkID: n.ID}}
+				break
+			}
+		}
+	}
+	ia.ID = s.newID()
+	ia.InfrastructureID = infrastructureID
+	ia.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.instanceArrays[ia.ID] = &ia
+	for i := 0; i < ia.InstanceCount; i++ {
+		instance := &metalsoft.Instance{
+			ID:              s.newID(),
+			Label:           fmt.Sprintf("%s-%d", ia.Label, i),
+			InstanceArrayID: ia.ID,
+			ServiceStatus:   metalsoft.ServiceStatusOrdered,
+		}
+		s.instances[instance.ID] = instance
+	}
+	return ia, nil
+}
+
+func (s *Server) instanceArrayDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.instanceArrays[id]; !ok {
+		return nil, notFound("instance array", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+func (s *Server) instanceArrayStop(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	ia, ok := s.instanceArrays[id]
+	if !ok {
+		return nil, notFound("instance array", id)
+	}
+	if ia.ServiceStatus != metalsoft.ServiceStatusActive {
+		return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("instance array %d is not active", id)}
+	}
+	s.stopping[id] = true
+	return true, nil
+}
+
+func (s *Server) instanceArrayInstances(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.instanceArrays[id]; !ok {
+		return nil, notFound("instance array", id)
+	}
+	instances := []metalsoft.Instance{}
+	for _, instance := range s.instances {
+		if instance.InstanceArrayID == id {
+			instances = append(instances, *instance)
+		}
+	}
+	sort.Slice(instances, func(i, j int) bool { return instances[i].ID < instances[j].ID })
+	return instances, nil
+}
+
+func (s *Server) driveArraysList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	driveArrays := []metalsoft.DriveArray{}
+	for _, da := range s.driveArrays {
+		if da.InfrastructureID == infrastructureID {
+			driveArrays = append(driveArrays, *da)
+		}
+	}
+	sort.Slice(driveArrays, func(i, j int) bool { return driveArrays[i].ID < driveArrays[j].ID })
+	return driveArrays, nil
+}
+
+func (s *Server) driveArrayCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	var da metalsoft.DriveArray
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &da); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok || s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	if da.InstanceArrayID != 0 {
+		if _, ok := s.instanceArrays[da.InstanceArrayID]; !ok {
+			return nil, notFound("instance array", da.InstanceArrayID)
+		}
+	}
+	da.ID = s.newID()
+	da.InfrastructureID = infrastructureID
+	da.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.driveArrays[da.ID] = &da
+	return da, nil
+}
+
+func (s *Server) driveArrayDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.driveArrays[id]; !ok {
+		return nil, notFound("drive array", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+func (s *Server) networksList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	networks := []metalsoft.Network{}
+	for _, n := range s.networks {
+		if n.InfrastructureID == infrastructureID {
+			networks = append(networks, *n)
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool { return networks[i].ID < networks[j].ID })
+	return networks, nil
+}
+
+func (s *Server) networkCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	var n metalsoft.Network
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &n); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok || s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	switch n.Type {
+	case metalsoft.NetworkTypeWAN, metalsoft.NetworkTypeLAN, metalsoft.NetworkTypeSAN:
+	default:
+		return nil, invalidParams(fmt.Errorf("unknown network type %q", n.Type))
+	}
+	for _, existing := range s.networks {
+		if existing.InfrastructureID != infrastructureID || s.deleted[existing.ID] {
+			continue
+		}
+		if n.Label != "" && existing.Label == n.Label {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("network %q already exists", n.Label)}
+		}
+		if n.VLANID != 0 && existing.VLANID == n.VLANID {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("VLAN %d is already in use", n.VLANID)}
+		}
+	}
+	n.ID = s.newID()
+	n.InfrastructureID = infrastructureID
+	n.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.networks[n.ID] = &n
+	s.addSubnet(&n)
+	return n, nil
+}
+
+func (s *Server) networkDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.networks[id]; !ok {
+		return nil, notFound("network", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+// addSubnet gives a network the subnet the addresses of its interfaces are
+// allocated from.
+func (s *Server) addSubnet(n *metalsoft.Network) {
+	prefixSize := n.SubnetPrefixSize
+	if prefixSize == 0 {
+		prefixSize = 24
+	}
+	subnet := &metalsoft.Subnet{
+		ID:         s.newID(),
+		NetworkID:  n.ID,
+		Type:       "ipv4",
+		Address:    "192.0.2.0",
+		PrefixSize: prefixSize,
+		Gateway:    "192.0.2.1",
+	}
+	s.subnets[subnet.ID] = subnet
+}
+
+func (s *Server) subnetsList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var networkID int
+	if apiErr := param(params, 0, &networkID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.networks[networkID]; !ok {
+		return nil, notFound("network", networkID)
+	}
+	subnets := []metalsoft.Subnet{}
+	for _, subnet := range s.subnets {
+		if subnet.NetworkID == networkID {
+			subnets = append(subnets, *subnet)
+		}
+	}
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].ID < subnets[j].ID })
+	return subnets, nil
+}
+
+// detachInstance removes a deleted instance from the load balancers and
+// floating IPs pointing at it.
+func (s *Server) detachInstance(instanceID int) {
+	for _, lb := range s.loadBalancers {
+		backends := lb.Backends[:0]
+		for _, backend := range lb.Backends {
+			if backend.InstanceID != instanceID {
+				backends = append(backends, backend)
+			}
+		}
+		lb.Backends = backends
+	}
+	for _, fip := range s.floatingIPs {
+		if fip.InstanceID == instanceID {
+			fip.InstanceID = 0
+		}
+	}
+}
+
+func (s *Server) loadBalancersList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	loadBalancers := []metalsoft.LoadBalancer{}
+	for _, lb := range s.loadBalancers {
+		if lb.InfrastructureID == infrastructureID {
+			loadBalancers = append(loadBalancers, *lb)
+		}
+	}
+	sort.Slice(loadBalancers, func(i, j int) bool { return loadBalancers[i].ID < loadBalancers[j].ID })
+	return loadBalancers, nil
+}
+
+func (s *Server) loadBalancerGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	lb, ok := s.loadBalancers[id]
+	if !ok {
+		return nil, notFound("load balancer", id)
+	}
+	return lb, nil
+}
+
+func (s *Server) loadBalancerCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	var lb metalsoft.LoadBalancer
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &lb); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok || s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	if lb.Port <= 0 || lb.Port > 65535 {
+		return nil, invalidParams(fmt.Errorf("invalid load balancer port %d", lb.Port))
+	}
+	for _, existing := range s.loadBalancers {
+		if existing.InfrastructureID == infrastructureID && existing.Label == lb.Label && !s.deleted[existing.ID] {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("load balancer %q already exists", lb.Label)}
+		}
+	}
+	lb.ID = s.newID()
+	lb.InfrastructureID = infrastructureID
+	lb.Address = s.newIP()
+	lb.Backends = nil
+	lb.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.loadBalancers[lb.ID] = &lb
+	return lb, nil
+}
+
+func (s *Server) loadBalancerDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.loadBalancers[id]; !ok {
+		return nil, notFound("load balancer", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+func (s *Server) loadBalancerBackendAdd(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	var backend metalsoft.LoadBalancerBackend
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &backend); apiErr != nil {
+		return nil, apiErr
+	}
+	lb, ok := s.loadBalancers[id]
+	if !ok {
+		return nil, notFound("load balancer", id)
+	}
+	instance, ok := s.instances[backend.InstanceID]
+	if !ok {
+		return nil, notFound("instance", backend.InstanceID)
+	}
+	if instance.ServiceStatus != metalsoft.ServiceStatusActive {
+		return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("instance %d is not active", instance.ID)}
+	}
+	for _, existing := range lb.Backends {
+		if existing.InstanceID == backend.InstanceID {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("instance %d is already a backend of load balancer %d", backend.InstanceID, id)}
+		}
+	}
+	if backend.Port == 0 {
+		backend.Port = lb.Port
+	}
+	for _, iface := range instance.Interfaces {
+		if len(iface.IPs) > 0 {
+			backend.Address = iface.IPs[0].Address
+			break
+		}
+	}
+	lb.Backends = append(lb.Backends, backend)
+	return true, nil
+}
+
+func (s *Server) loadBalancerBackendRemove(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id, instanceID int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &instanceID); apiErr != nil {
+		return nil, apiErr
+	}
+	lb, ok := s.loadBalancers[id]
+	if !ok {
+		return nil, notFound("load balancer", id)
+	}
+	for i, backend := range lb.Backends {
+		if backend.InstanceID == instanceID {
+			lb.Backends = append(lb.Backends[:i], lb.Backends[i+1:]...)
+			return true, nil
+		}
+	}
+	return nil, notFound("load balancer backend", instanceID)
+}
+
+func (s *Server) floatingIPsList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	floatingIPs := []metalsoft.FloatingIP{}
+	for _, fip := range s.floatingIPs {
+		if fip.InfrastructureID == infrastructureID {
+			floatingIPs = append(floatingIPs, *fip)
+		}
+	}
+	sort.Slice(floatingIPs, func(i, j int) bool { return floatingIPs[i].ID < floatingIPs[j].ID })
+	return floatingIPs, nil
+}
+
+func (s *Server) floatingIPGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	fip, ok := s.floatingIPs[id]
+	if !ok {
+		return nil, notFound("floating IP", id)
+	}
+	return fip, nil
+}
+
+func (s *Server) floatingIPCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	var fip metalsoft.FloatingIP
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &fip); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok || s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	for _, existing := range s.floatingIPs {
+		if existing.InfrastructureID == infrastructureID && existing.Label == fip.Label && !s.deleted[existing.ID] {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("floating IP %q already exists", fip.Label)}
+		}
+	}
+	fip.ID = s.newID()
+	fip.InfrastructureID = infrastructureID
+	fip.Address = s.newIP()
+	fip.InstanceID = 0
+	fip.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.floatingIPs[fip.ID] = &fip
+	return fip, nil
+}
+
+func (s *Server) floatingIPDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.floatingIPs[id]; !ok {
+		return nil, notFound("floating IP", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+func (s *Server) reservedIPsList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var infrastructureID int
+	if apiErr := param(params, 0, &infrastructureID); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.infrastructures[infrastructureID]; !ok {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	reservedIPs := []metalsoft.ReservedIP{}
+	for _, ip := range s.reservedIPs {
+		if ip.InfrastructureID == infrastructureID {
+			reservedIPs = append(reservedIPs, *ip)
+		}
+	}
+	sort.Slice(reservedIPs, func(i, j int) bool { return reservedIPs[i].ID < reservedIPs[j].ID })
+	return reservedIPs, nil
+}
+
+func (s *Server) reservedIPGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	ip, ok := s.reservedIPs[id]
+	if !ok {
+		return nil, notFound("reserved IP", id)
+	}
+	return ip, nil
+}
+
+func (s *Server) reservedIPCreate(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var subnetID int
+	var ip metalsoft.ReservedIP
+	if apiErr := param(params, 0, &subnetID); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &ip); apiErr != nil {
+		return nil, apiErr
+	}
+	subnet, ok := s.subnets[subnetID]
+	if !ok || s.deleted[subnet.NetworkID] {
+		return nil, notFound("subnet", subnetID)
+	}
+	infrastructureID := s.networks[subnet.NetworkID].InfrastructureID
+	if s.deleted[infrastructureID] {
+		return nil, notFound("infrastructure", infrastructureID)
+	}
+	for _, existing := range s.reservedIPs {
+		if existing.InfrastructureID == infrastructureID && existing.Label == ip.Label && !s.deleted[existing.ID] {
+			return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("reserved IP %q already exists", ip.Label)}
+		}
+	}
+	ip.ID = s.newID()
+	ip.InfrastructureID = infrastructureID
+	ip.SubnetID = subnetID
+	ip.Address = s.newIP()
+	ip.ServiceStatus = metalsoft.ServiceStatusOrdered
+	s.reservedIPs[ip.ID] = &ip
+	return ip, nil
+}
+
+func (s *Server) reservedIPDelete(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if _, ok := s.reservedIPs[id]; !ok {
+		return nil, notFound("reserved IP", id)
+	}
+	s.deleted[id] = true
+	return true, nil
+}
+
+func (s *Server) floatingIPAssign(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id, instanceID int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &instanceID); apiErr != nil {
+		return nil, apiErr
+	}
+	fip, ok := s.floatingIPs[id]
+	if !ok {
+		return nil, notFound("floating IP", id)
+	}
+	instance, ok := s.instances[instanceID]
+	if !ok {
+		return nil, notFound("instance", instanceID)
+	}
+	if instance.ServiceStatus != metalsoft.ServiceStatusActive {
+		return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("instance %d is not active", instanceID)}
+	}
+	fip.InstanceID = instanceID
+	return true, nil
+}
+
+func (s *Server) floatingIPUnassign(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	fip, ok := s.floatingIPs[id]
+	if !ok {
+		return nil, notFound("floating IP", id)
+	}
+	fip.InstanceID = 0
+	return true, nil
+}
+
+func (s *Server) serverTypesList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var datacenter string
+	if apiErr := param(params, 0, &datacenter); apiErr != nil {
+		return nil, apiErr
+	}
+	return s.serverTypes, nil
+}
+
+func (s *Server) osTemplateGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var label string
+	if apiErr := param(params, 0, &label); apiErr != nil {
+		return nil, apiErr
+	}
+	template, ok := s.osTemplates[label]
+	if !ok {
+		return nil, notFound("OS template", label)
+	}
+	return template, nil
+}
+
+func (s *Server) serversList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var datacenter string
+	if apiErr := param(params, 0, &datacenter); apiErr != nil {
+		return nil, apiErr
+	}
+	servers := []metalsoft.Server{}
+	for _, server := range s.servers {
+		if server.Datacenter == datacenter {
+			servers = append(servers, *server)
+		}
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers, nil
+}
+
+func (s *Server) serverSearch(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var search metalsoft.ServerSearch
+	if apiErr := param(params, 0, &search); apiErr != nil {
+		return nil, apiErr
+	}
+	if search.DiskType != "" && search.MinDiskCount == 0 {
+		search.MinDiskCount = 1
+	}
+	servers := []metalsoft.Server{}
+	for _, server := range s.servers {
+		if server.Datacenter != search.Datacenter ||
+			(search.ServerTypeID != 0 && server.ServerTypeID != search.ServerTypeID) ||
+			(search.Status != "" && server.Status != search.Status) ||
+			server.ProcessorCoreCount < search.MinCoreCount ||
+			server.RAMGB < search.MinRAMGB {
+			continue
+		}
+		disks := 0
+		for _, disk := range server.Disks {
+			if search.DiskType == "" || disk.Type == search.DiskType {
+				disks++
+			}
+		}
+		if disks < search.MinDiskCount {
+			continue
+		}
+		if search.MinCapacityMbps > 0 && !hasInterfaceCapacity(server, search.MinCapacityMbps) {
+			continue
+		}
+		if len(search.RackLabels) > 0 && !inRacks(search.RackLabels, server.RackLabel) {
+			continue
+		}
+		servers = append(servers, *server)
+	}
+	sort.Slice(servers, func(i, j int) bool { return servers[i].ID < servers[j].ID })
+	return servers, nil
+}
+
+// hasInterfaceCapacity tells whether a server has a network interface of at
+// least the given capacity.
+func hasInterfaceCapacity(server *metalsoft.Server, capacityMbps int) bool {
+	for _, iface := range server.Interfaces {
+		if iface.CapacityMbps >= capacityMbps {
+			return true
+		}
+	}
+	return false
+}
+
+// inRacks tells whether a rack label is one of the given ones.
+func inRacks(rackLabels []string, label string) bool {
+	for _, rackLabel := range rackLabels {
+		if rackLabel == label {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) serverGet(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	server, ok := s.servers[id]
+	if !ok {
+		return nil, notFound("server", id)
+	}
+	return *server, nil
+}
+
+func (s *Server) serverReserve(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	var reservationKey string
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &reservationKey); apiErr != nil {
+		return nil, apiErr
+	}
+	if reservationKey == "" {
+		return nil, invalidParams(fmt.Errorf("missing reservation key"))
+	}
+	server, ok := s.servers[id]
+	if !ok {
+		return nil, notFound("server", id)
+	}
+	switch {
+	case server.Status == metalsoft.ServerStatusAvailable:
+		server.Status = metalsoft.ServerStatusReserved
+		server.ReservationKey = reservationKey
+	case server.Status == metalsoft.ServerStatusReserved && server.ReservationKey == reservationKey:
+	default:
+		return nil, &metalsoft.APIError{Code: metalsoft.ErrorCodeConflict, Message: fmt.Sprintf("server %d is not available", id)}
+	}
+	return true, nil
+}
+
+func (s *Server) serverRelease(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var id int
+	var reservationKey string
+	if apiErr := param(params, 0, &id); apiErr != nil {
+		return nil, apiErr
+	}
+	if apiErr := param(params, 1, &reservationKey); apiErr != nil {
+		return nil, apiErr
+	}
+	server, ok := s.servers[id]
+	if !ok {
+		return nil, notFound("server", id)
+	}
+	if server.Status == metalsoft.ServerStatusReserved && server.ReservationKey == reservationKey {
+		server.Status = metalsoft.ServerStatusAvailable
+		server.ReservationKey = ""
+	}
+	return true, nil
+}
+
+func (s *Server) racksList(params []json.RawMessage) (interface{}, *metalsoft.APIError) {
+	var datacenter string
+	if apiErr := param(params, 0, &datacenter); apiErr != nil {
+		return nil, apiErr
+	}
+	racks := []metalsoft.Rack{}
+	for _, rack := range s.racks {
+		if rack.Datacenter == datacenter {
+			racks = append(racks, rack)
+		}
+	}
+	return racks, nil
+}
+
+// pickRack returns the rack of the datacenter allowed by rackLabels hosting
+// the fewest allocated servers, or an empty string if the datacenter has no
+// racks.
+func (s *Server) pickRack(datacenter string, rackLabels []string) string {
+	allowed := map[string]bool{}
+	for _, label := range rackLabels {
+		allowed[label] = true
+	}
+	picked, min := "", -1
+	for _, rack := range s.racks {
+		if rack.Datacenter != datacenter || (len(allowed) > 0 && !allowed[rack.Label]) {
+			continue
+		}
+		count := 0
+		for _, instance := range s.instances {
+			if instance.ServerID != 0 && instance.RackLabel == rack.Label {
+				count++
+			}
+		}
+		if min < 0 || count < min {
+			picked, min = rack.Label, count
+		}
+	}
+	return picked
+}
+
+func (s *Server) hasRack(datacenter, label string) bool {
+	for _, rack := range s.racks {
+		if rack.Datacenter == datacenter && rack.Label == label {
+			return true
+		}
+	}
+	return false
+}
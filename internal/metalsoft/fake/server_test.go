@@ -0,0 +1,618 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/metalsoft-io/cluster-api-provider-metalsoft/internal/metalsoft"
+)
+
+func newTestClient(t *testing.T) (*Server, metalsoft.Client) {
+	t.Helper()
+	g := NewWithT(t)
+
+	srv := NewServer()
+	t.Cleanup(srv.Close)
+	c, err := metalsoft.NewClient(srv.Credentials())
+	g.Expect(err).NotTo(HaveOccurred())
+	return srv, c
+}
+
+func TestInfrastructureLifecycle(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(infra.ServiceStatus).To(Equal(metalsoft.ServiceStatusOrdered))
+
+	byLabel, err := c.GetInfrastructureByLabel(ctx, "test")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(byLabel.ID).To(Equal(infra.ID))
+
+	serverTypes, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(serverTypes).NotTo(BeEmpty())
+	template, err := c.GetOSTemplate(ctx, "ubuntu-22-04")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(template.SupportsBootstrapFormat(metalsoft.BootstrapFormatCloudConfig)).To(BeTrue())
+	g.Expect(template.SupportsBootstrapFormat(metalsoft.BootstrapFormatIgnition)).To(BeFalse())
+	flatcar, err := c.GetOSTemplate(ctx, "flatcar-stable")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(flatcar.SupportsBootstrapFormat(metalsoft.BootstrapFormatIgnition)).To(BeTrue())
+	g.Expect(flatcar.SupportsBootstrapFormat(metalsoft.BootstrapFormatCloudConfig)).To(BeFalse())
+
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:         "worker",
+		InstanceCount: 1,
+		ServerTypeID:  serverTypes[0].ID,
+		OSTemplateID:  template.ID,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	srv.SetDeployDuration(time.Hour)
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	infra, err = c.GetInfrastructure(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(infra.DeployOngoing()).To(BeTrue())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).NotTo(Succeed())
+
+	srv.SetDeployDuration(0)
+	srv.mu.Lock()
+	srv.infrastructures[infra.ID].deployFinishesAt = time.Now()
+	srv.mu.Unlock()
+	infra, err = c.GetInfrastructure(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(infra.DeployOngoing()).To(BeFalse())
+	g.Expect(infra.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances).To(HaveLen(1))
+	g.Expect(instances[0].ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	g.Expect(instances[0].Interfaces).NotTo(BeEmpty())
+	g.Expect(instances[0].Interfaces[0].IPs).NotTo(BeEmpty())
+
+	g.Expect(c.DeleteInfrastructure(ctx, infra.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	_, err = c.GetInfrastructure(ctx, infra.ID)
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+	_, err = c.GetInstanceArray(ctx, ia.ID)
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+}
+
+func TestInstanceArrayStopAndDelete(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	serverType, ok := srv.ServerType("M.8.32.v2")
+	g.Expect(ok).To(BeTrue())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "worker", ServerTypeID: serverType.ID})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "worker", ServerTypeID: serverType.ID})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+	g.Expect(metalsoft.IsConflict(c.StopInstanceArray(ctx, ia.ID))).To(BeTrue())
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	allocated, _ := srv.ServerType("M.8.32.v2")
+	g.Expect(allocated.AvailableCount).To(Equal(serverType.AvailableCount - 1))
+
+	g.Expect(c.StopInstanceArray(ctx, ia.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	ia, err = c.GetInstanceArray(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ia.ServiceStatus).To(Equal(metalsoft.ServiceStatusStopped))
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances[0].ServiceStatus).To(Equal(metalsoft.ServiceStatusStopped))
+
+	g.Expect(c.DeleteInstanceArray(ctx, ia.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	_, err = c.GetInstanceArray(ctx, ia.ID)
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+	released, _ := srv.ServerType("M.8.32.v2")
+	g.Expect(released.AvailableCount).To(Equal(serverType.AvailableCount))
+}
+
+func TestNetworks(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	other, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "other", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	networks, err := c.ListNetworks(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(networks).To(HaveLen(1))
+	wan := networks[0]
+	g.Expect(wan.Type).To(Equal(metalsoft.NetworkTypeWAN))
+
+	lan, err := c.CreateNetwork(ctx, infra.ID, metalsoft.Network{Label: "private", Type: metalsoft.NetworkTypeLAN, VLANID: 100})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lan.ServiceStatus).To(Equal(metalsoft.ServiceStatusOrdered))
+	_, err = c.CreateNetwork(ctx, infra.ID, metalsoft.Network{Label: "private", Type: metalsoft.NetworkTypeSAN})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+	_, err = c.CreateNetwork(ctx, infra.ID, metalsoft.Network{Label: "storage", Type: metalsoft.NetworkTypeSAN, VLANID: 100})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+	_, err = c.CreateNetwork(ctx, infra.ID, metalsoft.Network{Label: "storage", Type: "fibre"})
+	g.Expect(err).To(HaveOccurred())
+	foreign, err := c.CreateNetwork(ctx, other.ID, metalsoft.Network{Label: "private", Type: metalsoft.NetworkTypeLAN, VLANID: 100})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	serverTypes, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:        "foreign",
+		ServerTypeID: serverTypes[0].ID,
+		Interfaces:   []metalsoft.InstanceArrayInterface{{Index: 0, NetworkID: foreign.ID}},
+	})
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:        "worker",
+		ServerTypeID: serverTypes[0].ID,
+		Interfaces: []metalsoft.InstanceArrayInterface{
+			{Index: 0, NetworkID: wan.ID},
+			{Index: 1, NetworkID: lan.ID},
+			{Index: 2},
+		},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	networks, err = c.ListNetworks(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, network := range networks {
+		g.Expect(network.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	}
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances[0].Interfaces).To(HaveLen(3))
+	g.Expect(instances[0].Interfaces[1].NetworkID).To(Equal(lan.ID))
+	g.Expect(instances[0].Interfaces[1].IPs).To(HaveLen(1))
+	g.Expect(instances[0].Interfaces[2].IPs).To(BeEmpty())
+}
+
+func TestStaticIPs(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	lan, err := c.CreateNetwork(ctx, infra.ID, metalsoft.Network{Label: "private", Type: metalsoft.NetworkTypeLAN})
+	g.Expect(err).NotTo(HaveOccurred())
+	serverTypes, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:        "invalid",
+		ServerTypeID: serverTypes[0].ID,
+		Interfaces: []metalsoft.InstanceArrayInterface{
+			{Index: 0, NetworkID: lan.ID, StaticIPs: []metalsoft.StaticIP{{Address: "not-an-ip", Prefix: 24}}},
+		},
+	})
+	g.Expect(err).To(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:        "detached",
+		ServerTypeID: serverTypes[0].ID,
+		Interfaces: []metalsoft.InstanceArrayInterface{
+			{Index: 0, StaticIPs: []metalsoft.StaticIP{{Address: "10.0.0.10", Prefix: 24}}},
+		},
+	})
+	g.Expect(err).To(HaveOccurred())
+
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:        "worker",
+		ServerTypeID: serverTypes[0].ID,
+		Interfaces: []metalsoft.InstanceArrayInterface{{
+			Index:     0,
+			NetworkID: lan.ID,
+			StaticIPs: []metalsoft.StaticIP{
+				{Address: "10.0.0.10", Prefix: 24, Gateway: "10.0.0.1"},
+				{Address: "2001:db8::10", Prefix: 64},
+			},
+		}},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances[0].Interfaces[0].IPs).To(Equal([]metalsoft.IP{
+		{Address: "10.0.0.10", Type: "ipv4"},
+		{Address: "2001:db8::10", Type: "ipv6"},
+	}))
+}
+
+func TestBootstrapDataLimits(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	_, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	serverTypes, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	for label, ia := range map[string]metalsoft.InstanceArray{
+		"cloud-init":    {CloudInitData: strings.Repeat("a", metalsoft.MaxCloudInitDataBytes+1)},
+		"variables":     {CustomVariables: map[string]string{"data": strings.Repeat("a", metalsoft.MaxCustomVariableBytes+1)}},
+		"not-an-iso":    {ConfigDriveISO: make([]byte, 64*1024)},
+		"oversized-iso": {ConfigDriveISO: make([]byte, metalsoft.MaxConfigDriveBytes+1)},
+		"truncated-iso": {ConfigDriveISO: []byte("CD001")},
+	} {
+		ia.Label = label
+		ia.ServerTypeID = serverTypes[0].ID
+		_, err = c.CreateInstanceArray(ctx, infra.ID, ia)
+		g.Expect(err).To(HaveOccurred(), label)
+	}
+
+	iso := make([]byte, 64*1024)
+	copy(iso[16*2048+1:], "CD001")
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label:          "worker",
+		ServerTypeID:   serverTypes[0].ID,
+		ConfigDriveISO: iso,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	ia, err = c.GetInstanceArray(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ia.ConfigDriveISO).To(Equal(iso))
+}
+
+func TestRackPlacement(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+	srv.AddRack(metalsoft.Rack{Label: "a1", Datacenter: "dc", SwitchGroup: "a"})
+	srv.AddRack(metalsoft.Rack{Label: "a2", Datacenter: "dc", SwitchGroup: "a"})
+	srv.AddRack(metalsoft.Rack{Label: "b1", Datacenter: "dc", SwitchGroup: "b"})
+	srv.AddRack(metalsoft.Rack{Label: "c1", Datacenter: "other"})
+
+	racks, err := c.ListRacks(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(racks).To(HaveLen(3))
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	serverTypes, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{
+		Label: "elsewhere", ServerTypeID: serverTypes[0].ID, RackLabels: []string{"c1"},
+	})
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+
+	var ids []int
+	for _, label := range []string{"a-0", "a-1", "any"} {
+		ia := metalsoft.InstanceArray{Label: label, ServerTypeID: serverTypes[0].ID}
+		if label != "any" {
+			ia.RackLabels = []string{"a1", "a2"}
+		}
+		created, err := c.CreateInstanceArray(ctx, infra.ID, ia)
+		g.Expect(err).NotTo(HaveOccurred())
+		ids = append(ids, created.ID)
+		g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	}
+
+	rackOf := func(id int) string {
+		instances, err := c.ListInstances(ctx, id)
+		g.Expect(err).NotTo(HaveOccurred())
+		return instances[0].RackLabel
+	}
+	g.Expect([]string{rackOf(ids[0]), rackOf(ids[1])}).To(ConsistOf("a1", "a2"))
+	g.Expect(rackOf(ids[2])).To(Equal("b1"))
+}
+
+func TestServerInventory(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s, c := newTestClient(t)
+
+	serverType, ok := s.ServerType("M.16.64.v2")
+	g.Expect(ok).To(BeTrue())
+	server := s.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "dc", RackLabel: "r1", RAMGB: 64})
+	s.AddServer(metalsoft.Server{ServerTypeID: serverType.ID, Datacenter: "other"})
+
+	servers, err := c.ListServers(ctx, "dc")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(servers).To(Equal([]metalsoft.Server{server}))
+	g.Expect(server.Status).To(Equal(metalsoft.ServerStatusAvailable))
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "missing", ServerID: 1})
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "worker", ServerID: server.ID})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ia.ServerTypeID).To(Equal(serverType.ID))
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances[0].ServerID).To(Equal(server.ID))
+	g.Expect(instances[0].RackLabel).To(Equal("r1"))
+
+	allocated, err := c.GetServer(ctx, server.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(allocated.Status).To(Equal(metalsoft.ServerStatusUsed))
+	g.Expect(allocated.PowerStatus).To(Equal(metalsoft.ServerPowerStatusOn))
+	g.Expect(allocated.InstanceID).To(Equal(instances[0].ID))
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "other", ServerID: server.ID})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+
+	g.Expect(c.DeleteInstanceArray(ctx, ia.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	released, ok := s.InventoryServer(server.ID)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(released.Status).To(Equal(metalsoft.ServerStatusAvailable))
+	g.Expect(released.InstanceID).To(BeZero())
+}
+
+func TestServerSearch(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s, c := newTestClient(t)
+
+	small := s.AddServer(metalsoft.Server{
+		Datacenter:         "dc",
+		ProcessorCoreCount: 16,
+		RAMGB:              64,
+		Disks:              []metalsoft.ServerDisk{{SizeGB: 960, Type: metalsoft.DiskTypeSSD}},
+		Interfaces:         []metalsoft.ServerInterface{{CapacityMbps: 10000}},
+	})
+	storage := s.AddServer(metalsoft.Server{
+		Datacenter:         "dc",
+		ProcessorCoreCount: 32,
+		RAMGB:              128,
+		Disks: []metalsoft.ServerDisk{
+			{SizeGB: 3840, Type: metalsoft.DiskTypeNVMe},
+			{SizeGB: 3840, Type: metalsoft.DiskTypeNVMe},
+			{SizeGB: 480, Type: metalsoft.DiskTypeSSD},
+		},
+		Interfaces: []metalsoft.ServerInterface{{CapacityMbps: 10000}, {CapacityMbps: 100000}},
+	})
+	s.AddServer(metalsoft.Server{Datacenter: "other", ProcessorCoreCount: 64, RAMGB: 512})
+
+	search := func(search metalsoft.ServerSearch) []int {
+		search.Datacenter = "dc"
+		servers, err := c.SearchServers(ctx, search)
+		g.Expect(err).NotTo(HaveOccurred())
+		ids := []int{}
+		for _, server := range servers {
+			ids = append(ids, server.ID)
+		}
+		return ids
+	}
+	g.Expect(search(metalsoft.ServerSearch{})).To(Equal([]int{small.ID, storage.ID}))
+	g.Expect(search(metalsoft.ServerSearch{MinCoreCount: 20})).To(Equal([]int{storage.ID}))
+	g.Expect(search(metalsoft.ServerSearch{MinRAMGB: 256})).To(BeEmpty())
+	g.Expect(search(metalsoft.ServerSearch{DiskType: metalsoft.DiskTypeNVMe})).To(Equal([]int{storage.ID}))
+	g.Expect(search(metalsoft.ServerSearch{DiskType: metalsoft.DiskTypeNVMe, MinDiskCount: 3})).To(BeEmpty())
+	g.Expect(search(metalsoft.ServerSearch{MinDiskCount: 3})).To(Equal([]int{storage.ID}))
+	g.Expect(search(metalsoft.ServerSearch{MinCapacityMbps: 25000})).To(Equal([]int{storage.ID}))
+	g.Expect(search(metalsoft.ServerSearch{Status: metalsoft.ServerStatusUsed})).To(BeEmpty())
+}
+
+func TestServerReservation(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	s, c := newTestClient(t)
+
+	server := s.AddServer(metalsoft.Server{Datacenter: "dc"})
+	g.Expect(c.ReserveServer(ctx, server.ID, "machine-a")).To(Succeed())
+	g.Expect(c.ReserveServer(ctx, server.ID, "machine-a")).To(Succeed())
+	g.Expect(metalsoft.IsConflict(c.ReserveServer(ctx, server.ID, "machine-b"))).To(BeTrue())
+	servers, err := c.SearchServers(ctx, metalsoft.ServerSearch{Datacenter: "dc", Status: metalsoft.ServerStatusAvailable})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(servers).To(BeEmpty())
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "b", ServerID: server.ID, ReservationKey: "machine-b"})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "a", ServerID: server.ID, ReservationKey: "machine-a"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	allocated, ok := s.InventoryServer(server.ID)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(allocated.Status).To(Equal(metalsoft.ServerStatusUsed))
+	g.Expect(allocated.ReservationKey).To(BeEmpty())
+	g.Expect(metalsoft.IsConflict(c.ReserveServer(ctx, server.ID, "machine-a"))).To(BeTrue())
+
+	g.Expect(c.DeleteInstanceArray(ctx, ia.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	g.Expect(c.ReserveServer(ctx, server.ID, "machine-b")).To(Succeed())
+	g.Expect(c.ReleaseServer(ctx, server.ID, "machine-a")).To(Succeed())
+	reserved, _ := s.InventoryServer(server.ID)
+	g.Expect(reserved.ReservationKey).To(Equal("machine-b"))
+	g.Expect(c.ReleaseServer(ctx, server.ID, "machine-b")).To(Succeed())
+	released, _ := s.InventoryServer(server.ID)
+	g.Expect(released.Status).To(Equal(metalsoft.ServerStatusAvailable))
+}
+
+func TestLoadBalancerBackends(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "control-plane"})
+	g.Expect(err).NotTo(HaveOccurred())
+	lb, err := c.CreateLoadBalancer(ctx, infra.ID, metalsoft.LoadBalancer{Label: "apiserver", Port: 6443})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lb.Address).NotTo(BeEmpty())
+	g.Expect(lb.ServiceStatus).To(Equal(metalsoft.ServiceStatusOrdered))
+	_, err = c.CreateLoadBalancer(ctx, infra.ID, metalsoft.LoadBalancer{Label: "apiserver", Port: 6443})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	backend := metalsoft.LoadBalancerBackend{InstanceID: instances[0].ID, Port: 6443}
+	g.Expect(metalsoft.IsConflict(c.AddLoadBalancerBackend(ctx, lb.ID, backend))).To(BeTrue())
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	g.Expect(c.AddLoadBalancerBackend(ctx, lb.ID, backend)).To(Succeed())
+	lb, err = c.GetLoadBalancer(ctx, lb.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(lb.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	g.Expect(lb.Backends).To(HaveLen(1))
+	g.Expect(lb.Backends[0].Address).NotTo(BeEmpty())
+
+	g.Expect(c.RemoveLoadBalancerBackend(ctx, lb.ID, instances[0].ID)).To(Succeed())
+	g.Expect(metalsoft.IsNotFound(c.RemoveLoadBalancerBackend(ctx, lb.ID, instances[0].ID))).To(BeTrue())
+
+	// Deleting an instance removes it from the load balancers.
+	g.Expect(c.AddLoadBalancerBackend(ctx, lb.ID, backend)).To(Succeed())
+	g.Expect(c.DeleteInstanceArray(ctx, ia.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	detached, ok := srv.LoadBalancer(lb.ID)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(detached.Backends).To(BeEmpty())
+
+	g.Expect(c.DeleteLoadBalancer(ctx, lb.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	_, err = c.GetLoadBalancer(ctx, lb.ID)
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+}
+
+func TestFloatingIPAssignment(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	ia, err := c.CreateInstanceArray(ctx, infra.ID, metalsoft.InstanceArray{Label: "control-plane"})
+	g.Expect(err).NotTo(HaveOccurred())
+	fip, err := c.CreateFloatingIP(ctx, infra.ID, metalsoft.FloatingIP{Label: "apiserver"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fip.Address).NotTo(BeEmpty())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+
+	instances, err := c.ListInstances(ctx, ia.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(metalsoft.IsNotFound(c.AssignFloatingIP(ctx, fip.ID, 1))).To(BeTrue())
+	g.Expect(c.AssignFloatingIP(ctx, fip.ID, instances[0].ID)).To(Succeed())
+	fip, err = c.GetFloatingIP(ctx, fip.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(fip.InstanceID).To(Equal(instances[0].ID))
+	g.Expect(fip.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+
+	g.Expect(c.UnassignFloatingIP(ctx, fip.ID)).To(Succeed())
+	unassigned, ok := srv.FloatingIP(fip.ID)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(unassigned.InstanceID).To(BeZero())
+
+	// Floating IPs are deleted together with their infrastructure.
+	g.Expect(c.DeleteInfrastructure(ctx, infra.ID)).To(Succeed())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	_, ok = srv.FloatingIP(fip.ID)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestReservedIPs(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	infra, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	networks, err := c.ListNetworks(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	subnets, err := c.ListSubnets(ctx, networks[0].ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(subnets).To(HaveLen(1))
+	g.Expect(subnets[0].PrefixSize).To(Equal(24))
+
+	ip, err := c.CreateReservedIP(ctx, subnets[0].ID, metalsoft.ReservedIP{Label: "apiserver"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ip.Address).NotTo(BeEmpty())
+	g.Expect(ip.InfrastructureID).To(Equal(infra.ID))
+	_, err = c.CreateReservedIP(ctx, subnets[0].ID, metalsoft.ReservedIP{Label: "apiserver"})
+	g.Expect(metalsoft.IsConflict(err)).To(BeTrue())
+	_, err = c.CreateReservedIP(ctx, 1, metalsoft.ReservedIP{Label: "other"})
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	ip, err = c.GetReservedIP(ctx, ip.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ip.ServiceStatus).To(Equal(metalsoft.ServiceStatusActive))
+	reservedIPs, err := c.ListReservedIPs(ctx, infra.ID)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(reservedIPs).To(ConsistOf(*ip))
+
+	g.Expect(c.DeleteReservedIP(ctx, ip.ID)).To(Succeed())
+	_, ok := srv.ReservedIP(ip.ID)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(c.DeployInfrastructure(ctx, infra.ID)).To(Succeed())
+	_, ok = srv.ReservedIP(ip.ID)
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestFailureInjection(t *testing.T) {
+	g := NewWithT(t)
+	ctx := context.Background()
+	srv, c := newTestClient(t)
+
+	srv.FailNext("infrastructure_create", 1, 500, "boom")
+	_, err := c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).To(MatchError(ContainSubstring("boom")))
+	_, err = c.CreateInfrastructure(ctx, metalsoft.Infrastructure{Label: "test", Datacenter: "dc"})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(srv.Calls("infrastructure_create")).To(Equal(2))
+
+	_, err = c.GetInfrastructure(ctx, 1)
+	g.Expect(metalsoft.IsNotFound(err)).To(BeTrue())
+}
+
+func TestLatency(t *testing.T) {
+	g := NewWithT(t)
+	srv, c := newTestClient(t)
+
+	srv.SetLatency(200 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ListServerTypes(ctx, "dc")
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestUnauthorized(t *testing.T) {
+	g := NewWithT(t)
+	srv := NewServer()
+	defer srv.Close()
+
+	creds := srv.Credentials()
+	creds.APIKey = "wrong"
+	c, err := metalsoft.NewClient(creds)
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.ListServerTypes(context.Background(), "dc")
+	g.Expect(err).To(MatchError(ContainSubstring("401")))
+}
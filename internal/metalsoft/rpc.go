@@ -0,0 +1,395 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metalsoft
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RPCPath is the path of the MetalSoft JSON-RPC endpoint relative to the API base URL.
+const RPCPath = "/api/developer/developer"
+
+const defaultTimeout = 30 * time.Second
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      uint64          `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *APIError       `json:"error,omitempty"`
+}
+
+// rpcClient implements Client over the MetalSoft JSON-RPC 2.0 API.
+type rpcClient struct {
+	creds      Credentials
+	url        string
+	httpClient *http.Client
+	nextID     uint64
+}
+
+var _ Client = &rpcClient{}
+
+func newRPCClient(creds Credentials) *rpcClient {
+	return &rpcClient{
+		creds:      creds,
+		url:        strings.TrimSuffix(creds.Endpoint, "/") + RPCPath,
+		httpClient: &http.Client{Timeout: defaultTimeout},
+	}
+}
+
+// call invokes method with params and decodes the result into result, which
+// may be nil when the result is not needed.
+func (c *rpcClient) call(ctx context.Context, result interface{}, method string, params ...interface{}) error {
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&c.nextID, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build %s request: %w", method, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+c.creds.APIKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read %s response: %w", method, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to call %s: unexpected HTTP status %s", method, resp.Status)
+	}
+
+	var rpcResp rpcResponse
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return rpcResp.Error
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("failed to decode %s result: %w", method, err)
+	}
+	return nil
+}
+
+func (c *rpcClient) GetInfrastructure(ctx context.Context, id int) (*Infrastructure, error) {
+	var infrastructure Infrastructure
+	if err := c.call(ctx, &infrastructure, "infrastructure_get", id); err != nil {
+		return nil, err
+	}
+	return &infrastructure, nil
+}
+
+func (c *rpcClient) GetInfrastructureByLabel(ctx context.Context, label string) (*Infrastructure, error) {
+	var infrastructure Infrastructure
+	if err := c.call(ctx, &infrastructure, "infrastructure_get", label); err != nil {
+		return nil, err
+	}
+	return &infrastructure, nil
+}
+
+func (c *rpcClient) CreateInfrastructure(ctx context.Context, infrastructure Infrastructure) (*Infrastructure, error) {
+	var created Infrastructure
+	if err := c.call(ctx, &created, "infrastructure_create", c.creds.UserEmail, infrastructure); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeployInfrastructure(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "infrastructure_deploy", id)
+}
+
+func (c *rpcClient) DeleteInfrastructure(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "infrastructure_delete", id)
+}
+
+func (c *rpcClient) ListInstanceArrays(ctx context.Context, infrastructureID int) ([]InstanceArray, error) {
+	var instanceArrays []InstanceArray
+	if err := c.call(ctx, &instanceArrays, "instance_arrays", infrastructureID); err != nil {
+		return nil, err
+	}
+	return instanceArrays, nil
+}
+
+func (c *rpcClient) GetInstanceArray(ctx context.Context, id int) (*InstanceArray, error) {
+	var instanceArray InstanceArray
+	if err := c.call(ctx, &instanceArray, "instance_array_get", id); err != nil {
+		return nil, err
+	}
+	return &instanceArray, nil
+}
+
+func (c *rpcClient) CreateInstanceArray(ctx context.Context, infrastructureID int, instanceArray InstanceArray) (*InstanceArray, error) {
+	var created InstanceArray
+	if err := c.call(ctx, &created, "instance_array_create", infrastructureID, instanceArray); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) StopInstanceArray(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "instance_array_stop", id)
+}
+
+func (c *rpcClient) DeleteInstanceArray(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "instance_array_delete", id)
+}
+
+func (c *rpcClient) ListInstances(ctx context.Context, instanceArrayID int) ([]Instance, error) {
+	var instances []Instance
+	if err := c.call(ctx, &instances, "instance_array_instances", instanceArrayID); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+func (c *rpcClient) ListDriveArrays(ctx context.Context, infrastructureID int) ([]DriveArray, error) {
+	var driveArrays []DriveArray
+	if err := c.call(ctx, &driveArrays, "drive_arrays", infrastructureID); err != nil {
+		return nil, err
+	}
+	return driveArrays, nil
+}
+
+func (c *rpcClient) CreateDriveArray(ctx context.Context, infrastructureID int, driveArray DriveArray) (*DriveArray, error) {
+	var created DriveArray
+	if err := c.call(ctx, &created, "drive_array_create", infrastructureID, driveArray); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeleteDriveArray(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "drive_array_delete", id)
+}
+
+func (c *rpcClient) ListNetworks(ctx context.Context, infrastructureID int) ([]Network, error) {
+	var networks []Network
+	if err := c.call(ctx, &networks, "networks", infrastructureID); err != nil {
+		return nil, err
+	}
+	return networks, nil
+}
+
+func (c *rpcClient) CreateNetwork(ctx context.Context, infrastructureID int, network Network) (*Network, error) {
+	var created Network
+	if err := c.call(ctx, &created, "network_create", infrastructureID, network); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeleteNetwork(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "network_delete", id)
+}
+
+func (c *rpcClient) ListSubnets(ctx context.Context, networkID int) ([]Subnet, error) {
+	var subnets []Subnet
+	if err := c.call(ctx, &subnets, "subnets", networkID); err != nil {
+		return nil, err
+	}
+	return subnets, nil
+}
+
+func (c *rpcClient) ListLoadBalancers(ctx context.Context, infrastructureID int) ([]LoadBalancer, error) {
+	var loadBalancers []LoadBalancer
+	if err := c.call(ctx, &loadBalancers, "load_balancers", infrastructureID); err != nil {
+		return nil, err
+	}
+	return loadBalancers, nil
+}
+
+func (c *rpcClient) GetLoadBalancer(ctx context.Context, id int) (*LoadBalancer, error) {
+	var loadBalancer LoadBalancer
+	if err := c.call(ctx, &loadBalancer, "load_balancer_get", id); err != nil {
+		return nil, err
+	}
+	return &loadBalancer, nil
+}
+
+func (c *rpcClient) CreateLoadBalancer(ctx context.Context, infrastructureID int, loadBalancer LoadBalancer) (*LoadBalancer, error) {
+	var created LoadBalancer
+	if err := c.call(ctx, &created, "load_balancer_create", infrastructureID, loadBalancer); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeleteLoadBalancer(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "load_balancer_delete", id)
+}
+
+func (c *rpcClient) AddLoadBalancerBackend(ctx context.Context, id int, backend LoadBalancerBackend) error {
+	return c.call(ctx, nil, "load_balancer_backend_add", id, backend)
+}
+
+func (c *rpcClient) RemoveLoadBalancerBackend(ctx context.Context, id int, instanceID int) error {
+	return c.call(ctx, nil, "load_balancer_backend_remove", id, instanceID)
+}
+
+func (c *rpcClient) ListFloatingIPs(ctx context.Context, infrastructureID int) ([]FloatingIP, error) {
+	var floatingIPs []FloatingIP
+	if err := c.call(ctx, &floatingIPs, "floating_ips", infrastructureID); err != nil {
+		return nil, err
+	}
+	return floatingIPs, nil
+}
+
+func (c *rpcClient) GetFloatingIP(ctx context.Context, id int) (*FloatingIP, error) {
+	var floatingIP FloatingIP
+	if err := c.call(ctx, &floatingIP, "floating_ip_get", id); err != nil {
+		return nil, err
+	}
+	return &floatingIP, nil
+}
+
+func (c *rpcClient) CreateFloatingIP(ctx context.Context, infrastructureID int, floatingIP FloatingIP) (*FloatingIP, error) {
+	var created FloatingIP
+	if err := c.call(ctx, &created, "floating_ip_create", infrastructureID, floatingIP); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeleteFloatingIP(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "floating_ip_delete", id)
+}
+
+func (c *rpcClient) AssignFloatingIP(ctx context.Context, id int, instanceID int) error {
+	return c.call(ctx, nil, "floating_ip_assign", id, instanceID)
+}
+
+func (c *rpcClient) UnassignFloatingIP(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "floating_ip_unassign", id)
+}
+
+func (c *rpcClient) ListReservedIPs(ctx context.Context, infrastructureID int) ([]ReservedIP, error) {
+	var reservedIPs []ReservedIP
+	if err := c.call(ctx, &reservedIPs, "reserved_ips", infrastructureID); err != nil {
+		return nil, err
+	}
+	return reservedIPs, nil
+}
+
+func (c *rpcClient) GetReservedIP(ctx context.Context, id int) (*ReservedIP, error) {
+	var reservedIP ReservedIP
+	if err := c.call(ctx, &reservedIP, "reserved_ip_get", id); err != nil {
+		return nil, err
+	}
+	return &reservedIP, nil
+}
+
+func (c *rpcClient) CreateReservedIP(ctx context.Context, subnetID int, reservedIP ReservedIP) (*ReservedIP, error) {
+	var created ReservedIP
+	if err := c.call(ctx, &created, "reserved_ip_create", subnetID, reservedIP); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+func (c *rpcClient) DeleteReservedIP(ctx context.Context, id int) error {
+	return c.call(ctx, nil, "reserved_ip_delete", id)
+}
+
+func (c *rpcClient) ListServerTypes(ctx context.Context, datacenter string) ([]ServerType, error) {
+	var serverTypes []ServerType
+	if err := c.call(ctx, &serverTypes, "server_types", datacenter); err != nil {
+		return nil, err
+	}
+	return serverTypes, nil
+}
+
+func (c *rpcClient) ListRacks(ctx context.Context, datacenter string) ([]Rack, error) {
+	var racks []Rack
+	if err := c.call(ctx, &racks, "server_racks", datacenter); err != nil {
+		return nil, err
+	}
+	return racks, nil
+}
+
+func (c *rpcClient) ListServers(ctx context.Context, datacenter string) ([]Server, error) {
+	var servers []Server
+	if err := c.call(ctx, &servers, "servers", datacenter); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+func (c *rpcClient) SearchServers(ctx context.Context, search ServerSearch) ([]Server, error) {
+	var servers []Server
+	if err := c.call(ctx, &servers, "server_search", search); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+func (c *rpcClient) GetServer(ctx context.Context, id int) (*Server, error) {
+	var server Server
+	if err := c.call(ctx, &server, "server_get", id); err != nil {
+		return nil, err
+	}
+	return &server, nil
+}
+
+func (c *rpcClient) ReserveServer(ctx context.Context, id int, reservationKey string) error {
+	return c.call(ctx, nil, "server_reserve", id, reservationKey)
+}
+
+func (c *rpcClient) ReleaseServer(ctx context.Context, id int, reservationKey string) error {
+	return c.call(ctx, nil, "server_release", id, reservationKey)
+}
+
+func (c *rpcClient) GetOSTemplate(ctx context.Context, label string) (*OSTemplate, error) {
+	var template OSTemplate
+	if err := c.call(ctx, &template, "os_template_get", label); err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
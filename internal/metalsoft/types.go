@@ -0,0 +1,317 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metalsoft
+
+// Service statuses shared by MetalSoft infrastructures, instance arrays,
+// drive arrays, networks and instances.
+const (
+	ServiceStatusOrdered = "ordered"
+	ServiceStatusActive  = "active"
+	ServiceStatusStopped = "stopped"
+	ServiceStatusDeleted = "deleted"
+)
+
+// Deploy statuses reported in an infrastructure operation.
+const (
+	DeployStatusNotStarted = "not_started"
+	DeployStatusOngoing    = "ongoing"
+	DeployStatusFinished   = "finished"
+)
+
+// Statuses of the servers of the MetalSoft inventory.
+const (
+	ServerStatusAvailable = "available"
+	ServerStatusReserved  = "reserved"
+	ServerStatusUsed      = "used"
+)
+
+// Power statuses of MetalSoft servers.
+const (
+	ServerPowerStatusOn  = "on"
+	ServerPowerStatusOff = "off"
+)
+
+// Disk types of MetalSoft servers.
+const (
+	DiskTypeHDD  = "hdd"
+	DiskTypeSSD  = "ssd"
+	DiskTypeNVMe = "nvme"
+)
+
+// Network types supported by MetalSoft.
+const (
+	NetworkTypeWAN = "wan"
+	NetworkTypeLAN = "lan"
+	NetworkTypeSAN = "san"
+)
+
+// Bootstrap data formats read by the first boot agent of OS templates.
+const (
+	BootstrapFormatCloudConfig = "cloud-config"
+	BootstrapFormatIgnition    = "ignition"
+)
+
+// Size limits of the instance array fields carrying bootstrap data, in bytes.
+const (
+	MaxCloudInitDataBytes  = 64 * 1024
+	MaxCustomVariableBytes = 32 * 1024
+	MaxConfigDriveBytes    = 2 * 1024 * 1024
+)
+
+// Infrastructure is a MetalSoft infrastructure, the unit of deployment that
+// groups the instance arrays, drive arrays and networks of a cluster.
+type Infrastructure struct {
+	ID            int                      `json:"infrastructure_id,omitempty"`
+	Label         string                   `json:"infrastructure_label"`
+	Datacenter    string                   `json:"datacenter_name"`
+	ServiceStatus string                   `json:"infrastructure_service_status,omitempty"`
+	Operation     *InfrastructureOperation `json:"infrastructure_operation,omitempty"`
+}
+
+// InfrastructureOperation describes the pending or running operation of an infrastructure.
+type InfrastructureOperation struct {
+	DeployStatus string `json:"infrastructure_deploy_status,omitempty"`
+	DeployType   string `json:"infrastructure_deploy_type,omitempty"`
+}
+
+// DeployOngoing returns true while a deploy of the infrastructure is running.
+func (i *Infrastructure) DeployOngoing() bool {
+	return i.Operation != nil && i.Operation.DeployStatus == DeployStatusOngoing
+}
+
+// InstanceArray is a group of identically configured MetalSoft instances.
+type InstanceArray struct {
+	ID               int                      `json:"instance_array_id,omitempty"`
+	InfrastructureID int                      `json:"infrastructure_id,omitempty"`
+	Label            string                   `json:"instance_array_label"`
+	InstanceCount    int                      `json:"instance_array_instance_count"`
+	ServerTypeID     int                      `json:"server_type_id,omitempty"`
+	OSTemplateID     int                      `json:"volume_template_id,omitempty"`
+	BootDriveSizeMB  int                      `json:"instance_array_boot_drive_size_mbytes,omitempty"`
+	RackLabels       []string                 `json:"instance_array_server_rack_labels,omitempty"`
+	ServerID         int                      `json:"server_id,omitempty"`
+	ReservationKey   string                   `json:"server_reservation_key,omitempty"`
+	SSHKeyIDs        []int                    `json:"instance_array_ssh_key_ids,omitempty"`
+	CustomVariables  map[string]string        `json:"instance_array_custom_variables,omitempty"`
+	CloudInitData    string                   `json:"instance_array_cloudinit_user_data,omitempty"`
+	CloudInitNetwork string                   `json:"instance_array_cloudinit_network_config,omitempty"`
+	ConfigDriveISO   []byte                   `json:"instance_array_config_drive_iso,omitempty"`
+	Interfaces       []InstanceArrayInterface `json:"instance_array_interfaces,omitempty"`
+	ServiceStatus    string                   `json:"instance_array_service_status,omitempty"`
+}
+
+// InstanceArrayInterface attaches an instance array network interface to a
+// network. Interfaces with static IPs use them instead of addresses allocated
+// from the subnets of the network.
+type InstanceArrayInterface struct {
+	Index     int        `json:"instance_array_interface_index"`
+	NetworkID int        `json:"network_id,omitempty"`
+	StaticIPs []StaticIP `json:"instance_array_interface_static_ips,omitempty"`
+}
+
+// StaticIP is an externally managed address of an instance array interface.
+type StaticIP struct {
+	Address string `json:"ip_human_readable"`
+	Prefix  int    `json:"ip_prefix"`
+	Gateway string `json:"ip_gateway,omitempty"`
+}
+
+// Instance is a single bare-metal server deployed as part of an instance array.
+type Instance struct {
+	ID              int                 `json:"instance_id"`
+	Label           string              `json:"instance_label"`
+	InstanceArrayID int                 `json:"instance_array_id"`
+	ServerID        int                 `json:"server_id,omitempty"`
+	ServerTypeID    int                 `json:"server_type_id,omitempty"`
+	RackLabel       string              `json:"server_rack_label,omitempty"`
+	Hostname        string              `json:"instance_subdomain_permanent,omitempty"`
+	ServiceStatus   string              `json:"instance_service_status,omitempty"`
+	Interfaces      []InstanceInterface `json:"instance_interfaces,omitempty"`
+}
+
+// InstanceInterface is a network interface of a deployed instance.
+type InstanceInterface struct {
+	Index     int  `json:"instance_interface_index"`
+	NetworkID int  `json:"network_id,omitempty"`
+	IPs       []IP `json:"instance_interface_ips,omitempty"`
+}
+
+// IP is an address allocated to an instance interface.
+type IP struct {
+	Address string `json:"ip_human_readable"`
+	Type    string `json:"ip_type"`
+}
+
+// DriveArray is a group of iSCSI drives attached to an instance array.
+type DriveArray struct {
+	ID               int    `json:"drive_array_id,omitempty"`
+	InfrastructureID int    `json:"infrastructure_id,omitempty"`
+	InstanceArrayID  int    `json:"instance_array_id,omitempty"`
+	Label            string `json:"drive_array_label"`
+	StorageType      string `json:"drive_array_storage_type,omitempty"`
+	DriveSizeMB      int    `json:"drive_size_mbytes_default"`
+	ServiceStatus    string `json:"drive_array_service_status,omitempty"`
+}
+
+// Network is a WAN, LAN or SAN network of an infrastructure.
+type Network struct {
+	ID               int    `json:"network_id,omitempty"`
+	InfrastructureID int    `json:"infrastructure_id,omitempty"`
+	Label            string `json:"network_label,omitempty"`
+	Type             string `json:"network_type"`
+	SubnetPool       string `json:"subnet_pool_label,omitempty"`
+	SubnetPrefixSize int    `json:"network_subnet_prefix_size,omitempty"`
+	VLANID           int    `json:"network_vlan_id,omitempty"`
+	ServiceStatus    string `json:"network_service_status,omitempty"`
+}
+
+// Subnet is a block of addresses of a network, from which the instance
+// interfaces attached to the network get their addresses.
+type Subnet struct {
+	ID         int    `json:"subnet_id,omitempty"`
+	NetworkID  int    `json:"network_id,omitempty"`
+	Type       string `json:"subnet_type"`
+	Address    string `json:"subnet_network_human_readable,omitempty"`
+	PrefixSize int    `json:"subnet_prefix_size"`
+	Gateway    string `json:"subnet_gateway_human_readable,omitempty"`
+}
+
+// ServerType describes a class of MetalSoft bare-metal servers.
+type ServerType struct {
+	ID                 int    `json:"server_type_id"`
+	Name               string `json:"server_type_name"`
+	ProcessorCoreCount int    `json:"server_processor_core_count"`
+	RAMGB              int    `json:"server_ram_gbytes"`
+	AvailableCount     int    `json:"server_available_count"`
+}
+
+// Server is a bare-metal server of the MetalSoft inventory.
+type Server struct {
+	ID                 int               `json:"server_id"`
+	ServerTypeID       int               `json:"server_type_id"`
+	Datacenter         string            `json:"datacenter_name"`
+	RackLabel          string            `json:"server_rack_label,omitempty"`
+	SerialNumber       string            `json:"server_serial_number,omitempty"`
+	ProcessorName      string            `json:"server_processor_name,omitempty"`
+	ProcessorCount     int               `json:"server_processor_count"`
+	ProcessorCoreCount int               `json:"server_processor_core_count"`
+	RAMGB              int               `json:"server_ram_gbytes"`
+	Disks              []ServerDisk      `json:"server_disks,omitempty"`
+	Interfaces         []ServerInterface `json:"server_interfaces,omitempty"`
+	PowerStatus        string            `json:"server_power_status,omitempty"`
+	Status             string            `json:"server_status,omitempty"`
+	InstanceID         int               `json:"instance_id,omitempty"`
+	ReservationKey     string            `json:"server_reservation_key,omitempty"`
+}
+
+// ServerSearch is a query of the server search API. Zero fields do not
+// constrain the search.
+type ServerSearch struct {
+	Datacenter      string   `json:"datacenter_name"`
+	ServerTypeID    int      `json:"server_type_id,omitempty"`
+	Status          string   `json:"server_status,omitempty"`
+	MinCoreCount    int      `json:"server_processor_core_count_min,omitempty"`
+	MinRAMGB        int      `json:"server_ram_gbytes_min,omitempty"`
+	DiskType        string   `json:"server_disk_type,omitempty"`
+	MinDiskCount    int      `json:"server_disk_count_min,omitempty"`
+	MinCapacityMbps int      `json:"server_interface_capacity_mbps_min,omitempty"`
+	RackLabels      []string `json:"server_rack_labels,omitempty"`
+}
+
+// ServerDisk is a local disk of a server.
+type ServerDisk struct {
+	SizeGB int    `json:"server_disk_size_gbytes"`
+	Type   string `json:"server_disk_type"`
+}
+
+// ServerInterface is a network interface of a server.
+type ServerInterface struct {
+	MACAddress   string `json:"server_interface_mac_address"`
+	CapacityMbps int    `json:"server_interface_capacity_mbps"`
+}
+
+// Rack is a server rack of a datacenter. Racks sharing their top-of-rack
+// switches belong to the same switch group.
+type Rack struct {
+	Label       string `json:"server_rack_label"`
+	Datacenter  string `json:"datacenter_name"`
+	SwitchGroup string `json:"switch_group_label,omitempty"`
+}
+
+// OSTemplate is an operating system image that can be installed on a server.
+type OSTemplate struct {
+	ID               int      `json:"volume_template_id"`
+	Label            string   `json:"volume_template_label"`
+	DisplayName      string   `json:"volume_template_display_name,omitempty"`
+	BootstrapFormats []string `json:"volume_template_bootstrap_formats,omitempty"`
+}
+
+// SupportsBootstrapFormat returns true if the OS template reads bootstrap data
+// of the given format. Templates that do not list their formats run cloud-init.
+func (t *OSTemplate) SupportsBootstrapFormat(format string) bool {
+	if len(t.BootstrapFormats) == 0 {
+		return format == BootstrapFormatCloudConfig
+	}
+	for _, supported := range t.BootstrapFormats {
+		if supported == format {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadBalancer is a MetalSoft-managed TCP load balancer of an infrastructure,
+// forwarding a frontend port on a WAN address to a set of instances.
+type LoadBalancer struct {
+	ID               int                   `json:"load_balancer_id,omitempty"`
+	InfrastructureID int                   `json:"infrastructure_id,omitempty"`
+	Label            string                `json:"load_balancer_label"`
+	Address          string                `json:"load_balancer_ip_human_readable,omitempty"`
+	Port             int                   `json:"load_balancer_port"`
+	Backends         []LoadBalancerBackend `json:"load_balancer_backends,omitempty"`
+	ServiceStatus    string                `json:"load_balancer_service_status,omitempty"`
+}
+
+// LoadBalancerBackend is an instance receiving the traffic of a load balancer.
+type LoadBalancerBackend struct {
+	InstanceID int    `json:"instance_id"`
+	Address    string `json:"ip_human_readable,omitempty"`
+	Port       int    `json:"load_balancer_backend_port"`
+}
+
+// FloatingIP is a WAN address reserved in an infrastructure that can be
+// routed to any one of its instances.
+type FloatingIP struct {
+	ID               int    `json:"floating_ip_id,omitempty"`
+	InfrastructureID int    `json:"infrastructure_id,omitempty"`
+	Label            string `json:"floating_ip_label"`
+	Address          string `json:"floating_ip_human_readable,omitempty"`
+	InstanceID       int    `json:"instance_id,omitempty"`
+	ServiceStatus    string `json:"floating_ip_service_status,omitempty"`
+}
+
+// ReservedIP is an address of a subnet that is never given to the instance
+// interfaces attached to its network, e.g. for a virtual IP announced by the
+// instances themselves.
+type ReservedIP struct {
+	ID               int    `json:"ip_id,omitempty"`
+	InfrastructureID int    `json:"infrastructure_id,omitempty"`
+	SubnetID         int    `json:"subnet_id,omitempty"`
+	Label            string `json:"ip_label"`
+	Address          string `json:"ip_human_readable,omitempty"`
+	ServiceStatus    string `json:"ip_service_status,omitempty"`
+}